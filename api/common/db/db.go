@@ -64,17 +64,21 @@ func UploadDB() {
 }
 
 func New() {
-	sqlt, err := sql.Open("sqlite", env.DatabasePath())
-	if err != nil {
+	if err := Open("sqlite", env.DatabasePath()); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	_, err = sqlt.Exec("PRAGMA foreign_keys = ON;")
+// 指定のドライバでDBファイルを開く（ベンチマークではドライバを差し替える）
+func Open(driverName string, path string) error {
+	// プールの全ての接続で外部キー制約（ON DELETE CASCADEを含む）を有効にする
+	sqlt, err := sql.Open(driverName, path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate")
 	if err != nil {
-		log.Fatal("Error enabling foreign key constraints:", err)
+		return err
 	}
 
 	db = sqlt
+	return nil
 }
 
 func CheckConnection() {
@@ -158,6 +162,34 @@ func CheckAffectedRows(result sql.Result, cnt int64) error {
 	return nil
 }
 
+// 既存のテーブルにカラムが無ければ追加する
+// SQLiteは`ADD COLUMN IF NOT EXISTS`に対応していないため、table_infoで確認する
+func AddColumnIfNotExists(table string, column string, definition string) error {
+	rows, err := Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // ------------------------------------------------------------
 
 func PInt64(n int64) *int64 {
@@ -167,3 +199,23 @@ func PInt64(n int64) *int64 {
 func PTime(t time.Time) *time.Time {
 	return &t
 }
+
+// 保存された日時の文字列を読み込む（集計した値など、列の型の情報が無く文字列で返る場合）
+func ParseTime(s string) (time.Time, error) {
+	// time.Timeの保存形式（Time.String）のモノトニッククロックの部分は除く
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	layouts := []string{
+		"2006-01-02 15:04:05.999999999 -0700 MST",
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time format: %s", s)
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// トランザクション
+// dao関数に渡すと、そのSQLはトランザクション内で実行される
+// nilの場合はトランザクションを使わずに実行する
+type Tx struct {
+	tx *sql.Tx
+}
+
+func Begin() (*Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		slog.Error("SQL Error", "query", "BEGIN", "error", err)
+		return nil, err
+	}
+	return &Tx{tx: tx}, nil
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// fをトランザクション内で実行する（エラーまたはpanicの場合はロールバック）
+func Transaction(f func(tx *Tx) error) (err error) {
+	tx, err := Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				slog.Error("Failed to rollback transaction", "error", rbErr)
+			}
+		}
+	}()
+
+	if err = f(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (t *Tx) Exec(sql string, args ...any) (sql.Result, error) {
+	if t == nil {
+		return Exec(sql, args...)
+	}
+	result, err := t.tx.Exec(sql, args...)
+	if err != nil {
+		slog.Error("SQL Error", "query", formatSQL(sql), "params", formatParams(args), "error", err)
+	}
+	return result, err
+}
+
+func (t *Tx) QueryRow(sql string, args ...any) *sql.Row {
+	if t == nil {
+		return QueryRow(sql, args...)
+	}
+	return t.tx.QueryRow(sql, args...)
+}
+
+func (t *Tx) Query(sql string, args ...any) (*sql.Rows, error) {
+	if t == nil {
+		return Query(sql, args...)
+	}
+	rows, err := t.tx.Query(sql, args...)
+	if err != nil {
+		slog.Error("SQL Error", "query", formatSQL(sql), "params", formatParams(args), "error", err)
+	}
+	return rows, err
+}
@@ -3,6 +3,8 @@ package env
 import (
 	"log"
 	"os"
+	"slices"
+	"strings"
 )
 
 const (
@@ -15,11 +17,13 @@ type Config struct {
 	Environment    string
 	SecretKey      []byte
 	AllowedOrigins []string
+	FrontendOrigin string // フロントエンドのオリジン（メール本文のリンクに使用）
 	DatabasePath   string
 	AwsRegion      string
 	S3BucketName   string
 	EmailSender    string
 	SwaggerEnable  bool
+	AdminIDs       []string // 管理者のアカウントID
 }
 
 var conf *Config
@@ -34,6 +38,14 @@ func Initialize() {
 		log.Fatal("FRONTEND_ORIGIN environment variable is required")
 	}
 
+	// 管理者のアカウントID（カンマ区切り、省略可）
+	adminIDs := []string{}
+	for _, id := range strings.Split(os.Getenv("ADMIN_ACCOUNT_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			adminIDs = append(adminIDs, id)
+		}
+	}
+
 	env := os.Getenv("ENV")
 	switch env {
 	case envProduction:
@@ -41,33 +53,39 @@ func Initialize() {
 			Environment:    envProduction,
 			SecretKey:      []byte(secretKey),
 			AllowedOrigins: []string{frontendOrigin},
+			FrontendOrigin: frontendOrigin,
 			DatabasePath:   "kifup.db",
 			AwsRegion:      "ap-northeast-1",
 			S3BucketName:   "s3-kifup",
 			EmailSender:    "system.kifup@jcytp.net",
 			SwaggerEnable:  false,
+			AdminIDs:       adminIDs,
 		}
 	case envStaging:
 		conf = &Config{
 			Environment:    envStaging,
 			SecretKey:      []byte(secretKey),
 			AllowedOrigins: []string{frontendOrigin},
+			FrontendOrigin: frontendOrigin,
 			DatabasePath:   "kifup.db",
 			AwsRegion:      "ap-northeast-1",
 			S3BucketName:   "s3-kifup-stg",
 			EmailSender:    "system.kifup@jcytp.net",
 			SwaggerEnable:  true,
+			AdminIDs:       adminIDs,
 		}
 	case envDevelopment:
 		conf = &Config{
 			Environment:    envDevelopment,
 			SecretKey:      []byte(secretKey),
 			AllowedOrigins: []string{"http://localhost", frontendOrigin},
+			FrontendOrigin: frontendOrigin,
 			DatabasePath:   "tmp/kifup.db",
 			AwsRegion:      "",
 			S3BucketName:   "",
 			EmailSender:    "",
 			SwaggerEnable:  true,
+			AdminIDs:       adminIDs,
 		}
 	default:
 		log.Fatalf("invalid environment: %s", env)
@@ -98,6 +116,10 @@ func AllowedOrigins() []string {
 	return conf.AllowedOrigins
 }
 
+func FrontendOrigin() string {
+	return conf.FrontendOrigin
+}
+
 func DatabasePath() string {
 	return conf.DatabasePath
 }
@@ -117,3 +139,7 @@ func EmailSender() string {
 func SwaggerEnable() bool {
 	return conf.SwaggerEnable
 }
+
+func IsAdmin(accountID string) bool {
+	return accountID != "" && slices.Contains(conf.AdminIDs, accountID)
+}
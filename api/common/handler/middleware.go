@@ -87,3 +87,13 @@ func MwRequireSession(c *gin.Context) {
 		return
 	}
 }
+
+// 管理者のみ（MwRequireSessionの後に使用）
+func MwRequireAdmin(c *gin.Context) {
+	aid := GetActorID(c)
+	if !env.IsAdmin(aid) {
+		ResponseForbidden(c, "Admin only", fmt.Errorf("not admin: %s", aid))
+		c.Abort()
+		return
+	}
+}
@@ -2,24 +2,37 @@
 
 package handler
 
-import "math"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+)
 
 type PaginationRequest struct {
-	Page     int `form:"page,default=1" binding:"min=1"`
-	PageSize int `form:"page_size,default=20" binding:"min=1,max=100"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+	Cursor   string `form:"cursor" binding:"max=1000"` // 前のレスポンスのnext_cursor（指定した場合はpageより優先）
 }
 
 type PaginatedResponse struct {
-	TotalCount int `json:"total_count"`
-	Page       int `json:"page"`
-	PageSize   int `json:"page_size"`
-	MaxPage    int `json:"max_page"`
+	TotalCount int     `json:"total_count"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+	MaxPage    int     `json:"max_page"`
+	NextCursor *string `json:"next_cursor,omitempty"` // 続きを取得するためのカーソル（続きが無い場合は省略）
 }
 
 func (req *PaginationRequest) LimitOffset() (int, int) {
+	if req.UsesCursor() {
+		return req.PageSize, 0
+	}
 	return req.PageSize, req.PageSize * (req.Page - 1)
 }
 
+func (req *PaginationRequest) UsesCursor() bool {
+	return req.Cursor != ""
+}
+
 func (req *PaginationRequest) NewPaginatedResponse(totalCount int) *PaginatedResponse {
 	maxPage := int(math.Max(1, math.Ceil(float64(totalCount)/float64(req.PageSize))))
 
@@ -30,3 +43,21 @@ func (req *PaginationRequest) NewPaginatedResponse(totalCount int) *PaginatedRes
 		MaxPage:    maxPage,
 	}
 }
+
+// カーソルの内容を不透明なトークンにする
+func EncodeCursor(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// リクエストのカーソルのトークンを読み込む
+func (req *PaginationRequest) DecodeCursor(v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
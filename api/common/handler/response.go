@@ -27,8 +27,16 @@ func ResponseOK(c *gin.Context, data any) {
 }
 
 func ResponseError(c *gin.Context, status int, msg string, err error) {
-	if strings.HasPrefix(msg, "UNAUTHORIZED") {
+	if strings.HasPrefix(msg, "BAD_REQUEST") {
+		status = http.StatusBadRequest
+	} else if strings.HasPrefix(msg, "UNAUTHORIZED") {
 		status = http.StatusUnauthorized
+	} else if strings.HasPrefix(msg, "FORBIDDEN") {
+		status = http.StatusForbidden
+	} else if strings.HasPrefix(msg, "CONFLICT") {
+		status = http.StatusConflict
+	} else if strings.HasPrefix(msg, "PRECONDITION_REQUIRED") {
+		status = http.StatusPreconditionRequired
 	}
 
 	if err != nil {
@@ -51,6 +59,10 @@ func ResponseUnauthorized(c *gin.Context, msg string, err error) {
 	ResponseError(c, http.StatusUnauthorized, msg, err)
 }
 
+func ResponseForbidden(c *gin.Context, msg string, err error) {
+	ResponseError(c, http.StatusForbidden, msg, err)
+}
+
 func ResponseBadRequest(c *gin.Context, msg string, err error) {
 	ResponseError(c, http.StatusBadRequest, msg, err)
 }
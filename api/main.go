@@ -37,16 +37,19 @@ func main() {
 			db.New() // ダウンロードしたDBの使用
 		}
 	}
+	api.SetupTables() // 既存のDBに未作成のテーブルがあれば作成
 	defer db.Close()
-	db.StartBackupCycle()    // 定期的なバックアップの作成
-	db.ScheduleFinalBackup() // 正常終了時の最終バックアップ
+	db.StartBackupCycle()              // 定期的なバックアップの作成
+	db.ScheduleFinalBackup()           // 正常終了時の最終バックアップ
+	api.StartNotificationDigestCycle() // 定期的な通知メールの送信
 
 	// gin engine
 	r := gin.Default()
 	config := cors.DefaultConfig()
 	config.AllowOrigins = env.AllowedOrigins()
-	config.AllowMethods = []string{"GET", "HEAD", "OPTIONS", "POST", "PUT", "DELETE"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowMethods = []string{"GET", "HEAD", "OPTIONS", "POST", "PUT", "PATCH", "DELETE"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "If-Match"}
+	config.ExposeHeaders = []string{"ETag"}
 	r.Use(cors.New(config))
 	r.Use(handler.MwSetCacheControl)
 
@@ -67,6 +70,10 @@ func main() {
 	rSes := rOpt.Group("/")
 	rSes.Use(handler.MwRequireSession)
 
+	// require admin
+	rAdm := rSes.Group("/admin")
+	rAdm.Use(handler.MwRequireAdmin)
+
 	// health-check api
 	rPub.GET("/status", handler.HandlerOut(api.GetServerStatus))
 
@@ -75,6 +82,7 @@ func main() {
 	rPub.POST("/account/verify-code", handler.HandlerIn(api.CheckVerificationCode))
 	rPub.POST("/account", handler.HandlerIn(api.CreateAccount))
 	rSes.GET("/account", handler.HandlerOut(api.GetAccount))
+	rSes.GET("/account/stats", handler.HandlerOut(api.GetAccountStats))
 	rSes.DELETE("/account", handler.Handler(api.DeleteAccount))
 	rPub.POST("/account/reset-password", handler.HandlerIn(api.ResetPassword))
 	rSes.PUT("/account/password", handler.HandlerIn(api.ChangePassword))
@@ -88,10 +96,30 @@ func main() {
 	// kifu api
 	rSes.POST("/kifu", handler.HandlerInOut(api.CreateKifu))
 	rOpt.GET("/kifu", handler.HandlerInPagination(api.ListKifus))
+	rOpt.GET("/kifu/compare", handler.HandlerOut(api.CompareKifus))
 	rOpt.GET("/kifu/:kifuID", handler.HandlerOut(api.GetKifu))
 	rSes.DELETE("/kifu/:kifuID", handler.Handler(api.DeleteKifu))
 	rSes.PUT("/kifu/:kifuID", handler.HandlerIn(api.UpdateKifuInfo))
 	rSes.PUT("/kifu/:kifuID/moves", handler.HandlerIn(api.UpdateKifuMoves))
+	rOpt.GET("/kifu/:kifuID/position", handler.HandlerOut(api.GetKifuPosition))
+	rOpt.GET("/kifu/:kifuID/similar", handler.HandlerOut(api.ListSimilarKifus))
+	rSes.POST("/kifu/:kifuID/branches/:branchID/moves", handler.HandlerIn(api.AppendKifuMoves))
+	rSes.PATCH("/kifu/:kifuID/branches/:branchID/moves/:number", handler.HandlerIn(api.UpdateKifuMove))
+	rSes.POST("/kifu/:kifuID/branches/:branchID/truncate", handler.HandlerIn(api.TruncateKifuBranch))
+	rSes.POST("/kifu/:kifuID/branches/:branchID/variations", handler.HandlerInOut(api.AddKifuVariation))
+	rSes.DELETE("/kifu/:kifuID/branches/:branchID", handler.Handler(api.DeleteKifuVariation))
+	rSes.PUT("/kifu/:kifuID/branches/:branchID/ending", handler.HandlerIn(api.UpdateKifuBranchEnding))
+	rSes.POST("/kifu/:kifuID/branches/:branchID/promote", handler.HandlerIn(api.PromoteKifuVariation))
+	rSes.POST("/kifu/:kifuID/branches/:branchID/reorder", handler.HandlerIn(api.ReorderKifuVariation))
+	rSes.GET("/kifu/:kifuID/revisions", handler.HandlerPagination(api.ListKifuRevisions))
+	rSes.GET("/kifu/:kifuID/revisions/:revisionID", handler.HandlerOut(api.GetKifuRevision))
+	rSes.POST("/kifu/:kifuID/revisions/:revisionID/restore", handler.HandlerIn(api.RestoreKifuRevision))
+	rSes.POST("/kifu/:kifuID/fork", handler.HandlerOut(api.ForkKifu))
+	rSes.POST("/kifu/merge", handler.HandlerInOut(api.MergeKifus))
+
+	// kifu import api
+	rSes.POST("/kifu/import", handler.HandlerInOut(api.ImportKifus))
+	rSes.GET("/kifu/import/:jobID", handler.HandlerOut(api.GetImportJob))
 
 	// social api
 	rSes.POST("/kifu/:kifuID/like", handler.Handler(api.LikeKifu))
@@ -99,5 +127,30 @@ func main() {
 	rSes.POST("/kifu/:kifuID/comment", handler.HandlerIn(api.PostKifuComment))
 	rOpt.GET("/kifu/:kifuID/comments", handler.HandlerOut(api.ListKifuComments))
 
+	// player api
+	rPub.GET("/players", handler.HandlerPagination(api.ListPlayers))
+	rPub.GET("/players/:playerID", handler.HandlerPagination(api.GetPlayer))
+	rPub.GET("/players/:playerID/stats", handler.HandlerOut(api.GetPlayerStats))
+	rAdm.GET("/players/reviews", handler.HandlerOut(api.ListPlayerReviews))
+	rAdm.POST("/players/reviews", handler.HandlerInOut(api.ResolvePlayerReview))
+	rAdm.POST("/players/:playerID/aliases", handler.HandlerIn(api.AddPlayerAlias))
+
+	// saved search api
+	rSes.POST("/saved-searches", handler.HandlerInOut(api.CreateSavedSearch))
+	rSes.GET("/saved-searches", handler.HandlerOut(api.ListSavedSearches))
+	rSes.DELETE("/saved-searches/:searchID", handler.Handler(api.DeleteSavedSearch))
+
+	// notification api
+	rSes.GET("/notifications", handler.HandlerInPagination(api.ListNotifications))
+	rSes.PUT("/notifications/read", handler.Handler(api.ReadAllNotifications))
+	rSes.PUT("/notifications/:notificationID/read", handler.Handler(api.ReadNotification))
+
+	// tag api
+	rPub.GET("/tags", handler.HandlerOut(api.ListTags))
+	rAdm.POST("/tags/merge", handler.HandlerInOut(api.MergeTags))
+	rAdm.GET("/tags/aliases", handler.HandlerOut(api.ListTagAliases))
+	rAdm.PUT("/tags/aliases", handler.HandlerIn(api.PutTagAlias))
+	rAdm.DELETE("/tags/aliases/:alias", handler.Handler(api.DeleteTagAlias))
+
 	r.Run(":80") // default -> localhost:8080
 }
@@ -10,6 +10,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/jcytp/kifup-api/common/aws"
+	"github.com/jcytp/kifup-api/common/db"
 	"github.com/jcytp/kifup-api/common/env"
 	"github.com/jcytp/kifup-api/common/handler"
 	"github.com/jcytp/kifup-api/service/dao"
@@ -106,7 +107,17 @@ func GetAccount(c *gin.Context) (*model.AccountResponse, string, error) {
 	if err != nil {
 		return nil, "Failed to get account", err
 	}
-	return account.ToResponse(), "", nil
+	playerNames, err := dao.ListAccountPlayerNames(aid)
+	if err != nil {
+		return nil, "Failed to get player names", err
+	}
+	names := make([]string, 0, len(playerNames))
+	for _, playerName := range playerNames {
+		names = append(names, playerName.Name)
+	}
+	resp := account.ToResponse()
+	resp.PlayerNames = &names
+	return resp, "", nil
 }
 
 func GetAccountByID(c *gin.Context) (*model.AccountResponse, string, error) {
@@ -192,9 +203,11 @@ func ChangePassword(c *gin.Context, req requestChangePassword) (string, error) {
 }
 
 type requestUpadateAccountInfo struct {
-	Name         string `json:"name" binding:"required,min=2,max=60"`
-	IconID       string `json:"icon_id" binding:"max=60"`
-	Introduction string `json:"introduction" binding:"max=1000"`
+	Name         string    `json:"name" binding:"required,min=2,max=60"`
+	IconID       string    `json:"icon_id" binding:"max=60"`
+	Introduction string    `json:"introduction" binding:"max=1000"`
+	AllowFork    *bool     `json:"allow_fork"`                                           // 省略時は変更しない
+	PlayerNames  *[]string `json:"player_names" binding:"omitempty,max=10,dive,max=100"` // 自分とみなす対局者名（省略時は変更しない）
 }
 
 func UpdateAccountInfo(c *gin.Context, req requestUpadateAccountInfo) (string, error) {
@@ -206,9 +219,33 @@ func UpdateAccountInfo(c *gin.Context, req requestUpadateAccountInfo) (string, e
 	account.Name = req.Name
 	account.IconID = req.IconID
 	account.Introduction = req.Introduction
+	if req.AllowFork != nil {
+		account.AllowFork = *req.AllowFork
+	}
 	if err = dao.UpdateAccount(account); err != nil {
 		return "Failed to update account info", err
 	}
 
+	if req.PlayerNames != nil {
+		names := []*model.AccountPlayerName{}
+		for _, name := range *req.PlayerNames {
+			name = model.NormalizePlayerName(name)
+			if name == "" {
+				continue
+			}
+			names = append(names, &model.AccountPlayerName{
+				AccountID: aid,
+				NameKey:   model.PlayerNameKey(name),
+				Name:      name,
+			})
+		}
+		return inTransaction(func(tx *db.Tx) (string, error) {
+			if err := dao.ReplaceAccountPlayerNames(tx, aid, names); err != nil {
+				return "Failed to update player names", err
+			}
+			return "", nil
+		})
+	}
+
 	return "", nil
 }
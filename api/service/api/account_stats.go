@@ -0,0 +1,147 @@
+// service/api/account_stats.go
+
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+const (
+	accountStatsOpeningLimit = 10 // よく指す戦型（タグ）の数
+	accountStatsKifuLimit    = 5  // いいね・コメントの多い棋譜の数
+)
+
+// アカウントの統計（自分の棋譜の集計、非公開の棋譜を含む）
+// 成績と消費時間は、棋譜の先手・後手の名前が設定した対局者名と一致した側で集計する
+func GetAccountStats(c *gin.Context) (*model.AccountStatsResponse, string, error) {
+	aid := handler.GetActorID(c)
+	response := &model.AccountStatsResponse{}
+
+	// 棋譜数・月ごとの棋譜数
+	kifuCount, err := dao.CountKifusByCondition(&dao.KifuSearchCondition{AccountID: &aid})
+	if err != nil {
+		return nil, "Failed to count kifus", err
+	}
+	response.KifuCount = int64(kifuCount)
+	months, err := dao.ListAccountKifuMonthCounts(aid)
+	if err != nil {
+		return nil, "Failed to get kifu counts", err
+	}
+	response.Months = make([]*model.MonthCountResponse, 0, len(months))
+	for _, month := range months {
+		response.Months = append(response.Months, month.ToResponse())
+	}
+
+	// 対局者名として一致した対局の成績
+	mySides, results, msg, err := getAccountResults(aid)
+	if err != nil {
+		return nil, msg, err
+	}
+	response.Results = results
+
+	// よく指す戦型
+	openings, err := dao.ListAccountKifuTagCounts(aid, accountStatsOpeningLimit)
+	if err != nil {
+		return nil, "Failed to get kifu tags", err
+	}
+	response.Openings = make([]*model.TagCountResponse, 0, len(openings))
+	for _, opening := range openings {
+		response.Openings = append(response.Openings, opening.ToResponse())
+	}
+
+	// 消費時間の傾向（自分の側の指し手）
+	moves, err := dao.ListAccountMoveTimes(aid)
+	if err != nil {
+		return nil, "Failed to get kifu moves", err
+	}
+	timeUse := model.NewTimeUseStats()
+	for _, move := range moves {
+		if side, ok := mySides[move.KifuID]; ok && model.MoveSide(move.InitialPosition, move.Number) == side {
+			timeUse.Add(move)
+		}
+	}
+	response.TimeUse = timeUse.ToResponse()
+
+	// いいね・コメントの多い棋譜
+	if response.MostLiked, msg, err = listAccountTopKifus(aid, model.KIFU_SORT_LIKED); err != nil {
+		return nil, msg, err
+	}
+	if response.MostCommented, msg, err = listAccountTopKifus(aid, model.KIFU_SORT_COMMENTED); err != nil {
+		return nil, msg, err
+	}
+
+	return response, "", nil
+}
+
+// 自分の棋譜のうち、先手・後手の名前（段級位・タイトルを除いた名前を含む）が設定した対局者名と一致した側の成績
+// 一致した棋譜ごとの自分の側も返す（両方が一致した場合は先手）
+func getAccountResults(aid string) (map[string]model.PlayerSide, *model.AccountResultsResponse, string, error) {
+	playerNames, err := dao.ListAccountPlayerNames(aid)
+	if err != nil {
+		return nil, nil, "Failed to get player names", err
+	}
+	names := make([]string, 0, len(playerNames))
+	myKeys := map[string]bool{}
+	for _, playerName := range playerNames {
+		names = append(names, playerName.Name)
+		for _, key := range model.PlayerNameKeys(playerName.Name) {
+			myKeys[key] = true
+		}
+	}
+
+	mySides := map[string]model.PlayerSide{}
+	records := map[model.PlayerSide]*model.PlayerRecord{
+		model.SIDE_BLACK: {},
+		model.SIDE_WHITE: {},
+	}
+	if len(myKeys) > 0 {
+		kifuPlayers, err := dao.ListAccountKifuPlayers(aid)
+		if err != nil {
+			return nil, nil, "Failed to get kifu players", err
+		}
+		for _, kifuPlayer := range kifuPlayers {
+			if _, ok := mySides[kifuPlayer.KifuID]; ok {
+				continue
+			}
+			for _, key := range model.PlayerNameKeys(kifuPlayer.Name) {
+				if myKeys[key] {
+					mySides[kifuPlayer.KifuID] = kifuPlayer.Side
+					records[kifuPlayer.Side].Add(kifuPlayer.Result, kifuPlayer.MoveCount)
+					break
+				}
+			}
+		}
+	}
+
+	results := &model.AccountResultsResponse{PlayerNames: names}
+	results.Total, results.Black, results.White = model.NewPlayerRecordResponses(records)
+	return mySides, results, "", nil
+}
+
+// いいね・コメントの多い自分の棋譜（0件の棋譜は含めない）
+func listAccountTopKifus(aid string, sort model.KifuSort) ([]*model.KifuSummaryResponse, string, error) {
+	cond := &dao.KifuSearchCondition{AccountID: &aid, Sort: sort}
+	kifus, _, err := dao.ListKifusByCondition(cond, accountStatsKifuLimit, 0)
+	if err != nil {
+		return nil, "Failed to get kifus", err
+	}
+	summaries, msg, err := newKifuSummaryResponses(kifus)
+	if err != nil {
+		return nil, msg, err
+	}
+	response := make([]*model.KifuSummaryResponse, 0, len(summaries))
+	for _, summary := range summaries {
+		count := summary.LikeCount
+		if sort == model.KIFU_SORT_COMMENTED {
+			count = summary.CommentCount
+		}
+		if count > 0 {
+			response = append(response, summary)
+		}
+	}
+	return response, "", nil
+}
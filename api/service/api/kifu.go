@@ -4,9 +4,14 @@ package api
 
 import (
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
 	"github.com/jcytp/kifup-api/common/handler"
 	"github.com/jcytp/kifup-api/service/api/parser"
 	"github.com/jcytp/kifup-api/service/dao"
@@ -15,84 +20,278 @@ import (
 
 // ------------------------------------------------------------
 type requestCreateKifu struct {
-	Type            string  `json:"type" binding:"required,oneof=file position"`
-	Content         *string `json:"content,omitempty" binding:"required_if=Type file"`
-	InitialPosition *string `json:"initial_position,omitempty"`
+	Type            string  `json:"type" binding:"required,oneof=file position usi"`
+	Content         *string `json:"content,omitempty" binding:"required_unless=Type position"` // fileは棋譜テキスト、usiはpositionコマンド
+	InitialPosition *string `json:"initial_position,omitempty"`                                // SFENまたはBOD形式の局面図
+	OnDuplicate     string  `json:"on_duplicate" binding:"omitempty,oneof=warn skip"`          // 同一棋譜がある場合の動作（省略時はwarn）
 }
 
-func CreateKifu(c *gin.Context, req requestCreateKifu) (*string, string, error) {
+type CreateKifuResponse struct {
+	ID         *string                        `json:"id"`                   // 作成した棋譜ID（重複によりスキップした場合はNULL）
+	Duplicates []*model.DuplicateKifuResponse `json:"duplicates,omitempty"` // 既に登録されている同一の棋譜
+}
+
+const duplicateKifuLimit = 5 // レスポンスに含める同一棋譜の最大数
+
+func CreateKifu(c *gin.Context, req requestCreateKifu) (*CreateKifuResponse, string, error) {
 	aid := handler.GetActorID(c)
 
 	switch req.Type {
 	case "file":
-		return createKifuFromFile(aid, *req.Content)
+		return createKifuFromFile(aid, *req.Content, req.OnDuplicate == "skip")
+	case "usi":
+		return createKifuFromUSI(aid, *req.Content, req.OnDuplicate == "skip")
 	case "position":
-		return createKifuFromPosition(aid, (*model.SFEN)(req.InitialPosition))
+		sfen, err := parseInitialPosition(req.InitialPosition)
+		if err != nil {
+			return nil, "Invalid initial position", err
+		}
+		kifuID, msg, err := createKifuFromPosition(aid, sfen)
+		if err != nil {
+			return nil, msg, err
+		}
+		return &CreateKifuResponse{ID: kifuID}, "", nil
 	default:
 		return nil, "Invalid creation type", fmt.Errorf("invalid type: %s", req.Type)
 	}
 }
 
-func createKifuFromFile(aid string, content string) (*string, string, error) {
+func createKifuFromFile(aid string, content string, skipDuplicate bool) (*CreateKifuResponse, string, error) {
 	// 1. 棋譜フォーマットごとに棋譜テキストをパースする
 	// 　※どの棋譜フォーマットにも合致しなければエラー
-	// 2. 生成されたKifu・KifuOption・KifuBranch・KifuMoveをDBに保存する
-	var err error
-	var parsedKifu *model.ParsedKifu
+	// 2. 同一の棋譜が登録済みか確認する（skipDuplicateなら作成しない）
+	// 3. 生成されたKifu・KifuOption・KifuBranch・KifuMoveをDBに保存する
+	parsedKifu, msg, err := parseKifuFile(content)
+	if err != nil {
+		return nil, msg, err
+	}
+	return createKifuCheckingDuplicate(aid, parsedKifu, skipDuplicate)
+}
+
+func createKifuFromUSI(aid string, content string, skipDuplicate bool) (*CreateKifuResponse, string, error) {
+	parsedKifu, err := parser.ParseFromUSI(content) // フォーマット対象外の場合、nil, nilが返る
+	if err != nil {
+		return nil, "error in Parsing from USI", err
+	} else if parsedKifu == nil {
+		return nil, "Formats unmatched", fmt.Errorf("content is not usi position command")
+	}
+	return createKifuCheckingDuplicate(aid, parsedKifu, skipDuplicate)
+}
+
+// 同一棋譜を確認してからDBへ保存する（skipDuplicateなら同一棋譜がある場合は作成しない）
+func createKifuCheckingDuplicate(aid string, parsedKifu *model.ParsedKifu, skipDuplicate bool) (*CreateKifuResponse, string, error) {
+	parsedKifu.Kifu.AccountID = aid
+
+	duplicates, msg, err := findDuplicateKifus(parsedKifu)
+	if err != nil {
+		return nil, msg, err
+	}
+	resp := &CreateKifuResponse{}
+	for _, duplicate := range duplicates {
+		resp.Duplicates = append(resp.Duplicates, duplicate.ToDuplicateResponse(aid))
+	}
+	if skipDuplicate && len(duplicates) > 0 {
+		return resp, "", nil
+	}
+
+	resp.ID, msg, err = createKifuFromParsedKifu(parsedKifu) // DBへ保存
+	if err != nil {
+		return nil, msg, err
+	}
+	return resp, "", nil
+}
+
+// 自身の棋譜と公開棋譜から同一の棋譜を探す（フィンガープリントを設定する）
+func findDuplicateKifus(parsedKifu *model.ParsedKifu) ([]*model.Kifu, string, error) {
+	fingerprint, err := parsedKifu.Fingerprint()
+	if err != nil {
+		return nil, "Invalid initial position", err
+	}
+	parsedKifu.Kifu.Fingerprint = &fingerprint
+
+	// 指し手のない棋譜は重複とみなさない
+	if len(parsedKifu.Branches) == 0 || len(parsedKifu.Branches[0].Moves) == 0 {
+		return nil, "", nil
+	}
+	duplicates, err := dao.ListDuplicateKifus(fingerprint, parsedKifu.Kifu.AccountID, duplicateKifuLimit)
+	if err != nil {
+		return nil, "Failed to check duplicate kifus", err
+	}
+	return duplicates, "", nil
+}
+
+// フィンガープリントが未設定の棋譜に設定する（fingerprint追加前の棋譜の移行）
+// 初期局面を読めない棋譜は重複の判定対象外のまま残す
+func fillKifuFingerprints() error {
+	kifuIDs, err := dao.ListKifuIDsWithoutFingerprint()
+	if err != nil {
+		return err
+	}
+	for _, kifuID := range kifuIDs {
+		kifu, err := dao.GetKifu(kifuID)
+		if err != nil {
+			return err
+		}
+		branches, _, err := listKifuBranchesWithMoves(kifuID)
+		if err != nil {
+			return err
+		}
+		var mainMoves []*model.KifuMove
+		if mainBranch := model.MainBranch(branches); mainBranch != nil {
+			mainMoves = mainBranch.Moves
+		}
+		fingerprint, err := model.NewKifuFingerprint(kifu.InitialPosition, mainMoves)
+		if err != nil {
+			slog.Warn("Failed to create kifu fingerprint", "kifuID", kifuID, "error", err)
+			continue
+		}
+		err = db.Transaction(func(tx *db.Tx) error {
+			return dao.UpdateKifuFingerprint(tx, kifuID, fingerprint)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 対応する棋譜フォーマットを順に試してパースする
+func parseKifuFile(content string) (*model.ParsedKifu, string, error) {
+	parsedKifu, err := parser.ParseFromJKF(content) // フォーマット対象外の場合、nil, nilが返る
+	if err != nil {
+		return nil, "error in Parsing from JKF", err
+	} else if parsedKifu != nil {
+		parsedKifu.ExtractAnnotations() // コメント内の注釈の表記を注釈にする
+		return parsedKifu, "", nil
+	}
 
 	parsedKifu, err = parser.ParseFromKIF(content) // フォーマット対象外の場合、nil, nilが返る
 	if err != nil {
 		return nil, "error in Parsing from KIF", err
 	} else if parsedKifu != nil {
-		parsedKifu.Kifu.AccountID = aid
-		return createKifuFromParsedKifu(parsedKifu) // DBへ保存
+		parsedKifu.ExtractAnnotations() // コメント内の注釈の表記を注釈にする
+		return parsedKifu, "", nil
 	}
 
 	parsedKifu, err = parser.ParseFromCSA(content) // フォーマット対象外の場合、nil, nilが返る
 	if err != nil {
-		return nil, "error in Parsing from KIF", err
+		return nil, "error in Parsing from CSA", err
 	} else if parsedKifu != nil {
-		parsedKifu.Kifu.AccountID = aid
-		return createKifuFromParsedKifu(parsedKifu) // DBへ保存
+		parsedKifu.ExtractAnnotations() // コメント内の注釈の表記を注釈にする
+		return parsedKifu, "", nil
 	}
 
 	return nil, "Formats unmatched", fmt.Errorf("content unmatched any kifu formats")
 }
 
 func createKifuFromParsedKifu(parsedKifu *model.ParsedKifu) (*string, string, error) {
-	kifuID, err := dao.InsertKifu(parsedKifu.Kifu)
-	if err != nil {
-		return nil, "Failed to create kifu", err
+	if parsedKifu.Kifu.Fingerprint == nil {
+		fingerprint, err := parsedKifu.Fingerprint()
+		if err != nil {
+			return nil, "Invalid initial position", err
+		}
+		parsedKifu.Kifu.Fingerprint = &fingerprint
 	}
 
-	for i := range parsedKifu.Options {
-		parsedKifu.Options[i].KifuID = kifuID
+	// 棋譜・棋譜情報・分岐・指し手をまとめて保存する（途中で失敗した場合は何も残さない）
+	var kifuID string
+	msg, err := inTransaction(func(tx *db.Tx) (string, error) {
+		var err error
+		kifuID, err = dao.InsertKifu(tx, parsedKifu.Kifu)
+		if err != nil {
+			return "Failed to create kifu", err
+		}
+
+		for i := range parsedKifu.Options {
+			parsedKifu.Options[i].KifuID = kifuID
+		}
+		if err := dao.InsertKifuOptions(tx, parsedKifu.Options); err != nil {
+			return "Failed to create kifu options", err
+		}
+
+		if msg, err := insertBranchesWithMoves(tx, kifuID, parsedKifu.Branches); err != nil {
+			return msg, err
+		}
+		if msg, err := refreshKifuIndexes(tx, kifuID); err != nil {
+			return msg, err
+		}
+
+		revision, err := newKifuRevision(parsedKifu.Kifu, parsedKifu.Kifu.Version, parsedKifu.Kifu.AccountID, model.KIFU_REVISION_CREATE, parsedKifu.Options, nil, parsedKifu.Branches)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		return insertKifuRevisions(tx, nil, revision)
+	})
+	if err != nil {
+		return nil, msg, err
 	}
-	if err := dao.InsertKifuOptions(parsedKifu.Options); err != nil {
-		return nil, "Failed to create kifu options", err
+
+	// 公開状態で作成した棋譜を保存された検索条件と照合する
+	if parsedKifu.Kifu.IsPublic {
+		go notifySavedSearches(kifuID)
 	}
+	return &kifuID, "", nil
+}
 
+// 分岐と指し手を保存する（branchesは親ブランチが先の順で、IDは仮IDから実IDに置き換える）
+func insertBranchesWithMoves(tx *db.Tx, kifuID string, branches []*model.KifuBranchWithMoves) (string, error) {
 	branchIDMap := make(map[string]string) // 仮ID->実IDの対応表
-	for _, branch := range parsedKifu.Branches {
+	siblingCount := make(map[string]int64) // 分岐元ごとの分岐数（兄弟間の順序に使用）
+	for _, branch := range branches {
 		branch.KifuID = kifuID
 		if branch.RootBranchID != nil {
 			rootBranchID := branchIDMap[*branch.RootBranchID]
 			branch.RootBranchID = &rootBranchID
+			rootKey := fmt.Sprintf("%s:%d", rootBranchID, *branch.RootNumber)
+			branch.SortOrder = siblingCount[rootKey]
+			siblingCount[rootKey]++
 		}
-		branchID, err := dao.InsertKifuBranch(branch.KifuBranch)
+		tmpBranchID := branch.ID
+		branchID, err := dao.InsertKifuBranch(tx, branch.KifuBranch)
 		if err != nil {
-			return nil, "Failed to create kifu branch", err
+			return "Failed to create kifu branch", err
 		}
+		branchIDMap[tmpBranchID] = branchID
 
 		for _, move := range branch.Moves {
 			move.BranchID = branchID
 		}
-		if err := dao.InsertKifuMoves(branch.Moves); err != nil {
-			return nil, "Failed to create kifu moves", err
+		if err := insertKifuMoves(tx, branch.Moves); err != nil {
+			return "Failed to create kifu moves", err
 		}
 	}
+	return "", nil
+}
 
-	return &kifuID, "", nil
+// 指し手と、指し手に付いた注釈を保存する
+func insertKifuMoves(tx *db.Tx, moves []*model.KifuMove) error {
+	if err := dao.InsertKifuMoves(tx, moves); err != nil {
+		return err
+	}
+	annotations := []*model.KifuAnnotation{}
+	for _, move := range moves {
+		for _, annotation := range move.Annotations {
+			annotation.BranchID, annotation.Number = move.BranchID, move.Number
+			annotations = append(annotations, annotation)
+		}
+	}
+	return dao.InsertKifuAnnotations(tx, annotations)
+}
+
+// BOD形式の局面図はSFENに変換する（NULLは平手初期局面）
+func parseInitialPosition(initialPosition *string) (*model.SFEN, error) {
+	if initialPosition == nil || !strings.Contains(*initialPosition, "\n") {
+		return (*model.SFEN)(initialPosition), nil
+	}
+	position, err := model.NewBoardPositionFromBOD(*initialPosition)
+	if err != nil {
+		return nil, err
+	}
+	sfen, err := position.ToSFEN(1)
+	if err != nil {
+		return nil, err
+	}
+	return &sfen, nil
 }
 
 func createKifuFromPosition(aid string, sfen *model.SFEN) (*string, string, error) {
@@ -102,25 +301,47 @@ func createKifuFromPosition(aid string, sfen *model.SFEN) (*string, string, erro
 		return nil, "Invalid initial position", err
 	}
 
+	fingerprint, err := model.NewKifuFingerprint(sfen, nil)
+	if err != nil {
+		return nil, "Invalid initial position", err
+	}
+
 	// 棋譜レコードを作成
 	kifu := &model.Kifu{
 		AccountID:       aid,
 		Title:           "新規棋譜",
 		IsPublic:        false,
 		InitialPosition: sfen,
+		Fingerprint:     &fingerprint,
 	}
-	kifuID, err := dao.InsertKifu(kifu)
-	if err != nil {
-		return nil, "Failed to create kifu", err
-	}
+	var kifuID string
+	msg, err := inTransaction(func(tx *db.Tx) (string, error) {
+		var err error
+		kifuID, err = dao.InsertKifu(tx, kifu)
+		if err != nil {
+			return "Failed to create kifu", err
+		}
 
-	// メインラインの空branchを作成
-	branch := &model.KifuBranch{
-		KifuID: kifuID,
-	}
-	_, err = dao.InsertKifuBranch(branch)
+		// メインラインの空branchを作成
+		branch := &model.KifuBranch{
+			KifuID: kifuID,
+		}
+		if _, err := dao.InsertKifuBranch(tx, branch); err != nil {
+			return "Failed to create branch", err
+		}
+		if msg, err := refreshKifuIndexes(tx, kifuID); err != nil {
+			return msg, err
+		}
+
+		branches := []*model.KifuBranchWithMoves{{KifuBranch: branch, Moves: []*model.KifuMove{}}}
+		revision, err := newKifuRevision(kifu, kifu.Version, aid, model.KIFU_REVISION_CREATE, nil, nil, branches)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		return insertKifuRevisions(tx, nil, revision)
+	})
 	if err != nil {
-		return nil, "Failed to create branch", err
+		return nil, msg, err
 	}
 
 	return &kifuID, "", nil
@@ -128,48 +349,153 @@ func createKifuFromPosition(aid string, sfen *model.SFEN) (*string, string, erro
 
 // ------------------------------------------------------------
 type requestListKifus struct {
-	Owner *string `form:"owner"`
+	Owner       *string           `form:"owner"`                                                                          // meは自身の棋譜（非公開を含む）、アカウントIDはその公開棋譜
+	Keyword     string            `form:"keyword" binding:"max=100"`                                                      // タイトル・対局者・棋譜情報・タグ・コメント（空白区切りで全てを含む）
+	Tags        []string          `form:"tags" binding:"max=10,dive,min=1,max=50"`                                        // タグ（複数指定可、表記の揺れ・別名を含む）
+	TagMode     string            `form:"tag_mode" binding:"omitempty,oneof=and or"`                                      // タグの条件（省略時はand）
+	Player      *string           `form:"player" binding:"omitempty,min=1,max=100"`                                       // 先手・後手のいずれか
+	BlackPlayer *string           `form:"black_player" binding:"omitempty,min=1,max=100"`                                 // 先手
+	WhitePlayer *string           `form:"white_player" binding:"omitempty,min=1,max=100"`                                 // 後手
+	StartedFrom *string           `form:"started_from" binding:"omitempty,datetime=2006-01-02"`                           // 対局日の範囲の開始
+	StartedTo   *string           `form:"started_to" binding:"omitempty,datetime=2006-01-02"`                             // 対局日の範囲の終了（この日を含む）
+	EndingType  *model.EndingType `form:"ending_type"`                                                                    // メインラインの終局の種類
+	Handicap    *string           `form:"handicap"`                                                                       // 手合割（平手、香落ち、…）
+	Sort        model.KifuSort    `form:"sort" binding:"omitempty,oneof=updated liked commented started title relevance"` // 並び順（省略時はキーワード検索では一致の度合いの順、それ以外は更新日時の新しい順）
 }
 
-func ListKifus(c *gin.Context, req requestListKifus, pgreq *handler.PaginationRequest) (*[]*model.KifuSummaryResponse, *handler.PaginatedResponse, string, error) {
-	limit, offset := pgreq.LimitOffset()
+// 棋譜一覧のカーソルの内容（並び順と最後の棋譜の並びのキー）
+type kifuListCursor struct {
+	Sort model.KifuSort `json:"s"`
+	Keys []any          `json:"k"`
+}
 
-	// ownerに応じてKifuリストを取得
-	var totalCount int
-	var kifus []*model.Kifu
-	var err error
+// 検索条件を組み立てる
+func (req *requestListKifus) toSearchCondition(accountID string) (*dao.KifuSearchCondition, string, error) {
+	tags, msg, err := normalizeKifuTags(req.Tags)
+	if err != nil {
+		return nil, msg, err
+	}
+	cond := &dao.KifuSearchCondition{
+		Keywords:    strings.Fields(req.Keyword),
+		Tags:        tags,
+		TagMatchAll: req.TagMode != "or",
+		Player:      req.Player,
+		BlackPlayer: req.BlackPlayer,
+		WhitePlayer: req.WhitePlayer,
+		StartedFrom: req.StartedFrom,
+		EndingType:  req.EndingType,
+		Sort:        req.Sort,
+	}
+
+	// ownerに応じた対象
 	if req.Owner == nil {
-		totalCount, _ = dao.CountPublicKifus()
-		kifus, err = dao.ListPublicKifus(limit, offset) // 公開棋譜リスト
+		cond.PublicOnly = true // 公開棋譜
+	} else if *req.Owner == "me" {
+		cond.AccountID = &accountID // 自身の棋譜
 	} else {
-		if *req.Owner == "me" {
-			accountID := handler.GetActorID(c)
-			totalCount, _ = dao.CountKifusByAccountID(accountID)
-			kifus, err = dao.ListKifusByAccountID(accountID, limit, offset) // 自身の棋譜リスト
-		} else {
-			totalCount, _ = dao.CountPublicKifusByAccountID(*req.Owner)
-			kifus, err = dao.ListPublicKifusByAccountID(*req.Owner, limit, offset) // 特定アカウントの公開棋譜リスト
+		cond.AccountID = req.Owner // 特定アカウントの公開棋譜
+		cond.PublicOnly = true
+	}
+
+	if req.StartedTo != nil {
+		t, err := time.Parse("2006-01-02", *req.StartedTo)
+		if err != nil {
+			return nil, "Invalid date", err
 		}
+		before := t.AddDate(0, 0, 1).Format("2006-01-02")
+		cond.StartedBefore = &before
 	}
+	if req.Handicap != nil {
+		sfen, ok := model.HandicapNameToSFEN[*req.Handicap]
+		if !ok {
+			return nil, "Invalid handicap", fmt.Errorf("unknown handicap: %s", *req.Handicap)
+		}
+		cond.InitialPosition = &sfen
+	}
+	return cond, "", nil
+}
+
+func ListKifus(c *gin.Context, req requestListKifus, pgreq *handler.PaginationRequest) (*[]*model.KifuSummaryResponse, *handler.PaginatedResponse, string, error) {
+	limit, offset := pgreq.LimitOffset()
+
+	cond, msg, err := req.toSearchCondition(handler.GetActorID(c))
+	if err != nil {
+		return nil, nil, msg, err
+	}
+	if pgreq.UsesCursor() {
+		var cursor kifuListCursor
+		if err := pgreq.DecodeCursor(&cursor); err != nil || cursor.Sort != req.Sort || len(cursor.Keys) == 0 {
+			return nil, nil, "Invalid cursor", fmt.Errorf("invalid cursor: %v", err)
+		}
+		cond.After = cursor.Keys
+	}
+	totalCount, err := dao.CountKifusByCondition(cond)
 	if err != nil {
 		return nil, nil, "Failed to get kifu list", err
 	}
-
-	// レスポンス構築
-	responses := make([]*model.KifuSummaryResponse, 0, len(kifus))
-	for _, kifu := range kifus {
-		owner, err := dao.GetAccountByID(kifu.AccountID)
+	kifus, nextKeys, err := dao.ListKifusByCondition(cond, limit, offset)
+	if err != nil {
+		return nil, nil, "Failed to get kifu list", err
+	}
+	paginatedResponse := pgreq.NewPaginatedResponse(totalCount)
+	if nextKeys != nil {
+		nextCursor, err := handler.EncodeCursor(&kifuListCursor{Sort: req.Sort, Keys: nextKeys})
 		if err != nil {
-			return nil, nil, "Failed to get account info", err
+			return nil, nil, "Failed to get kifu list", err
 		}
-		tags, err := dao.ListKifuTagsByKifuID(kifu.ID)
+		paginatedResponse.NextCursor = &nextCursor
+	}
+
+	responses, msg, err := newKifuSummaryResponses(kifus)
+	if err != nil {
+		return nil, nil, msg, err
+	}
+
+	// キーワード検索では一致箇所の抜粋を添える
+	if len(cond.Keywords) > 0 {
+		kifuIDs := make([]string, len(kifus))
+		for i, kifu := range kifus {
+			kifuIDs[i] = kifu.ID
+		}
+		documents, err := dao.ListKifuSearchDocumentsByKifuIDs(kifuIDs)
 		if err != nil {
-			return nil, nil, "Failed to get tags", err
+			return nil, nil, "Failed to get search snippets", err
+		}
+		for _, response := range responses {
+			if document, ok := documents[response.ID]; ok {
+				response.Snippet = document.Snippet(cond.Keywords)
+			}
+		}
+	}
+	return &responses, paginatedResponse, "", nil
+}
+
+// 一覧表示用のレスポンスを構築する（所有者・タグは一覧の棋譜の分をまとめて取得する）
+func newKifuSummaryResponses(kifus []*model.Kifu) ([]*model.KifuSummaryResponse, string, error) {
+	kifuIDs := make([]string, len(kifus))
+	ownerIDs := make([]string, 0, len(kifus))
+	for i, kifu := range kifus {
+		kifuIDs[i] = kifu.ID
+		ownerIDs = append(ownerIDs, kifu.AccountID)
+	}
+	owners, err := dao.ListAccountsByIDs(ownerIDs)
+	if err != nil {
+		return nil, "Failed to get account info", err
+	}
+	tags, err := dao.ListKifuTagsByKifuIDs(kifuIDs)
+	if err != nil {
+		return nil, "Failed to get tags", err
+	}
+
+	responses := make([]*model.KifuSummaryResponse, 0, len(kifus))
+	for _, kifu := range kifus {
+		owner, ok := owners[kifu.AccountID]
+		if !ok {
+			return nil, "Failed to get account info", fmt.Errorf("account not found: %s", kifu.AccountID)
 		}
-		response := kifu.ToSummaryResponse(owner, tags)
-		responses = append(responses, response)
+		responses = append(responses, kifu.ToSummaryResponse(owner, tags[kifu.ID]))
 	}
-	return &responses, pgreq.NewPaginatedResponse(totalCount), "", nil
+	return responses, "", nil
 }
 
 // ------------------------------------------------------------
@@ -199,30 +525,134 @@ func GetKifu(c *gin.Context) (*model.KifuDetailResponse, string, error) {
 	if err != nil {
 		return nil, "Failed to get kifu tags", err
 	}
+	branchesWithMoves, msg, err := listKifuBranchesWithMoves(kifuID)
+	if err != nil {
+		return nil, msg, err
+	}
+	hasLike := false
+	if accountID != "" {
+		if like, err := dao.HasKifuLike(kifuID, accountID); err == nil {
+			hasLike = like
+		}
+	}
+
+	kifuPlayers, err := dao.ListKifuPlayersByKifuIDs([]string{kifuID})
+	if err != nil {
+		return nil, "Failed to get kifu players", err
+	}
+
+	response := kifu.ToDetailResponse(owner, options, tags, branchesWithMoves, hasLike)
+	for _, kifuPlayer := range kifuPlayers[kifuID] {
+		if kifuPlayer.Side == model.SIDE_BLACK {
+			response.BlackPlayerID = kifuPlayer.PlayerID
+		} else {
+			response.WhitePlayerID = kifuPlayer.PlayerID
+		}
+	}
+	c.Header("ETag", kifu.ETag())
+	return response, "", nil
+}
+
+func listKifuBranchesWithMoves(kifuID string) ([]*model.KifuBranchWithMoves, string, error) {
 	branches, err := dao.ListKifuBranchesByKifuID(kifuID)
 	if err != nil {
 		return nil, "Failed to get branches", err
 	}
+	annotations, err := dao.ListKifuAnnotationsByKifuID(kifuID)
+	if err != nil {
+		return nil, "Failed to get annotations", err
+	}
+	annotationMap := make(map[string][]*model.KifuAnnotation) // branchID:number -> 注釈
+	for _, annotation := range annotations {
+		key := fmt.Sprintf("%s:%d", annotation.BranchID, annotation.Number)
+		annotationMap[key] = append(annotationMap[key], annotation)
+	}
+
+	moves, err := dao.ListKifuMovesByKifuID(kifuID)
+	if err != nil {
+		return nil, "Failed to get moves", err
+	}
+	moveMap := make(map[string][]*model.KifuMove) // branchID -> 指し手（手数の順）
+	for _, move := range moves {
+		move.Annotations = annotationMap[fmt.Sprintf("%s:%d", move.BranchID, move.Number)]
+		moveMap[move.BranchID] = append(moveMap[move.BranchID], move)
+	}
+
 	branchesWithMoves := make([]*model.KifuBranchWithMoves, 0, len(branches))
 	for _, branch := range branches {
-		moves, err := dao.ListKifuMovesByBranchID(branch.ID)
-		if err != nil {
-			return nil, "Failed to get moves", err
+		branchMoves := moveMap[branch.ID]
+		if branchMoves == nil {
+			branchMoves = []*model.KifuMove{}
 		}
 		branchWithMoves := &model.KifuBranchWithMoves{
 			KifuBranch: branch,
-			Moves:      moves,
+			Moves:      branchMoves,
 		}
 		branchesWithMoves = append(branchesWithMoves, branchWithMoves)
 	}
-	hasLike := false
-	if accountID != "" {
-		if like, err := dao.HasKifuLike(kifuID, accountID); err == nil {
-			hasLike = like
+	return branchesWithMoves, "", nil
+}
+
+// ------------------------------------------------------------
+type KifuPositionResponse struct {
+	BranchID string     `json:"branch_id"`
+	Number   int64      `json:"number"` // 何手目の局面か（0は開始局面）
+	SFEN     model.SFEN `json:"sfen"`
+	BOD      string     `json:"bod"` // 掲示板などに貼り付ける局面図
+}
+
+// クエリ: number（省略時は0）、branch_id（省略時はメインライン）
+func GetKifuPosition(c *gin.Context) (*KifuPositionResponse, string, error) {
+	accountID := handler.GetActorID(c)
+	kifuID := c.GetString("kifuID")
+
+	number, err := strconv.ParseInt(c.DefaultQuery("number", "0"), 10, 64)
+	if err != nil || number < 0 {
+		return nil, "Invalid move number", fmt.Errorf("invalid number: %s", c.Query("number"))
+	}
+
+	kifu, err := dao.GetKifu(kifuID)
+	if err != nil {
+		return nil, "Failed to get kifu", err
+	}
+
+	// 非公開の棋譜は所有者のみアクセス可能
+	if !kifu.IsPublic && (accountID != kifu.AccountID) {
+		return nil, "Access denied", fmt.Errorf("unauthorized acces to private kifu")
+	}
+
+	branchesWithMoves, msg, err := listKifuBranchesWithMoves(kifuID)
+	if err != nil {
+		return nil, msg, err
+	}
+	branchID := c.Query("branch_id")
+	if branchID == "" {
+		for _, branch := range branchesWithMoves {
+			if branch.RootBranchID == nil {
+				branchID = branch.ID
+			}
 		}
 	}
 
-	response := kifu.ToDetailResponse(owner, options, tags, branchesWithMoves, hasLike)
+	position, err := kifu.PositionAt(branchesWithMoves, branchID, number)
+	if err != nil {
+		return nil, "Invalid move number", err
+	}
+	sfen, err := position.ToSFEN(int(number) + 1)
+	if err != nil {
+		return nil, "Failed to convert position", err
+	}
+	bod, err := position.ToBOD()
+	if err != nil {
+		return nil, "Failed to convert position", err
+	}
+
+	response := &KifuPositionResponse{
+		BranchID: branchID,
+		Number:   number,
+		SFEN:     sfen,
+		BOD:      bod,
+	}
 	return response, "", nil
 }
 
@@ -245,6 +675,10 @@ func DeleteKifu(c *gin.Context) (string, error) {
 	if err != nil {
 		return "Failed to delete kifu", err
 	}
+	// 削除した棋譜の対局者の成績を集計し直す（失敗しても次の読み込み時に集計し直す）
+	if _, err := refreshStalePlayerStats(); err != nil {
+		slog.Warn("Failed to refresh player stats", "kifuID", kifuID, "error", err)
+	}
 
 	return "", nil
 }
@@ -255,6 +689,7 @@ type requestUpdateKifuInfo struct {
 	IsPublic bool           `json:"is_public"`
 	GameInfo model.GameInfo `json:"game_info"` // 対局情報（black_player, white_player, started_at, time_rule, その他オプション）
 	Tags     []string       `json:"tags"`      // タグリスト
+	Version  *int64         `json:"version"`   // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
 }
 
 func UpdateKifuInfo(c *gin.Context, req requestUpdateKifuInfo) (string, error) {
@@ -269,8 +704,23 @@ func UpdateKifuInfo(c *gin.Context, req requestUpdateKifuInfo) (string, error) {
 	if kifu.AccountID != accountID {
 		return "Access denied", fmt.Errorf("unauthorized access")
 	}
+	version, msg, err := requestedKifuVersion(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+
+	// 変更前の状態（履歴の記録に使用）
+	currentOptions, currentTags, branches, msg, err := loadKifuContents(kifuID)
+	if err != nil {
+		return msg, err
+	}
+	baseline, msg, err := newKifuBaselineRevision(kifu, currentOptions, currentTags, branches)
+	if err != nil {
+		return msg, err
+	}
 
 	// Kifu更新
+	published := !kifu.IsPublic && req.IsPublic // 非公開から公開に変わる
 	kifu.Title = req.Title
 	kifu.IsPublic = req.IsPublic
 	kifu.BlackPlayer = req.GameInfo.GetBlackPlayer()
@@ -278,16 +728,6 @@ func UpdateKifuInfo(c *gin.Context, req requestUpdateKifuInfo) (string, error) {
 	kifu.StartedAt = req.GameInfo.GetStartedAt()
 	kifu.TimeRule = req.GameInfo.GetTimeRule()
 
-	err = dao.UpdateKifu(kifu)
-	if err != nil {
-		return "Failed to update kifu", err
-	}
-
-	// KifuOption更新
-	if err := dao.ClearKifuOptionsByKifuID(kifuID); err != nil {
-		return "Failed to clear existing kifu options", err
-	}
-
 	reservedKeys := []string{"先手", "後手", "対局日時", "持ち時間", "秒読み", "秒加算"}
 	options := make([]*model.KifuOption, 0, len(req.GameInfo))
 	for k, v := range req.GameInfo {
@@ -300,45 +740,117 @@ func UpdateKifuInfo(c *gin.Context, req requestUpdateKifuInfo) (string, error) {
 			Value:  v,
 		})
 	}
-	if err := dao.InsertKifuOptions(options); err != nil {
-		return "Failed to insert kifu options", err
-	}
 
-	// KifuTag更新
-	if err := dao.ClearKifuTagsByKifuID(kifuID); err != nil {
-		return "Failed to clear existing kifu tags", err
+	tagNames, msg, err := normalizeKifuTags(req.Tags)
+	if err != nil {
+		return msg, err
 	}
-
-	tags := make([]*model.KifuTag, 0, len(req.Tags))
-	for _, name := range req.Tags {
+	tags := make([]*model.KifuTag, 0, len(tagNames))
+	for _, name := range tagNames {
 		tags = append(tags, &model.KifuTag{
 			KifuID: kifuID,
 			Name:   name,
 		})
 	}
-	if err := dao.InsertKifuTags(tags); err != nil {
-		return "Failed to insert kifu tags", err
+
+	// Kifu・KifuOption・KifuTagをまとめて更新（版が古い場合は更新しない）
+	msg, err = updateKifuWithVersion(c, kifu, version, func(tx *db.Tx) (string, error) {
+		if err := dao.UpdateKifu(tx, kifu); err != nil {
+			return "Failed to update kifu", err
+		}
+
+		// KifuOption更新
+		if err := dao.ClearKifuOptionsByKifuID(tx, kifuID); err != nil {
+			return "Failed to clear existing kifu options", err
+		}
+		if err := dao.InsertKifuOptions(tx, options); err != nil {
+			return "Failed to insert kifu options", err
+		}
+
+		// KifuTag更新
+		if err := dao.ClearKifuTagsByKifuID(tx, kifuID); err != nil {
+			return "Failed to clear existing kifu tags", err
+		}
+		if err := dao.InsertKifuTags(tx, tags); err != nil {
+			return "Failed to insert kifu tags", err
+		}
+
+		revision, err := newKifuRevision(kifu, version+1, accountID, model.KIFU_REVISION_UPDATE_INFO, options, tags, branches)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		return insertKifuRevisions(tx, baseline, revision)
+	})
+	if err != nil {
+		return msg, err
 	}
 
+	// 公開した棋譜を保存された検索条件と照合する
+	if published {
+		go notifySavedSearches(kifuID)
+	}
 	return "", nil
 }
 
 // ------------------------------------------------------------
 type KifuMoveRequest struct {
-	Number        int64                  `json:"number"`                   // 何手目か（分岐の場合も初手からカウント）
-	Piece         model.PieceType        `json:"piece"`                    // 動いた元の駒種
-	FromPlace     model.PiecePlace       `json:"from_place"`               // 移動元の場所
-	ToPlace       model.PiecePlace       `json:"to_place"`                 // 移動先の場所
-	Promote       *bool                  `json:"promote,omitempty"`        // 成ったか（成らなければNULL）
-	CatchPiece    *model.PieceType       `json:"catch_piece,omitempty"`    // 取った駒種（取ってなければNULL）
-	DirectionSign *string                `json:"direction_sign,omitempty"` // 方向の符号（無ければNULL）
-	Variations    *[]KifuMoveLineRequest `json:"variations,omitempty"`     // この手に変わる分岐
-	Comment       *string                `json:"comment"`                  // コメント
-	TimeSpentMs   *int64                 `json:"time_spent_ms"`            // 消費時間（ミリ秒）
+	Number           int64                    `json:"number"`                      // 何手目か（分岐の場合も初手からカウント）
+	Piece            model.PieceType          `json:"piece"`                       // 動いた元の駒種
+	FromPlace        model.PiecePlace         `json:"from_place"`                  // 移動元の場所
+	ToPlace          model.PiecePlace         `json:"to_place"`                    // 移動先の場所
+	Promote          *bool                    `json:"promote,omitempty"`           // 成ったか（成らなければNULL）
+	CatchPiece       *model.PieceType         `json:"catch_piece,omitempty"`       // 取った駒種（取ってなければNULL）
+	DirectionSign    *string                  `json:"direction_sign,omitempty"`    // 方向の符号（無ければNULL）
+	Variations       *[]KifuMoveLineRequest   `json:"variations,omitempty"`        // この手に変わる分岐
+	VariationEndings *[]*KifuEndingRequest    `json:"variation_endings,omitempty"` // 分岐ごとの終局（variationsと同じ順、終局の無い分岐はnull）
+	Comment          *string                  `json:"comment"`                     // コメント
+	TimeSpentMs      *int64                   `json:"time_spent_ms"`               // 消費時間（ミリ秒）
+	Annotations      []*KifuAnnotationRequest `json:"annotations,omitempty"`       // 矢印・マスの強調・評価記号
 }
 
 type KifuMoveLineRequest []*KifuMoveRequest
 
+type KifuEndingRequest struct {
+	Type    model.EndingType `json:"type"`    // 終局の種類
+	Comment *string          `json:"comment"` // 終局時のコメント
+}
+
+// ラインの終局をブランチに設定する（終局の番号はラインの最終手の次、指し手の無いラインは分岐元の次）
+func (ending *KifuEndingRequest) applyTo(branch *model.KifuBranchWithMoves) {
+	if ending == nil {
+		return
+	}
+	endingNumber := branch.LastNumber() + 1
+	endingType := ending.Type
+	branch.EndingNumber = &endingNumber
+	branch.EndingType = &endingType
+	branch.EndingComment = ending.Comment
+}
+
+type KifuAnnotationRequest struct {
+	Type      model.KifuAnnotationType `json:"type"`       // mark／arrow／highlight
+	FromPlace *model.PiecePlace        `json:"from_place"` // 矢印の始点
+	ToPlace   *model.PiecePlace        `json:"to_place"`   // 矢印の終点、強調するマス
+	Mark      *string                  `json:"mark"`       // 評価記号（!!、!、!?、?!、?、??）
+	Color     *string                  `json:"color"`      // 矢印・強調の色（省略時は既定の色）
+}
+
+func toKifuAnnotations(annotations []*KifuAnnotationRequest, branchID string, number int64) []*model.KifuAnnotation {
+	result := make([]*model.KifuAnnotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		result = append(result, &model.KifuAnnotation{
+			BranchID:  branchID,
+			Number:    number,
+			Type:      annotation.Type,
+			FromPlace: annotation.FromPlace,
+			ToPlace:   annotation.ToPlace,
+			Mark:      annotation.Mark,
+			Color:     annotation.Color,
+		})
+	}
+	return result
+}
+
 func (move *KifuMoveRequest) ToKifuMove(branchID string) *model.KifuMove {
 	return &model.KifuMove{
 		BranchID:    branchID,
@@ -348,11 +860,14 @@ func (move *KifuMoveRequest) ToKifuMove(branchID string) *model.KifuMove {
 		ToPlace:     move.ToPlace,
 		Comment:     move.Comment,
 		TimeSpentMs: move.TimeSpentMs,
+		Annotations: toKifuAnnotations(move.Annotations, branchID, move.Number),
 	}
 }
 
 type requestUpdateKifuMoves struct {
-	Moves KifuMoveLineRequest `json:"moves"` // メインラインと分岐を含む指し手情報
+	Moves   KifuMoveLineRequest `json:"moves"`   // メインラインと分岐を含む指し手情報
+	Ending  *KifuEndingRequest  `json:"ending"`  // メインラインの終局（指し手の無いラインにも指定できる）
+	Version *int64              `json:"version"` // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
 }
 
 func UpdateKifuMoves(c *gin.Context, req requestUpdateKifuMoves) (string, error) {
@@ -368,9 +883,19 @@ func UpdateKifuMoves(c *gin.Context, req requestUpdateKifuMoves) (string, error)
 		return "Access denied", fmt.Errorf("unauthorized access")
 	}
 
-	// 既存データの削除
-	if err := dao.ClearKifuBranchesByKifuID(kifuID); err != nil {
-		return "Failed to clear existing branches", err
+	version, msg, err := requestedKifuVersion(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+
+	// 変更前の状態（履歴の記録に使用）
+	options, tags, currentBranches, msg, err := loadKifuContents(kifuID)
+	if err != nil {
+		return msg, err
+	}
+	baseline, msg, err := newKifuBaselineRevision(kifu, options, tags, currentBranches)
+	if err != nil {
+		return msg, err
 	}
 
 	// 初期局面の生成（整合性チェックに使用）
@@ -379,56 +904,98 @@ func UpdateKifuMoves(c *gin.Context, req requestUpdateKifuMoves) (string, error)
 		return "Invalid initial position", err
 	}
 
-	// ブランチの保存（IDの取得）と指し手の生成のための全体リスト
-	branchWithMovesList := []*model.KifuBranchWithMoves{}
+	// 既存データの削除から保存までをまとめて実行する（不正な指し手や古い版の場合は元の指し手を残す）
+	return updateKifuWithVersion(c, kifu, version, func(tx *db.Tx) (string, error) {
+		// 既存データの削除
+		if err := dao.ClearKifuBranchesByKifuID(tx, kifuID); err != nil {
+			return "Failed to clear existing branches", err
+		}
 
-	// メインブランチを保存して全体リストに追加
-	mainBranch := &model.KifuBranch{KifuID: kifuID}
-	mainBranchID, err := dao.InsertKifuBranch(mainBranch)
-	if err != nil {
-		return "Failed to insert kifu branch", err
-	}
-	mainBranch.ID = mainBranchID
-	mainBranchWithMoves := &model.KifuBranchWithMoves{
-		KifuBranch: mainBranch,
-		Moves:      []*model.KifuMove{},
-	}
-	branchWithMovesList = append(branchWithMovesList, mainBranchWithMoves)
+		// ブランチの保存（IDの取得）と指し手の生成のための全体リスト
+		branchWithMovesList := []*model.KifuBranchWithMoves{}
 
-	// メインブランチから再帰的にブランチの保存と指し手の生成
-	if msg, err := createBranchWithMovesRecursive(kifuID, &branchWithMovesList, req.Moves, mainBranchWithMoves, position); err != nil {
-		return msg, err
-	}
+		// メインブランチを保存して全体リストに追加
+		mainBranch := &model.KifuBranch{KifuID: kifuID}
+		mainBranchID, err := dao.InsertKifuBranch(tx, mainBranch)
+		if err != nil {
+			return "Failed to insert kifu branch", err
+		}
+		mainBranch.ID = mainBranchID
+		mainBranchWithMoves := &model.KifuBranchWithMoves{
+			KifuBranch: mainBranch,
+			Moves:      []*model.KifuMove{},
+		}
+		branchWithMovesList = append(branchWithMovesList, mainBranchWithMoves)
 
-	// 指し手の保存
-	for _, branchWithMoves := range branchWithMovesList {
-		if err := dao.InsertKifuMoves(branchWithMoves.Moves); err != nil {
-			return "Failed to insert branch", err
+		// メインブランチから再帰的にブランチの保存と指し手の生成
+		if msg, err := createBranchWithMovesRecursive(tx, kifuID, &branchWithMovesList, req.Moves, mainBranchWithMoves, position); err != nil {
+			return msg, err
 		}
-	}
+		req.Ending.applyTo(mainBranchWithMoves)
 
-	return "", nil
+		// 指し手と終局情報の保存
+		for _, branchWithMoves := range branchWithMovesList {
+			if err := insertKifuMoves(tx, branchWithMoves.Moves); err != nil {
+				return "Failed to insert branch", err
+			}
+			if branchWithMoves.EndingType == nil {
+				continue
+			}
+			if err := kifu.ValidateEnding(branchWithMovesList, branchWithMoves.ID, *branchWithMoves.EndingType); err != nil {
+				return "Invalid ending", err
+			}
+			if err := dao.UpdateKifuBranchEnding(tx, branchWithMoves.KifuBranch); err != nil {
+				return "Failed to update branch ending", err
+			}
+		}
+
+		// メインラインの変更をフィンガープリントに反映
+		fingerprint, err := model.NewKifuFingerprint(kifu.InitialPosition, mainBranchWithMoves.Moves)
+		if err != nil {
+			return "Invalid initial position", err
+		}
+		if err := dao.UpdateKifuFingerprint(tx, kifuID, fingerprint); err != nil {
+			return "Failed to update kifu fingerprint", err
+		}
+
+		revision, err := newKifuRevision(kifu, version+1, accountID, model.KIFU_REVISION_UPDATE_MOVES, options, tags, branchWithMovesList)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		return insertKifuRevisions(tx, baseline, revision)
+	})
 }
 
 // ブランチに対する指し手生成の再帰処理
-func createBranchWithMovesRecursive(kifuID string, branchWithMovesList *[]*model.KifuBranchWithMoves, moves KifuMoveLineRequest, currentBranchWithMoves *model.KifuBranchWithMoves, position *model.BoardPosition) (string, error) {
+func createBranchWithMovesRecursive(tx *db.Tx, kifuID string, branchWithMovesList *[]*model.KifuBranchWithMoves, moves KifuMoveLineRequest, currentBranchWithMoves *model.KifuBranchWithMoves, position *model.BoardPosition) (string, error) {
 	for _, move := range moves {
 		// 指し手の整合性チェック
 		kifuMove := move.ToKifuMove(currentBranchWithMoves.ID)
 		if err := position.Move(kifuMove); err != nil {
 			return "Invalid move", err
 		}
+		if err := model.ValidateKifuAnnotations(kifuMove.Annotations); err != nil {
+			return "Invalid annotation", err
+		}
 
 		// 分岐の処理
 		if move.Variations != nil {
-			for _, variation := range *move.Variations {
+			var endings []*KifuEndingRequest
+			if move.VariationEndings != nil {
+				endings = *move.VariationEndings
+				if len(endings) != len(*move.Variations) {
+					return "BAD_REQUEST - variation_endings must match variations", fmt.Errorf("variation endings on move %d: %d, variations: %d", move.Number, len(endings), len(*move.Variations))
+				}
+			}
+			for i, variation := range *move.Variations {
 				// variationに対してブランチを作成し、再帰処理を呼び出す
 				newBranch := &model.KifuBranch{
 					KifuID:       kifuID,
 					RootBranchID: &currentBranchWithMoves.ID,
 					RootNumber:   &move.Number,
+					SortOrder:    int64(i),
 				}
-				newBranchID, err := dao.InsertKifuBranch(newBranch)
+				newBranchID, err := dao.InsertKifuBranch(tx, newBranch)
 				if err != nil {
 					return "Failed to insert kifu branch", err
 				}
@@ -440,10 +1007,15 @@ func createBranchWithMovesRecursive(kifuID string, branchWithMovesList *[]*model
 				(*branchWithMovesList) = append((*branchWithMovesList), newBranchWithMoves) // 全体リストに新ブランチを追加
 
 				// 再帰呼び出し
-				if msg, err := createBranchWithMovesRecursive(kifuID, branchWithMovesList, variation, newBranchWithMoves, position.Copy()); err != nil {
+				if msg, err := createBranchWithMovesRecursive(tx, kifuID, branchWithMovesList, variation, newBranchWithMoves, position.Copy()); err != nil {
 					return msg, err
 				}
+				if endings != nil {
+					endings[i].applyTo(newBranchWithMoves)
+				}
 			}
+		} else if move.VariationEndings != nil && len(*move.VariationEndings) > 0 {
+			return "BAD_REQUEST - variation_endings must match variations", fmt.Errorf("variation endings without variations on move %d", move.Number)
 		}
 
 		// 指し手の追加
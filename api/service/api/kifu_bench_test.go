@@ -0,0 +1,195 @@
+// service/api/kifu_bench_test.go
+
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"modernc.org/sqlite"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/api/parser"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// クエリ数を数えるSQLiteドライバ（ExecContext・QueryContextの呼び出しを1クエリとする）
+const countingDriverName = "sqlite-counting"
+
+var (
+	queryCount         atomic.Int64
+	countingDriverOnce sync.Once
+)
+
+type countingDriver struct {
+	driver.Driver
+}
+
+func (d countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	queryCount.Add(1)
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryCount.Add(1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+// 一時ファイルのDBに公開棋譜・タグ・分岐・コメントを用意する
+type queryBenchFixture struct {
+	router *gin.Engine
+	kifuID string
+}
+
+const queryBenchKifuCount = 16
+const queryBenchCommenterCount = 10
+
+// 1.76歩 2.34歩 3.26歩（変化 3.22角成 4.同銀 （変化 4.同飛））
+const queryBenchJKF = `{"header":{"先手":"先手%d","後手":"後手%d"},"moves":[{},
+	{"move":{"color":0,"from":{"x":7,"y":7},"to":{"x":7,"y":6},"piece":"FU"}},
+	{"move":{"color":1,"from":{"x":3,"y":3},"to":{"x":3,"y":4},"piece":"FU"}},
+	{"move":{"color":0,"from":{"x":2,"y":7},"to":{"x":2,"y":6},"piece":"FU"},"forks":[[
+		{"move":{"color":0,"from":{"x":8,"y":8},"to":{"x":2,"y":2},"piece":"KA","promote":true}},
+		{"move":{"color":1,"from":{"x":3,"y":1},"to":{"x":2,"y":2},"piece":"GI"},"forks":[[
+			{"move":{"color":1,"from":{"x":8,"y":2},"to":{"x":2,"y":2},"piece":"HI"}}
+		]]}
+	]]}
+]}`
+
+func setupQueryBench(b *testing.B) *queryBenchFixture {
+	b.Helper()
+	countingDriverOnce.Do(func() {
+		sql.Register(countingDriverName, countingDriver{Driver: &sqlite.Driver{}})
+	})
+	if err := db.Open(countingDriverName, filepath.Join(b.TempDir(), "bench.db")); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(db.Close)
+	SetupTables()
+
+	accountIDs := []string{}
+	for i := 0; i < queryBenchCommenterCount; i++ {
+		accountID, err := dao.InsertAccount(&model.Account{
+			Name:  fmt.Sprintf("account%d", i),
+			Email: fmt.Sprintf("account%d@example.com", i),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		accountIDs = append(accountIDs, accountID)
+	}
+
+	kifuIDs := []string{}
+	for i := 0; i < queryBenchKifuCount; i++ {
+		parsedKifu, err := parser.ParseFromJKF(fmt.Sprintf(queryBenchJKF, i, i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		// 保存された検索条件との照合（非同期）を避けるため、非公開で作成してから公開する
+		parsedKifu.Kifu.AccountID = accountIDs[i%len(accountIDs)]
+		parsedKifu.Kifu.IsPublic = false
+		kifuID, _, err := createKifuFromParsedKifu(parsedKifu)
+		if err != nil {
+			b.Fatal(err)
+		}
+		kifuIDs = append(kifuIDs, *kifuID)
+	}
+	if _, err := db.Exec(`UPDATE kifus SET is_public = true`); err != nil {
+		b.Fatal(err)
+	}
+
+	msg, err := inTransaction(func(tx *db.Tx) (string, error) {
+		for i, kifuID := range kifuIDs {
+			tags := []*model.KifuTag{
+				{KifuID: kifuID, Name: "居飛車"},
+				{KifuID: kifuID, Name: "角換わり"},
+				{KifuID: kifuID, Name: fmt.Sprintf("研究%d", i)},
+			}
+			if err := dao.InsertKifuTags(tx, tags); err != nil {
+				return "Failed to insert kifu tags", err
+			}
+		}
+		for i, accountID := range accountIDs {
+			comment := &model.KifuComment{
+				KifuID:    kifuIDs[0],
+				AccountID: accountID,
+				Content:   fmt.Sprintf("コメント%d", i),
+			}
+			if _, err := dao.InsertKifuComment(tx, comment); err != nil {
+				return "Failed to insert kifu comment", err
+			}
+			if err := dao.IncrementKifuCommentCount(tx, kifuIDs[0]); err != nil {
+				return "Failed to update kifu comment count", err
+			}
+		}
+		return "", nil
+	})
+	if err != nil {
+		b.Fatal(msg, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	rPub := router.Group("/api", handler.MwStorePathIDs)
+	rPub.GET("/kifu", handler.HandlerInPagination(ListKifus))
+	rPub.GET("/kifu/:kifuID", handler.HandlerOut(GetKifu))
+	rPub.GET("/kifu/:kifuID/comments", handler.HandlerOut(ListKifuComments))
+
+	return &queryBenchFixture{router: router, kifuID: kifuIDs[0]}
+}
+
+// リクエストあたりのクエリ数（queries/op）を計測する
+func BenchmarkKifuRequestQueries(b *testing.B) {
+	fixture := setupQueryBench(b)
+
+	benchmarks := []struct {
+		name string
+		path string
+	}{
+		{name: "ListKifus", path: "/api/kifu"},
+		{name: "GetKifu", path: "/api/kifu/" + fixture.kifuID},
+		{name: "ListKifuComments", path: "/api/kifu/" + fixture.kifuID + "/comments"},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			queryCount.Store(0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, bm.path, nil)
+				fixture.router.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					b.Fatalf("GET %s = %d: %s", bm.path, w.Code, w.Body.String())
+				}
+			}
+			b.ReportMetric(float64(queryCount.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,526 @@
+// service/api/kifu_branch.go
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 分岐単位の編集（ブランチIDを変えずに、変更のあった行だけを更新する）
+type kifuBranchEdit struct {
+	kifu     *model.Kifu
+	version  int64
+	options  []*model.KifuOption
+	tags     []*model.KifuTag
+	branches []*model.KifuBranchWithMoves // 編集後の状態に合わせて更新する（履歴の記録に使用）
+	branch   *model.KifuBranchWithMoves   // 操作対象のブランチ
+	baseline *model.KifuRevision
+}
+
+// 所有者チェック・版の確認・操作対象ブランチの取得
+func loadKifuBranchEdit(c *gin.Context, reqVersion *int64) (*kifuBranchEdit, string, error) {
+	kifu, msg, err := getOwnKifu(c)
+	if err != nil {
+		return nil, msg, err
+	}
+	version, msg, err := requestedKifuVersion(c, reqVersion)
+	if err != nil {
+		return nil, msg, err
+	}
+
+	options, tags, branches, msg, err := loadKifuContents(kifu.ID)
+	if err != nil {
+		return nil, msg, err
+	}
+	branchID := c.GetString("branchID")
+	branch := model.FindBranch(branches, branchID)
+	if branch == nil {
+		return nil, "Branch not found", fmt.Errorf("branch not found: %s", branchID)
+	}
+	baseline, msg, err := newKifuBaselineRevision(kifu, options, tags, branches)
+	if err != nil {
+		return nil, msg, err
+	}
+
+	edit := &kifuBranchEdit{
+		kifu:     kifu,
+		version:  version,
+		options:  options,
+		tags:     tags,
+		branches: branches,
+		branch:   branch,
+		baseline: baseline,
+	}
+	return edit, "", nil
+}
+
+// fで変更を保存し、フィンガープリントと履歴を更新する（版が古い場合は何もしない）
+func (e *kifuBranchEdit) save(c *gin.Context, f func(tx *db.Tx) (string, error)) (string, error) {
+	accountID := handler.GetActorID(c)
+	var mainMoves []*model.KifuMove
+	if mainBranch := model.MainBranch(e.branches); mainBranch != nil {
+		mainMoves = mainBranch.Moves
+	}
+	fingerprint, err := model.NewKifuFingerprint(e.kifu.InitialPosition, mainMoves)
+	if err != nil {
+		return "Invalid initial position", err
+	}
+
+	return updateKifuWithVersion(c, e.kifu, e.version, func(tx *db.Tx) (string, error) {
+		if msg, err := f(tx); err != nil {
+			return msg, err
+		}
+		if err := dao.UpdateKifuFingerprint(tx, e.kifu.ID, fingerprint); err != nil {
+			return "Failed to update kifu fingerprint", err
+		}
+
+		revision, err := newKifuRevision(e.kifu, e.version+1, accountID, model.KIFU_REVISION_EDIT_BRANCH, e.options, e.tags, e.branches)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		return insertKifuRevisions(tx, e.baseline, revision)
+	})
+}
+
+// 指し手リストの整合性チェック（positionはfirstNumberの直前の局面、分岐は指定不可）
+func validateBranchMoves(position *model.BoardPosition, moves KifuMoveLineRequest, branchID string, firstNumber int64) ([]*model.KifuMove, string, error) {
+	kifuMoves := make([]*model.KifuMove, 0, len(moves))
+	for i, move := range moves {
+		if move.Variations != nil || move.VariationEndings != nil {
+			return nil, "Variations are not allowed here", fmt.Errorf("variations in branch moves")
+		}
+		if move.Number != firstNumber+int64(i) {
+			return nil, "Invalid move number", fmt.Errorf("expected move number %d, got %d", firstNumber+int64(i), move.Number)
+		}
+		kifuMove := move.ToKifuMove(branchID)
+		if err := position.Move(kifuMove); err != nil {
+			return nil, "Invalid move", err
+		}
+		if err := model.ValidateKifuAnnotations(kifuMove.Annotations); err != nil {
+			return nil, "Invalid annotation", err
+		}
+		kifuMoves = append(kifuMoves, kifuMove)
+	}
+	return kifuMoves, "", nil
+}
+
+// DELETEリクエスト用（If-Matchヘッダーが無い場合はクエリのversionを使う）
+func queryKifuVersion(c *gin.Context) *int64 {
+	version, err := strconv.ParseInt(c.Query("version"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &version
+}
+
+// ------------------------------------------------------------
+type requestAppendKifuMoves struct {
+	Moves   KifuMoveLineRequest `json:"moves" binding:"required,min=1"` // 最終手に続ける指し手（分岐は指定不可）
+	Version *int64              `json:"version"`                        // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// ブランチの最終手の後に指し手を追加する（終局情報は削除する）
+func AppendKifuMoves(c *gin.Context, req requestAppendKifuMoves) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+	branch := edit.branch
+
+	// 対象ブランチまでの経路のみを再生して整合性をチェック
+	lastNumber := branch.LastNumber()
+	position, err := edit.kifu.PositionAt(edit.branches, branch.ID, lastNumber)
+	if err != nil {
+		return "Invalid branch", err
+	}
+	moves, msg, err := validateBranchMoves(position, req.Moves, branch.ID, lastNumber+1)
+	if err != nil {
+		return msg, err
+	}
+	branch.Moves = append(branch.Moves, moves...)
+	clearEnding := branch.EndingType != nil || branch.EndingNumber != nil || branch.EndingComment != nil
+	branch.EndingNumber, branch.EndingType, branch.EndingComment = nil, nil, nil
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		if err := insertKifuMoves(tx, moves); err != nil {
+			return "Failed to insert kifu moves", err
+		}
+		if clearEnding {
+			if err := dao.UpdateKifuBranchEnding(tx, branch.KifuBranch); err != nil {
+				return "Failed to update branch ending", err
+			}
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+type requestTruncateKifuBranch struct {
+	Number  int64  `json:"number" binding:"min=0"` // この手数より後の指し手を削除する
+	Version *int64 `json:"version"`                // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// number手目より後の指し手と、そこから分岐するブランチを削除する
+func TruncateKifuBranch(c *gin.Context, req requestTruncateKifuBranch) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+	branch := edit.branch
+
+	rootNumber := int64(0)
+	if branch.RootNumber != nil {
+		rootNumber = *branch.RootNumber
+	}
+	if req.Number < rootNumber || req.Number > branch.LastNumber() {
+		return "Invalid move number", fmt.Errorf("move number out of range: %d", req.Number)
+	}
+
+	removedIDs := model.DescendantBranchIDs(edit.branches, branch.ID, req.Number)
+	edit.branches = model.RemoveBranches(edit.branches, removedIDs)
+	branch.Moves = branch.Moves[:req.Number-rootNumber]
+	clearEnding := branch.EndingNumber != nil && *branch.EndingNumber > req.Number+1
+	if clearEnding {
+		branch.EndingNumber, branch.EndingType, branch.EndingComment = nil, nil, nil
+	}
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		if err := dao.DeleteKifuBranches(tx, edit.kifu.ID, removedIDs); err != nil {
+			return "Failed to delete kifu branches", err
+		}
+		if err := dao.DeleteKifuMovesAfter(tx, branch.ID, req.Number); err != nil {
+			return "Failed to delete kifu moves", err
+		}
+		if clearEnding {
+			if err := dao.UpdateKifuBranchEnding(tx, branch.KifuBranch); err != nil {
+				return "Failed to update branch ending", err
+			}
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+type requestAddKifuVariation struct {
+	RootNumber int64               `json:"root_number" binding:"min=1"`    // 分岐元の手数（分岐の初手はroot_number+1手目）
+	Moves      KifuMoveLineRequest `json:"moves" binding:"required,min=1"` // 分岐の指し手（入れ子の分岐は指定不可）
+	Version    *int64              `json:"version"`                        // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+type AddKifuVariationResponse struct {
+	BranchID string `json:"branch_id"` // 作成した分岐のID
+}
+
+// ブランチのroot_number手目の局面から分岐を追加する
+func AddKifuVariation(c *gin.Context, req requestAddKifuVariation) (*AddKifuVariationResponse, string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return nil, msg, err
+	}
+
+	// 分岐元はブランチ自身の指し手（メインラインの開始局面・分岐の分岐元の手からは表示できない）
+	firstNumber := int64(1)
+	if edit.branch.RootNumber != nil {
+		firstNumber = *edit.branch.RootNumber + 1
+	}
+	if req.RootNumber < firstNumber {
+		return nil, "BAD_REQUEST - Invalid move number", fmt.Errorf("root number must be a move of the branch: %d (first %d)", req.RootNumber, firstNumber)
+	}
+	position, err := edit.kifu.PositionAt(edit.branches, edit.branch.ID, req.RootNumber)
+	if err != nil {
+		return nil, "Invalid move number", err
+	}
+	newBranch := &model.KifuBranch{
+		KifuID:       edit.kifu.ID,
+		RootBranchID: &edit.branch.ID,
+		RootNumber:   &req.RootNumber,
+	}
+	if siblings := model.SiblingBranches(edit.branches, edit.branch.ID, req.RootNumber); len(siblings) > 0 {
+		newBranch.SortOrder = siblings[len(siblings)-1].SortOrder + 1 // 既存の分岐の後に追加
+	}
+
+	msg, err = edit.save(c, func(tx *db.Tx) (string, error) {
+		branchID, err := dao.InsertKifuBranch(tx, newBranch)
+		if err != nil {
+			return "Failed to insert kifu branch", err
+		}
+		moves, msg, err := validateBranchMoves(position, req.Moves, branchID, req.RootNumber+1)
+		if err != nil {
+			return msg, err
+		}
+		if err := insertKifuMoves(tx, moves); err != nil {
+			return "Failed to insert kifu moves", err
+		}
+		edit.branches = append(edit.branches, &model.KifuBranchWithMoves{KifuBranch: newBranch, Moves: moves})
+		return "", nil
+	})
+	if err != nil {
+		return nil, msg, err
+	}
+	return &AddKifuVariationResponse{BranchID: newBranch.ID}, "", nil
+}
+
+// ------------------------------------------------------------
+// 分岐とその子孫のブランチを削除する（メインラインは削除不可）
+func DeleteKifuVariation(c *gin.Context) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, queryKifuVersion(c))
+	if err != nil {
+		return msg, err
+	}
+	if edit.branch.RootBranchID == nil {
+		return "Main branch cannot be deleted", fmt.Errorf("attempt to delete main branch")
+	}
+
+	removedIDs := append([]string{edit.branch.ID}, model.DescendantBranchIDs(edit.branches, edit.branch.ID, -1)...)
+	edit.branches = model.RemoveBranches(edit.branches, removedIDs)
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		if err := dao.DeleteKifuBranches(tx, edit.kifu.ID, removedIDs); err != nil {
+			return "Failed to delete kifu branches", err
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+type requestUpdateKifuMove struct {
+	Comment     *string                   `json:"comment"`       // コメント（NULLで削除）
+	TimeSpentMs *int64                    `json:"time_spent_ms"` // 消費時間（ミリ秒、NULLで削除）
+	Annotations *[]*KifuAnnotationRequest `json:"annotations"`   // 注釈（省略時は変更しない、空配列で削除）
+	Version     *int64                    `json:"version"`       // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// 1手のコメント・消費時間・注釈を更新する
+func UpdateKifuMove(c *gin.Context, req requestUpdateKifuMove) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+	number, err := strconv.ParseInt(c.GetString("number"), 10, 64)
+	if err != nil {
+		return "Invalid move number", err
+	}
+	move := edit.branch.MoveAt(number)
+	if move == nil {
+		return "Move not found", fmt.Errorf("move not found: %d", number)
+	}
+	if req.TimeSpentMs != nil && *req.TimeSpentMs < 0 {
+		return "Invalid time spent", fmt.Errorf("negative time spent: %d", *req.TimeSpentMs)
+	}
+	move.Comment = req.Comment
+	move.TimeSpentMs = req.TimeSpentMs
+	if req.Annotations != nil {
+		move.Annotations = toKifuAnnotations(*req.Annotations, move.BranchID, move.Number)
+		if err := model.ValidateKifuAnnotations(move.Annotations); err != nil {
+			return "Invalid annotation", err
+		}
+	}
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		if err := dao.UpdateKifuMove(tx, move); err != nil {
+			return "Failed to update kifu move", err
+		}
+		if req.Annotations != nil {
+			if err := dao.ClearKifuAnnotationsByMove(tx, move.BranchID, move.Number); err != nil {
+				return "Failed to update annotations", err
+			}
+			if err := dao.InsertKifuAnnotations(tx, move.Annotations); err != nil {
+				return "Failed to update annotations", err
+			}
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+type requestUpdateKifuBranchEnding struct {
+	EndingType    *model.EndingType `json:"ending_type"`    // 終局の種類（NULLで終局情報を削除）
+	EndingComment *string           `json:"ending_comment"` // 終局時のコメント
+	Version       *int64            `json:"version"`        // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// ブランチの終局情報を設定する（終局の番号は最終手の次）
+func UpdateKifuBranchEnding(c *gin.Context, req requestUpdateKifuBranchEnding) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+	branch := edit.branch
+
+	if req.EndingType == nil {
+		branch.EndingNumber, branch.EndingType, branch.EndingComment = nil, nil, nil
+	} else {
+		if err := edit.kifu.ValidateEnding(edit.branches, branch.ID, *req.EndingType); err != nil {
+			return "Invalid ending", err
+		}
+		endingNumber := branch.LastNumber() + 1
+		branch.EndingNumber = &endingNumber
+		branch.EndingType = req.EndingType
+		branch.EndingComment = req.EndingComment
+	}
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		if err := dao.UpdateKifuBranchEnding(tx, branch.KifuBranch); err != nil {
+			return "Failed to update branch ending", err
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+type requestKifuBranchVersion struct {
+	Version *int64 `json:"version"` // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// 分岐と分岐元の続きの手順を入れ替える（分岐元がメインラインなら、分岐がメインラインになる）
+// ブランチIDは変えずに、指し手・終局情報・先の分岐の付け替えで入れ替える
+func PromoteKifuVariation(c *gin.Context, req requestKifuBranchVersion) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+	variation := edit.branch
+	if variation.RootBranchID == nil {
+		return "Main branch cannot be promoted", fmt.Errorf("attempt to promote main branch")
+	}
+	parent := model.FindBranch(edit.branches, *variation.RootBranchID)
+	if parent == nil {
+		return "Branch not found", fmt.Errorf("root branch not found: %s", *variation.RootBranchID)
+	}
+	rootNumber := *variation.RootNumber
+
+	// 分岐元の続きの手順と分岐の手順を入れ替える
+	parentHead := []*model.KifuMove{}
+	parentTail := []*model.KifuMove{}
+	for _, move := range parent.Moves {
+		if move.Number <= rootNumber {
+			parentHead = append(parentHead, move)
+		} else {
+			parentTail = append(parentTail, move)
+		}
+	}
+	variationMoves := variation.Moves
+	for _, move := range variationMoves {
+		move.BranchID = parent.ID
+	}
+	for _, move := range parentTail {
+		move.BranchID = variation.ID
+	}
+	parent.Moves = append(parentHead, variationMoves...)
+	variation.Moves = parentTail
+
+	// 終局情報も手順と一緒に入れ替える
+	parent.EndingNumber, variation.EndingNumber = variation.EndingNumber, parent.EndingNumber
+	parent.EndingType, variation.EndingType = variation.EndingType, parent.EndingType
+	parent.EndingComment, variation.EndingComment = variation.EndingComment, parent.EndingComment
+
+	// 先の分岐を、入れ替え後にその手順を持つブランチに付け替える
+	rerooted := []*model.KifuBranchWithMoves{}
+	for _, branch := range edit.branches {
+		if branch.RootBranchID == nil || branch == variation {
+			continue
+		}
+		switch {
+		case *branch.RootBranchID == parent.ID && *branch.RootNumber > rootNumber:
+			branch.RootBranchID = &variation.ID
+			rerooted = append(rerooted, branch)
+		case *branch.RootBranchID == variation.ID:
+			branch.RootBranchID = &parent.ID
+			rerooted = append(rerooted, branch)
+		}
+	}
+
+	// 分岐元に続きの手順が無かった場合、入れ替え後の分岐は空になるので削除する
+	removeVariation := len(variation.Moves) == 0
+	if removeVariation {
+		edit.branches = model.RemoveBranches(edit.branches, []string{variation.ID})
+	}
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		if err := dao.DeleteKifuMovesAfter(tx, parent.ID, rootNumber); err != nil {
+			return "Failed to delete kifu moves", err
+		}
+		if err := dao.ClearKifuMovesByBranchID(tx, variation.ID); err != nil {
+			return "Failed to delete kifu moves", err
+		}
+		if err := insertKifuMoves(tx, variationMoves); err != nil {
+			return "Failed to insert kifu moves", err
+		}
+		if err := dao.UpdateKifuBranchEnding(tx, parent.KifuBranch); err != nil {
+			return "Failed to update branch ending", err
+		}
+		for _, branch := range rerooted {
+			if err := dao.UpdateKifuBranchRoot(tx, branch.KifuBranch); err != nil {
+				return "Failed to update kifu branch", err
+			}
+		}
+		if removeVariation {
+			if err := dao.DeleteKifuBranches(tx, edit.kifu.ID, []string{variation.ID}); err != nil {
+				return "Failed to delete kifu branches", err
+			}
+			return "", nil
+		}
+		if err := insertKifuMoves(tx, parentTail); err != nil {
+			return "Failed to insert kifu moves", err
+		}
+		if err := dao.UpdateKifuBranchEnding(tx, variation.KifuBranch); err != nil {
+			return "Failed to update branch ending", err
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+type requestReorderKifuVariation struct {
+	Index   int    `json:"index" binding:"min=0"` // 同じ手から分岐するブランチの中での位置（0が先頭）
+	Version *int64 `json:"version"`               // 取得時の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// 同じ手から分岐するブランチの中で、分岐の順序を変更する
+func ReorderKifuVariation(c *gin.Context, req requestReorderKifuVariation) (string, error) {
+	edit, msg, err := loadKifuBranchEdit(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+	variation := edit.branch
+	if variation.RootBranchID == nil {
+		return "Main branch cannot be reordered", fmt.Errorf("attempt to reorder main branch")
+	}
+
+	siblings := model.SiblingBranches(edit.branches, *variation.RootBranchID, *variation.RootNumber)
+	if req.Index >= len(siblings) {
+		return "Invalid index", fmt.Errorf("index out of range: %d", req.Index)
+	}
+	ordered := make([]*model.KifuBranchWithMoves, 0, len(siblings))
+	for _, sibling := range siblings {
+		if sibling != variation {
+			ordered = append(ordered, sibling)
+		}
+	}
+	ordered = append(ordered[:req.Index], append([]*model.KifuBranchWithMoves{variation}, ordered[req.Index:]...)...)
+
+	// 順序を振り直し、変わったものだけを更新する
+	changed := []*model.KifuBranchWithMoves{}
+	for i, sibling := range ordered {
+		if sibling.SortOrder != int64(i) {
+			sibling.SortOrder = int64(i)
+			changed = append(changed, sibling)
+		}
+	}
+
+	return edit.save(c, func(tx *db.Tx) (string, error) {
+		for _, branch := range changed {
+			if err := dao.UpdateKifuBranchRoot(tx, branch.KifuBranch); err != nil {
+				return "Failed to update kifu branch", err
+			}
+		}
+		return "", nil
+	})
+}
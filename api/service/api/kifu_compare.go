@@ -0,0 +1,63 @@
+// service/api/kifu_compare.go
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 閲覧可能な棋譜と分岐・指し手を取得する（非公開の棋譜は所有者のみ）
+func getViewableKifuWithBranches(accountID string, kifuID string) (*model.Kifu, []*model.KifuBranchWithMoves, string, error) {
+	kifu, err := dao.GetKifu(kifuID)
+	if err != nil {
+		return nil, nil, "Failed to get kifu", err
+	}
+	if !kifu.IsPublic && (accountID != kifu.AccountID) {
+		return nil, nil, "Access denied", fmt.Errorf("unauthorized acces to private kifu")
+	}
+	branches, msg, err := listKifuBranchesWithMoves(kifuID)
+	if err != nil {
+		return nil, nil, msg, err
+	}
+	return kifu, branches, "", nil
+}
+
+// クエリ: a、b（比較する棋譜ID）
+func CompareKifus(c *gin.Context) (*model.KifuCompareResponse, string, error) {
+	accountID := handler.GetActorID(c)
+	aID, bID := c.Query("a"), c.Query("b")
+	if aID == "" || bID == "" {
+		return nil, "Invalid kifu ID", fmt.Errorf("kifu IDs are required: a=%s, b=%s", aID, bID)
+	}
+
+	a, aBranches, msg, err := getViewableKifuWithBranches(accountID, aID)
+	if err != nil {
+		return nil, msg, err
+	}
+	b, bBranches, msg, err := getViewableKifuWithBranches(accountID, bID)
+	if err != nil {
+		return nil, msg, err
+	}
+	aKey, err := a.InitialPositionKey()
+	if err != nil {
+		return nil, "Invalid initial position", err
+	}
+	bKey, err := b.InitialPositionKey()
+	if err != nil {
+		return nil, "Invalid initial position", err
+	}
+	if aKey != bKey {
+		return nil, "Incompatible initial positions", fmt.Errorf("initial position differs: %s, %s", aID, bID)
+	}
+
+	response, err := model.CompareKifus(a, aBranches, b, bBranches)
+	if err != nil {
+		return nil, "Failed to compare kifus", err
+	}
+	return response, "", nil
+}
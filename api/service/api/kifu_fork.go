@@ -0,0 +1,96 @@
+// service/api/kifu_fork.go
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 棋譜を複製して自分の非公開棋譜として保存する
+func ForkKifu(c *gin.Context) (*CreateKifuResponse, string, error) {
+	accountID := handler.GetActorID(c)
+	kifuID := c.GetString("kifuID")
+
+	source, err := dao.GetKifu(kifuID)
+	if err != nil {
+		return nil, "Failed to get kifu", err
+	}
+
+	// 非公開の棋譜は所有者のみフォーク可能
+	if !source.IsPublic && (accountID != source.AccountID) {
+		return nil, "Access denied", fmt.Errorf("unauthorized acces to private kifu")
+	}
+	// 他のアカウントの棋譜は、所有者がフォークを許可している場合のみ
+	if accountID != source.AccountID {
+		owner, err := dao.GetAccountByID(source.AccountID)
+		if err != nil {
+			return nil, "Failed to get account info", err
+		}
+		if !owner.AllowFork {
+			return nil, "FORBIDDEN - Fork not allowed", fmt.Errorf("owner disallows forking: %s", source.AccountID)
+		}
+	}
+
+	options, tags, branches, msg, err := loadKifuContents(kifuID)
+	if err != nil {
+		return nil, msg, err
+	}
+
+	// 分岐は親ブランチが先の順で取得されるため、そのまま仮IDとして保存できる
+	kifu := &model.Kifu{
+		AccountID:       accountID,
+		Title:           source.Title,
+		IsPublic:        false,
+		BlackPlayer:     source.BlackPlayer,
+		WhitePlayer:     source.WhitePlayer,
+		StartedAt:       source.StartedAt,
+		TimeRule:        source.TimeRule,
+		InitialPosition: source.InitialPosition,
+		Fingerprint:     source.Fingerprint,
+		ForkedFrom:      &source.ID,
+	}
+	var newKifuID string
+	msg, err = inTransaction(func(tx *db.Tx) (string, error) {
+		var err error
+		newKifuID, err = dao.InsertKifu(tx, kifu)
+		if err != nil {
+			return "Failed to create kifu", err
+		}
+
+		for _, option := range options {
+			option.KifuID = newKifuID
+		}
+		if err := dao.InsertKifuOptions(tx, options); err != nil {
+			return "Failed to create kifu options", err
+		}
+		for _, tag := range tags {
+			tag.KifuID = newKifuID
+		}
+		if err := dao.InsertKifuTags(tx, tags); err != nil {
+			return "Failed to create kifu tags", err
+		}
+
+		if msg, err := insertBranchesWithMoves(tx, newKifuID, branches); err != nil {
+			return msg, err
+		}
+		if msg, err := refreshKifuIndexes(tx, newKifuID); err != nil {
+			return msg, err
+		}
+
+		revision, err := newKifuRevision(kifu, kifu.Version, accountID, model.KIFU_REVISION_FORK, options, tags, branches)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		return insertKifuRevisions(tx, nil, revision)
+	})
+	if err != nil {
+		return nil, msg, err
+	}
+	return &CreateKifuResponse{ID: &newKifuID}, "", nil
+}
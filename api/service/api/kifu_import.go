@@ -0,0 +1,258 @@
+// service/api/kifu_import.go
+
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/api/parser"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+const (
+	importMaxEntries   = 1000    // 1ジョブで取り込める棋譜数の上限
+	importMaxEntrySize = 1 << 20 // 1ファイルのサイズ上限（展開後）
+)
+
+// 取り込み対象の1棋譜分のデータ
+type importSource struct {
+	name    string
+	content string
+	err     error // 展開・デコードに失敗した場合のエラー
+}
+
+// ------------------------------------------------------------
+type requestImportKifus struct {
+	Type        string `json:"type" binding:"required,oneof=file zip"`
+	Name        string `json:"name"`                                             // ファイル名（結果表示用）
+	Content     string `json:"content" binding:"required"`                       // fileは棋譜テキスト、zipはBase64エンコードしたアーカイブ
+	OnDuplicate string `json:"on_duplicate" binding:"omitempty,oneof=warn skip"` // 同一棋譜がある場合の動作（省略時はwarn）
+}
+
+func ImportKifus(c *gin.Context, req requestImportKifus) (*string, string, error) {
+	aid := handler.GetActorID(c)
+
+	// 取り込み対象の棋譜を展開
+	var sources []*importSource
+	switch req.Type {
+	case "file":
+		sources = splitImportSource(req.Name, req.Content)
+	case "zip":
+		data, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			return nil, "Invalid zip content", err
+		}
+		sources, err = extractImportSourcesFromZip(data)
+		if err != nil {
+			return nil, "Failed to open zip archive", err
+		}
+	default:
+		return nil, "Invalid import type", fmt.Errorf("invalid type: %s", req.Type)
+	}
+	if len(sources) == 0 {
+		return nil, "No kifu found", fmt.Errorf("no kifu found in content")
+	}
+	if len(sources) > importMaxEntries {
+		return nil, "Too many kifus", fmt.Errorf("too many kifus: %d (max %d)", len(sources), importMaxEntries)
+	}
+
+	// ジョブを登録して非同期で取り込む
+	job := &model.ImportJob{
+		AccountID:  aid,
+		Status:     model.IMPORT_JOB_PENDING,
+		TotalCount: int64(len(sources)),
+	}
+	jobID, err := dao.InsertImportJob(job)
+	if err != nil {
+		return nil, "Failed to create import job", err
+	}
+	go runImportJob(job, sources, req.OnDuplicate == "skip")
+
+	return &jobID, "", nil
+}
+
+// 複数棋譜のCSAは棋譜ごとに分割する
+func splitImportSource(name string, content string) []*importSource {
+	if name == "" {
+		name = "kifu"
+	}
+	games := parser.SplitCSA(content)
+	if len(games) <= 1 {
+		return []*importSource{{name: name, content: content}}
+	}
+	sources := make([]*importSource, 0, len(games))
+	for i, game := range games {
+		sources = append(sources, &importSource{
+			name:    fmt.Sprintf("%s#%d", name, i+1),
+			content: game,
+		})
+	}
+	return sources
+}
+
+func extractImportSourcesFromZip(data []byte) ([]*importSource, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := []*importSource{}
+	for _, file := range reader.File {
+		// ディレクトリとOSのメタデータファイルは対象外
+		base := path.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasPrefix(base, ".") || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+
+		if file.UncompressedSize64 > importMaxEntrySize {
+			sources = append(sources, &importSource{
+				name: file.Name,
+				err:  fmt.Errorf("file too large: %d bytes", file.UncompressedSize64),
+			})
+			continue
+		}
+		content, err := readZipFile(file)
+		if err != nil {
+			sources = append(sources, &importSource{name: file.Name, err: err})
+			continue
+		}
+		sources = append(sources, splitImportSource(file.Name, content)...)
+	}
+	return sources, nil
+}
+
+func readZipFile(file *zip.File) (string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, importMaxEntrySize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > importMaxEntrySize {
+		return "", fmt.Errorf("file too large")
+	}
+	return parser.DecodeText(data)
+}
+
+// 取り込みジョブの実行（goroutineで呼び出す）
+func runImportJob(job *model.ImportJob, sources []*importSource, skipDuplicate bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Import job panicked", "jobID", job.ID, "panic", r)
+			failImportJob(job, "Unexpected error during import")
+		}
+	}()
+
+	job.Status = model.IMPORT_JOB_RUNNING
+	if err := dao.UpdateImportJobProgress(job); err != nil {
+		slog.Error("Failed to update import job", "jobID", job.ID, "error", err)
+		failImportJob(job, "Failed to start import")
+		return
+	}
+
+	for i, source := range sources {
+		entry := &model.ImportJobEntry{
+			JobID: job.ID,
+			Seq:   int64(i + 1),
+			Name:  source.name,
+		}
+		resp, msg, err := importKifuSource(job.AccountID, source, skipDuplicate)
+		if err != nil {
+			slog.Warn("Failed to import kifu", "jobID", job.ID, "name", source.name, "error", err)
+			if msg == "" {
+				msg = err.Error()
+			} else {
+				msg = fmt.Sprintf("%s: %v", msg, err)
+			}
+			entry.Message = auxi.PString(msg)
+			job.FailedCount++
+		} else {
+			if len(resp.Duplicates) > 0 {
+				entry.DuplicateOf = &resp.Duplicates[0].ID
+			}
+			entry.KifuID = resp.ID
+			if resp.ID == nil {
+				job.SkippedCount++
+			} else {
+				job.SucceededCount++
+			}
+		}
+		job.ProcessedCount++
+
+		if err := dao.InsertImportJobEntry(entry); err != nil {
+			slog.Error("Failed to insert import job entry", "jobID", job.ID, "error", err)
+		}
+		if err := dao.UpdateImportJobProgress(job); err != nil {
+			slog.Error("Failed to update import job", "jobID", job.ID, "error", err)
+		}
+	}
+
+	job.Status = model.IMPORT_JOB_DONE
+	if err := dao.UpdateImportJobProgress(job); err != nil {
+		slog.Error("Failed to update import job", "jobID", job.ID, "error", err)
+	}
+}
+
+// ジョブを失敗にする（更新できなかった場合はログのみ）
+func failImportJob(job *model.ImportJob, message string) {
+	job.Status = model.IMPORT_JOB_FAILED
+	job.Message = &message
+	if err := dao.UpdateImportJobProgress(job); err != nil {
+		slog.Error("Failed to update import job", "jobID", job.ID, "error", err)
+	}
+}
+
+// 再起動で中断されたジョブを失敗にする（取り込みは実行中のプロセスでのみ行われるため再開できない）
+func failInterruptedImportJobs() error {
+	count, err := dao.FailUnfinishedImportJobs("Import was interrupted by a server restart")
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		slog.Warn("Marked interrupted import jobs as failed", "count", count)
+	}
+	return nil
+}
+
+func importKifuSource(aid string, source *importSource, skipDuplicate bool) (*CreateKifuResponse, string, error) {
+	if source.err != nil {
+		return nil, "Failed to read file", source.err
+	}
+	return createKifuFromFile(aid, source.content, skipDuplicate)
+}
+
+// ------------------------------------------------------------
+func GetImportJob(c *gin.Context) (*model.ImportJobResponse, string, error) {
+	accountID := handler.GetActorID(c)
+	jobID := c.GetString("jobID")
+
+	job, err := dao.GetImportJob(jobID)
+	if err != nil {
+		return nil, "Failed to get import job", err
+	}
+	if job.AccountID != accountID {
+		return nil, "Access denied", fmt.Errorf("unauthorized access to import job")
+	}
+
+	entries, err := dao.ListImportJobEntries(jobID)
+	if err != nil {
+		return nil, "Failed to get import job entries", err
+	}
+
+	return job.ToResponse(entries), "", nil
+}
@@ -0,0 +1,88 @@
+// service/api/kifu_merge.go
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+type requestMergeKifus struct {
+	KifuIDs []string `json:"kifu_ids" binding:"required,min=2,max=20,dive,required"` // 統合する棋譜（最初の棋譜の手順がメインライン）
+	Title   string   `json:"title" binding:"max=100"`                                // 省略時は「統合棋譜」
+}
+
+// 開始局面が同じ複数の棋譜を、分岐を持つ1つの非公開棋譜にまとめる
+func MergeKifus(c *gin.Context, req requestMergeKifus) (*CreateKifuResponse, string, error) {
+	accountID := handler.GetActorID(c)
+
+	sources := []*model.KifuMergeSource{}
+	seen := make(map[string]bool)
+	var initialKey string
+	for _, kifuID := range req.KifuIDs {
+		if seen[kifuID] {
+			continue
+		}
+		seen[kifuID] = true
+
+		kifu, err := dao.GetKifu(kifuID)
+		if err != nil {
+			return nil, "Failed to get kifu", err
+		}
+		// 非公開の棋譜は所有者のみアクセス可能
+		if !kifu.IsPublic && (accountID != kifu.AccountID) {
+			return nil, "Access denied", fmt.Errorf("unauthorized acces to private kifu")
+		}
+
+		key, err := kifu.InitialPositionKey()
+		if err != nil {
+			return nil, "Invalid initial position", err
+		}
+		if len(sources) == 0 {
+			initialKey = key
+		} else if key != initialKey {
+			return nil, "Incompatible initial positions", fmt.Errorf("initial position differs: %s", kifuID)
+		}
+
+		branches, msg, err := listKifuBranchesWithMoves(kifuID)
+		if err != nil {
+			return nil, msg, err
+		}
+		source := &model.KifuMergeSource{Kifu: kifu, Branches: branches}
+		// 初手の分岐はメインラインの分岐として表せないため統合しない
+		for _, other := range sources {
+			if !source.SameFirstMove(other) {
+				return nil, "BAD_REQUEST - Kifus to merge must share the first move", fmt.Errorf("first move differs: %s", kifuID)
+			}
+		}
+		sources = append(sources, source)
+	}
+
+	branches, err := model.MergeKifuLines(sources)
+	if err != nil {
+		return nil, "Invalid move", err
+	}
+
+	title := req.Title
+	if title == "" {
+		title = "統合棋譜"
+	}
+	parsedKifu := &model.ParsedKifu{
+		Kifu: &model.Kifu{
+			AccountID:       accountID,
+			Title:           title,
+			IsPublic:        false,
+			InitialPosition: sources[0].Kifu.InitialPosition,
+		},
+		Branches: branches,
+	}
+	kifuID, msg, err := createKifuFromParsedKifu(parsedKifu)
+	if err != nil {
+		return nil, msg, err
+	}
+	return &CreateKifuResponse{ID: kifuID}, "", nil
+}
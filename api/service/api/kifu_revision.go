@@ -0,0 +1,244 @@
+// service/api/kifu_revision.go
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 棋譜情報・タグ・分岐と指し手をまとめて取得する
+func loadKifuContents(kifuID string) ([]*model.KifuOption, []*model.KifuTag, []*model.KifuBranchWithMoves, string, error) {
+	options, err := dao.ListKifuOptionsByKifuID(kifuID)
+	if err != nil {
+		return nil, nil, nil, "Failed to get kifu options", err
+	}
+	tags, err := dao.ListKifuTagsByKifuID(kifuID)
+	if err != nil {
+		return nil, nil, nil, "Failed to get kifu tags", err
+	}
+	branches, msg, err := listKifuBranchesWithMoves(kifuID)
+	if err != nil {
+		return nil, nil, nil, msg, err
+	}
+	return options, tags, branches, "", nil
+}
+
+func newKifuRevision(kifu *model.Kifu, version int64, accountID string, action model.KifuRevisionAction, options []*model.KifuOption, tags []*model.KifuTag, branches []*model.KifuBranchWithMoves) (*model.KifuRevision, error) {
+	snapshot, err := model.NewKifuSnapshot(kifu, options, tags, branches).ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	revision := &model.KifuRevision{
+		KifuID:    kifu.ID,
+		Version:   version,
+		AccountID: accountID,
+		Action:    action,
+		Snapshot:  snapshot,
+	}
+	return revision, nil
+}
+
+// 履歴が未記録の棋譜（履歴機能の導入前に作成された棋譜）は、変更前の状態を基準リビジョンとして残す
+// 既に履歴がある場合はnilを返す（kifuは変更前の状態で渡すこと）
+func newKifuBaselineRevision(kifu *model.Kifu, options []*model.KifuOption, tags []*model.KifuTag, branches []*model.KifuBranchWithMoves) (*model.KifuRevision, string, error) {
+	count, err := dao.CountKifuRevisionsByKifuID(kifu.ID)
+	if err != nil {
+		return nil, "Failed to get kifu revisions", err
+	}
+	if count > 0 {
+		return nil, "", nil
+	}
+	baseline, err := newKifuRevision(kifu, kifu.Version, kifu.AccountID, model.KIFU_REVISION_BASELINE, options, tags, branches)
+	if err != nil {
+		return nil, "Failed to create kifu revision", err
+	}
+	baseline.CreatedAt = kifu.UpdatedAt
+	return baseline, "", nil
+}
+
+// 変更後の棋譜をリビジョンとして記録する（基準リビジョンがあれば先に記録する）
+func insertKifuRevisions(tx *db.Tx, baseline *model.KifuRevision, revision *model.KifuRevision) (string, error) {
+	if baseline != nil {
+		if _, err := dao.InsertKifuRevision(tx, baseline); err != nil {
+			return "Failed to insert kifu revision", err
+		}
+	}
+	if _, err := dao.InsertKifuRevision(tx, revision); err != nil {
+		return "Failed to insert kifu revision", err
+	}
+	return "", nil
+}
+
+// 所有者の棋譜を取得する（履歴は所有者のみ参照可能）
+func getOwnKifu(c *gin.Context) (*model.Kifu, string, error) {
+	accountID := handler.GetActorID(c)
+	kifuID := c.GetString("kifuID")
+
+	kifu, err := dao.GetKifu(kifuID)
+	if err != nil {
+		return nil, "Failed to get kifu", err
+	}
+	if kifu.AccountID != accountID {
+		return nil, "Access denied", fmt.Errorf("unauthorized access")
+	}
+	return kifu, "", nil
+}
+
+// ------------------------------------------------------------
+func ListKifuRevisions(c *gin.Context, pgreq *handler.PaginationRequest) (*[]*model.KifuRevisionResponse, *handler.PaginatedResponse, string, error) {
+	kifu, msg, err := getOwnKifu(c)
+	if err != nil {
+		return nil, nil, msg, err
+	}
+
+	limit, offset := pgreq.LimitOffset()
+	totalCount, _ := dao.CountKifuRevisionsByKifuID(kifu.ID)
+	revisions, err := dao.ListKifuRevisionsByKifuID(kifu.ID, limit, offset)
+	if err != nil {
+		return nil, nil, "Failed to get kifu revisions", err
+	}
+
+	// レスポンス構築（変更したアカウントの情報はまとめて取得する）
+	accountIDs := make([]string, 0, len(revisions))
+	for _, revision := range revisions {
+		accountIDs = append(accountIDs, revision.AccountID)
+	}
+	accounts, err := dao.ListAccountsByIDs(accountIDs)
+	if err != nil {
+		return nil, nil, "Failed to get account info", err
+	}
+	responses := make([]*model.KifuRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		account, ok := accounts[revision.AccountID]
+		if !ok {
+			return nil, nil, "Failed to get account info", fmt.Errorf("account not found: %s", revision.AccountID)
+		}
+		responses = append(responses, revision.ToResponse(account))
+	}
+	return &responses, pgreq.NewPaginatedResponse(totalCount), "", nil
+}
+
+// ------------------------------------------------------------
+// リビジョン時点の棋譜をGetKifuと同じ形式で返す
+func GetKifuRevision(c *gin.Context) (*model.KifuDetailResponse, string, error) {
+	kifu, msg, err := getOwnKifu(c)
+	if err != nil {
+		return nil, msg, err
+	}
+
+	revision, err := dao.GetKifuRevision(kifu.ID, c.GetString("revisionID"))
+	if err != nil {
+		return nil, "Failed to get kifu revision", err
+	}
+	snapshot, err := revision.ParseSnapshot()
+	if err != nil {
+		return nil, "Failed to parse kifu revision", err
+	}
+	revisionKifu, options, tags, branches := snapshot.ToKifuData(kifu)
+	revisionKifu.Version = revision.Version
+	revisionKifu.UpdatedAt = revision.CreatedAt
+
+	owner, err := dao.GetAccountByID(kifu.AccountID)
+	if err != nil {
+		return nil, "Failed to get account info", err
+	}
+	hasLike, _ := dao.HasKifuLike(kifu.ID, kifu.AccountID)
+
+	return revisionKifu.ToDetailResponse(owner, options, tags, branches, hasLike), "", nil
+}
+
+// ------------------------------------------------------------
+type requestRestoreKifuRevision struct {
+	Version *int64 `json:"version"` // 現在の版（If-Matchヘッダーで指定する場合は省略可）
+}
+
+// 過去のリビジョンの内容で現在の棋譜を置き換える（復元も新しいリビジョンとして記録する）
+func RestoreKifuRevision(c *gin.Context, req requestRestoreKifuRevision) (string, error) {
+	accountID := handler.GetActorID(c)
+	kifu, msg, err := getOwnKifu(c)
+	if err != nil {
+		return msg, err
+	}
+	version, msg, err := requestedKifuVersion(c, req.Version)
+	if err != nil {
+		return msg, err
+	}
+
+	revision, err := dao.GetKifuRevision(kifu.ID, c.GetString("revisionID"))
+	if err != nil {
+		return "Failed to get kifu revision", err
+	}
+	snapshot, err := revision.ParseSnapshot()
+	if err != nil {
+		return "Failed to parse kifu revision", err
+	}
+
+	// 変更前の状態
+	currentOptions, currentTags, currentBranches, msg, err := loadKifuContents(kifu.ID)
+	if err != nil {
+		return msg, err
+	}
+	baseline, msg, err := newKifuBaselineRevision(kifu, currentOptions, currentTags, currentBranches)
+	if err != nil {
+		return msg, err
+	}
+
+	restored, options, tags, branches := snapshot.ToKifuData(kifu)
+	var mainMoves []*model.KifuMove
+	if len(branches) > 0 {
+		mainMoves = branches[0].Moves
+	}
+	fingerprint, err := model.NewKifuFingerprint(restored.InitialPosition, mainMoves)
+	if err != nil {
+		return "Invalid initial position", err
+	}
+
+	msg, err = updateKifuWithVersion(c, kifu, version, func(tx *db.Tx) (string, error) {
+		if err := dao.UpdateKifu(tx, restored); err != nil {
+			return "Failed to update kifu", err
+		}
+		if err := dao.ClearKifuOptionsByKifuID(tx, kifu.ID); err != nil {
+			return "Failed to clear existing kifu options", err
+		}
+		if err := dao.InsertKifuOptions(tx, options); err != nil {
+			return "Failed to insert kifu options", err
+		}
+		if err := dao.ClearKifuTagsByKifuID(tx, kifu.ID); err != nil {
+			return "Failed to clear existing kifu tags", err
+		}
+		if err := dao.InsertKifuTags(tx, tags); err != nil {
+			return "Failed to insert kifu tags", err
+		}
+		if err := dao.ClearKifuBranchesByKifuID(tx, kifu.ID); err != nil {
+			return "Failed to clear existing branches", err
+		}
+		if msg, err := insertBranchesWithMoves(tx, kifu.ID, branches); err != nil {
+			return msg, err
+		}
+		if err := dao.UpdateKifuFingerprint(tx, kifu.ID, fingerprint); err != nil {
+			return "Failed to update kifu fingerprint", err
+		}
+
+		after, err := newKifuRevision(restored, version+1, accountID, model.KIFU_REVISION_RESTORE, options, tags, branches)
+		if err != nil {
+			return "Failed to create kifu revision", err
+		}
+		after.RestoredOf = &revision.ID
+		return insertKifuRevisions(tx, baseline, after)
+	})
+	if err != nil {
+		return msg, err
+	}
+
+	// 公開状態に戻した棋譜を保存された検索条件と照合する
+	if !kifu.IsPublic && restored.IsPublic {
+		go notifySavedSearches(kifu.ID)
+	}
+	return "", nil
+}
@@ -0,0 +1,126 @@
+// service/api/kifu_similar.go
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+const similarKifuCandidates = 200 // 類似度を計算する候補の数（共通の項目が多い順）
+
+// クエリ: limit（件数、省略時は5）
+func ListSimilarKifus(c *gin.Context) ([]*model.SimilarKifuResponse, string, error) {
+	accountID := handler.GetActorID(c)
+	kifuID := c.GetString("kifuID")
+	limit := 5
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 20 {
+			return nil, "Invalid limit", fmt.Errorf("invalid limit: %s", s)
+		}
+		limit = n
+	}
+
+	kifu, branches, msg, err := getViewableKifuWithBranches(accountID, kifuID)
+	if err != nil {
+		return nil, msg, err
+	}
+	tags, err := dao.ListKifuTagsByKifuIDs([]string{kifuID})
+	if err != nil {
+		return nil, "Failed to get tags", err
+	}
+	players, err := dao.ListKifuPlayersByKifuIDs([]string{kifuID})
+	if err != nil {
+		return nil, "Failed to get players", err
+	}
+	target := model.NewKifuFeatures(kifu, tags[kifuID], players[kifuID])
+	var mainMoves []*model.KifuMove
+	if mainBranch := model.MainBranch(branches); mainBranch != nil {
+		mainMoves = mainBranch.Moves
+	}
+	if err := target.SetOpeningMoves(mainMoves); err != nil {
+		return nil, "Failed to replay kifu", err
+	}
+
+	// SQLで候補を絞り込み、候補の特徴をまとめて取得して類似度を計算する
+	cond := &dao.SimilarKifuCondition{
+		KifuID:   kifuID,
+		TimeRule: kifu.TimeRule,
+	}
+	for key := range target.TagNames {
+		cond.TagKeys = append(cond.TagKeys, key)
+	}
+	for _, player := range target.Players {
+		if player.PlayerID != nil {
+			cond.PlayerIDs = append(cond.PlayerIDs, *player.PlayerID)
+		}
+		if player.NameKey != "" {
+			cond.NameKeys = append(cond.NameKeys, player.NameKey)
+		}
+	}
+	if len(mainMoves) > 0 {
+		cond.FirstMove = mainMoves[0]
+	}
+	candidateIDs, err := dao.ListSimilarKifuCandidateIDs(cond, similarKifuCandidates)
+	if err != nil {
+		return nil, "Failed to get similar kifus", err
+	}
+	if len(candidateIDs) == 0 {
+		return []*model.SimilarKifuResponse{}, "", nil
+	}
+	candidates, _, err := dao.ListKifusByCondition(&dao.KifuSearchCondition{KifuIDs: candidateIDs, PublicOnly: true}, len(candidateIDs), 0)
+	if err != nil {
+		return nil, "Failed to get kifus", err
+	}
+	candidateTags, err := dao.ListKifuTagsByKifuIDs(candidateIDs)
+	if err != nil {
+		return nil, "Failed to get tags", err
+	}
+	candidatePlayers, err := dao.ListKifuPlayersByKifuIDs(candidateIDs)
+	if err != nil {
+		return nil, "Failed to get players", err
+	}
+	candidateMoves, err := dao.ListKifuOpeningMovesByKifuIDs(candidateIDs, model.SimilarKifuOpeningMoves)
+	if err != nil {
+		return nil, "Failed to get moves", err
+	}
+
+	kifuMap := make(map[string]*model.Kifu, len(candidates))
+	similarities := make([]*model.KifuSimilarity, 0, len(candidates))
+	for _, candidate := range candidates {
+		features := model.NewKifuFeatures(candidate, candidateTags[candidate.ID], candidatePlayers[candidate.ID])
+		if err := features.SetOpeningMoves(candidateMoves[candidate.ID]); err != nil {
+			continue // 開始局面を読めない棋譜は局面以外でも比較しない
+		}
+		similarity := target.Similarity(features)
+		if similarity.Score <= 0 {
+			continue
+		}
+		kifuMap[candidate.ID] = candidate
+		similarities = append(similarities, similarity)
+	}
+	model.SortKifuSimilarities(similarities, kifuMap)
+	if len(similarities) > limit {
+		similarities = similarities[:limit]
+	}
+
+	kifus := make([]*model.Kifu, len(similarities))
+	for i, similarity := range similarities {
+		kifus[i] = kifuMap[similarity.KifuID]
+	}
+	summaries, msg, err := newKifuSummaryResponses(kifus)
+	if err != nil {
+		return nil, msg, err
+	}
+	responses := make([]*model.SimilarKifuResponse, len(similarities))
+	for i, similarity := range similarities {
+		responses[i] = similarity.ToResponse(summaries[i])
+	}
+	return responses, "", nil
+}
@@ -0,0 +1,84 @@
+// service/api/kifu_version.go
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 更新リクエストで指定された版を取得する（If-Matchヘッダーを優先し、無ければversionフィールド）
+func requestedKifuVersion(c *gin.Context, version *int64) (int64, string, error) {
+	if ifMatch := strings.TrimSpace(c.GetHeader("If-Match")); ifMatch != "" {
+		// 「*」（任意の版）では競合を検出できないため、版の指定を求める
+		if ifMatch == "*" {
+			return 0, "PRECONDITION_REQUIRED - If-Match: * is not supported, specify the version", fmt.Errorf("wildcard If-Match header")
+		}
+		v, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+		if err != nil {
+			return 0, "BAD_REQUEST - Invalid If-Match header", err
+		}
+		return v, "", nil
+	}
+	if version == nil {
+		return 0, "PRECONDITION_REQUIRED - version or If-Match header is required", fmt.Errorf("kifu version is not specified")
+	}
+	return *version, "", nil
+}
+
+// 版が一致しない場合のレスポンス（現在の版をETagとメッセージで返す）
+func kifuVersionConflict(c *gin.Context, current *model.Kifu) (string, error) {
+	c.Header("ETag", current.ETag())
+	msg := fmt.Sprintf("CONFLICT - Kifu has been updated by another session (current version: %d)", current.Version)
+	return msg, fmt.Errorf("kifu version conflict: current=%d", current.Version)
+}
+
+// 棋譜の内容から導出するデータ（検索インデックス・対局者と勝敗・対局者の成績）を更新する（棋譜の作成・更新のトランザクション内で呼ぶ）
+func refreshKifuIndexes(tx *db.Tx, kifuID string) (string, error) {
+	if err := dao.RefreshKifuSearchDocument(tx, kifuID); err != nil {
+		return "Failed to update kifu search index", err
+	}
+	if err := linkKifuPlayers(tx, kifuID); err != nil {
+		return "Failed to link kifu players", err
+	}
+	if err := dao.RefreshStalePlayerStats(tx); err != nil {
+		return "Failed to refresh player stats", err
+	}
+	return "", nil
+}
+
+// 版を進めてから、fをトランザクション内で実行する（検索インデックス・対局者も更新する）
+// 他の更新と競合した場合はロールバックして409を返す
+func updateKifuWithVersion(c *gin.Context, kifu *model.Kifu, version int64, f func(tx *db.Tx) (string, error)) (string, error) {
+	if kifu.Version != version {
+		return kifuVersionConflict(c, kifu)
+	}
+
+	msg, err := inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.IncrementKifuVersion(tx, kifu.ID, version); err != nil {
+			return "Failed to update kifu version", err
+		}
+		if msg, err := f(tx); err != nil {
+			return msg, err
+		}
+		// 更新後の内容で検索インデックス・対局者を更新する
+		return refreshKifuIndexes(tx, kifu.ID)
+	})
+	if err != nil {
+		// 確認後に他の更新が割り込んだ場合
+		if current, getErr := dao.GetKifu(kifu.ID); getErr == nil && current.Version != version {
+			return kifuVersionConflict(c, current)
+		}
+		return msg, err
+	}
+
+	kifu.Version = version + 1
+	c.Header("ETag", kifu.ETag())
+	return "", nil
+}
@@ -0,0 +1,153 @@
+// service/api/notification.go
+
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/aws"
+	"github.com/jcytp/kifup-api/common/env"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+const notificationDigestPeriod = 24 * time.Hour // 通知のメールをまとめて送る間隔
+
+type requestListNotifications struct {
+	Unread bool `form:"unread"` // 未読の通知のみ
+}
+
+func ListNotifications(c *gin.Context, req requestListNotifications, pgreq *handler.PaginationRequest) (*[]*model.NotificationResponse, *handler.PaginatedResponse, string, error) {
+	accountID := handler.GetActorID(c)
+	limit, offset := pgreq.LimitOffset()
+
+	totalCount, err := dao.CountNotificationsByAccountID(accountID, req.Unread)
+	if err != nil {
+		return nil, nil, "Failed to get notifications", err
+	}
+	notifications, err := dao.ListNotificationsByAccountID(accountID, req.Unread, limit, offset)
+	if err != nil {
+		return nil, nil, "Failed to get notifications", err
+	}
+
+	// 通知の棋譜・検索条件をまとめて取得する
+	kifuIDs := make([]string, 0, len(notifications))
+	searchIDs := make([]string, 0, len(notifications))
+	for _, notification := range notifications {
+		kifuIDs = append(kifuIDs, notification.KifuID)
+		if notification.SavedSearchID != nil {
+			searchIDs = append(searchIDs, *notification.SavedSearchID)
+		}
+	}
+	kifus, _, err := dao.ListKifusByCondition(&dao.KifuSearchCondition{KifuIDs: kifuIDs, PublicOnly: true}, len(kifuIDs), 0)
+	if err != nil {
+		return nil, nil, "Failed to get kifus", err
+	}
+	kifuResponses, msg, err := newKifuSummaryResponses(kifus)
+	if err != nil {
+		return nil, nil, msg, err
+	}
+	kifuMap := make(map[string]*model.KifuSummaryResponse, len(kifuResponses))
+	for _, kifuResponse := range kifuResponses {
+		kifuMap[kifuResponse.ID] = kifuResponse
+	}
+	searches, err := dao.ListSavedSearchesByIDs(searchIDs)
+	if err != nil {
+		return nil, nil, "Failed to get saved searches", err
+	}
+
+	responses := make([]*model.NotificationResponse, 0, len(notifications))
+	for _, notification := range notifications {
+		var search *model.SavedSearch
+		if notification.SavedSearchID != nil {
+			search = searches[*notification.SavedSearchID]
+		}
+		responses = append(responses, notification.ToResponse(search, kifuMap[notification.KifuID]))
+	}
+	return &responses, pgreq.NewPaginatedResponse(totalCount), "", nil
+}
+
+func ReadNotification(c *gin.Context) (string, error) {
+	accountID := handler.GetActorID(c)
+	notificationID := c.GetString("notificationID")
+
+	if err := dao.MarkNotificationRead(notificationID, accountID); err != nil {
+		return "Failed to update notification", err
+	}
+	return "", nil
+}
+
+func ReadAllNotifications(c *gin.Context) (string, error) {
+	accountID := handler.GetActorID(c)
+
+	if err := dao.MarkAllNotificationsRead(accountID); err != nil {
+		return "Failed to update notifications", err
+	}
+	return "", nil
+}
+
+// ------------------------------------------------------------
+
+// 定期的に、メール通知を有効にした検索条件の未読の通知をまとめて送る
+func StartNotificationDigestCycle() {
+	ticker := time.NewTicker(notificationDigestPeriod)
+	go func() {
+		for range ticker.C {
+			sendNotificationDigests()
+		}
+	}()
+}
+
+// アカウントごとに未送信の通知を1通のメールにまとめて送る（開発環境では送らずにログに出力する）
+func sendNotificationDigests() {
+	items, err := dao.ListNotificationDigestItems()
+	if err != nil {
+		slog.Error("Failed to get notification digest", "error", err)
+		return
+	}
+
+	// 通知はアカウント・検索条件の順に並んでいる
+	for start := 0; start < len(items); {
+		end := start + 1
+		for end < len(items) && items[end].AccountID == items[start].AccountID {
+			end++
+		}
+		accountItems := items[start:end]
+		start = end
+
+		subject := "棋譜UP 保存した検索条件に一致する棋譜"
+		message := notificationDigestMessage(accountItems)
+		if env.IsDevelopment() {
+			slog.Info("Notification digest", "to", accountItems[0].Email, "subject", subject, "message", message)
+		} else {
+			aws.SesSendEmailOne(env.EmailSender(), accountItems[0].Email, subject, message)
+		}
+
+		notificationIDs := make([]string, len(accountItems))
+		for i, item := range accountItems {
+			notificationIDs[i] = item.NotificationID
+		}
+		if err := dao.MarkNotificationsEmailed(notificationIDs); err != nil {
+			slog.Error("Failed to update notifications", "accountID", accountItems[0].AccountID, "error", err)
+		}
+	}
+}
+
+func notificationDigestMessage(items []*model.NotificationDigestItem) string {
+	var sb strings.Builder
+	sb.WriteString("保存した検索条件に一致する棋譜が公開されました。\n")
+	searchID := ""
+	for _, item := range items {
+		if item.SavedSearchID != searchID {
+			searchID = item.SavedSearchID
+			fmt.Fprintf(&sb, "\n■ %s\n", item.SavedSearchName)
+		}
+		fmt.Fprintf(&sb, "・%s\n  %s/kifu/view?id=%s\n", item.KifuTitle, env.FrontendOrigin(), item.KifuID)
+	}
+	return sb.String()
+}
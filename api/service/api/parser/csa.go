@@ -120,6 +120,28 @@ func ParseFromCSA(content string) (*model.ParsedKifu, error) {
 	return result, nil
 }
 
+// 複数の棋譜を含むCSAデータを、区切り行（"/"）で棋譜ごとに分割する
+func SplitCSA(content string) []string {
+	games := []string{}
+	lines := []string{}
+	appendGame := func() {
+		game := strings.Join(lines, "\n")
+		if strings.TrimSpace(game) != "" {
+			games = append(games, game)
+		}
+		lines = []string{}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "/" {
+			appendGame()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	appendGame()
+	return games
+}
+
 func checkKifuFormatCSA(lines []string) bool {
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
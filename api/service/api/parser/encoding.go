@@ -0,0 +1,24 @@
+// service/api/parser/encoding.go
+
+package parser
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// 棋譜ファイルのバイト列を文字列に変換する
+// UTF-8（BOM付きを含む）でなければShift_JISとして扱う
+func DecodeText(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte{0xef, 0xbb, 0xbf})
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,329 @@
+// service/api/parser/jkf.go
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// JSON棋譜フォーマット（JKF）の構造
+// https://github.com/na2hiro/json-kifu-format
+type jkfKifu struct {
+	Header  map[string]string `json:"header"`
+	Initial *jkfInitial       `json:"initial"`
+	Moves   []*jkfMoveFormat  `json:"moves"`
+}
+
+type jkfInitial struct {
+	Preset string          `json:"preset"`
+	Data   *jkfStateFormat `json:"data"` // preset=OTHERの場合の局面
+}
+
+type jkfStateFormat struct {
+	Color int              `json:"color"` // 手番（0:先手、1:後手）
+	Board [][]jkfPiece     `json:"board"` // board[筋-1][段-1]
+	Hands []map[string]int `json:"hands"` // [先手の持ち駒, 後手の持ち駒]
+}
+
+type jkfPiece struct {
+	Color *int   `json:"color"`
+	Kind  string `json:"kind"`
+}
+
+type jkfMoveFormat struct {
+	Comments []string           `json:"comments"`
+	Move     *jkfMove           `json:"move"`
+	Time     *jkfTime           `json:"time"`
+	Special  string             `json:"special"`
+	Forks    [][]*jkfMoveFormat `json:"forks"` // この手に変わる手順
+}
+
+type jkfMove struct {
+	Color   int       `json:"color"`
+	From    *jkfPlace `json:"from"` // 駒打ちの場合は省略
+	To      *jkfPlace `json:"to"`
+	Piece   string    `json:"piece"`   // 動かす前の駒種
+	Promote *bool     `json:"promote"` // 成った場合はtrue
+}
+
+type jkfPlace struct {
+	X int `json:"x"` // 筋
+	Y int `json:"y"` // 段
+}
+
+type jkfTime struct {
+	Now *jkfTimeFormat `json:"now"`
+}
+
+type jkfTimeFormat struct {
+	H int `json:"h"`
+	M int `json:"m"`
+	S int `json:"s"`
+}
+
+var presetToSfenJKF = map[string]model.SFEN{
+	"HIRATE": model.SfenHirate,
+	"KY":     model.SfenKyoOchi,
+	"KY_R":   model.SfenMigiKyoOchi,
+	"KA":     model.SfenKakuOchi,
+	"HI":     model.SfenHishaOchi,
+	"HIKY":   model.SfenHiKyoOchi,
+	"2":      model.SfenNimaiOchi,
+	"3":      model.SfenSanmaiOchi,
+	"4":      model.SfenYonmaiOchi,
+	"5":      model.SfenGomaiOchi,
+	"5_L":    model.SfenHidariGomaiOchi,
+	"6":      model.SfenRokumaiOchi,
+	"7_L":    model.SfenHidariNanamaiOchi,
+	"7_R":    model.SfenMigiNanamaiOchi,
+	"8":      model.SfenHachimaiOchi,
+	"10":     model.SfenJumaiOchi,
+}
+
+func ParseFromJKF(content string) (*model.ParsedKifu, error) {
+	jkf, ok := checkKifuFormatJKF(content)
+	if !ok {
+		return nil, nil
+	}
+
+	kifuID := auxi.NewULID()       // dummy id
+	mainBranchID := auxi.NewULID() // dummy id
+	result := &model.ParsedKifu{
+		Kifu: &model.Kifu{
+			Title:           "新規棋譜",
+			IsPublic:        false,
+			InitialPosition: model.SfenHirate.PSFEN(),
+		},
+		Options: []*model.KifuOption{},
+		Branches: []*model.KifuBranchWithMoves{
+			{
+				KifuBranch: &model.KifuBranch{
+					ID:           mainBranchID,
+					KifuID:       kifuID,
+					RootBranchID: nil,
+					RootNumber:   nil,
+				},
+			},
+		},
+	}
+
+	// 棋譜情報（キーはKIF形式と同じ）
+	tmpTimeRule := &model.GameInfo{}
+	for key, value := range jkf.Header {
+		line := fmt.Sprintf("%s：%s", key, value)
+		if err := parseGameInfoLineForKIF(line, result, tmpTimeRule); err != nil {
+			return nil, err
+		}
+	}
+	result.Kifu.TimeRule = tmpTimeRule.GetTimeRule()
+
+	// 開始局面（手合割より優先）
+	if jkf.Initial != nil {
+		sfen, err := parseInitialForJKF(jkf.Initial)
+		if err != nil {
+			return nil, err
+		}
+		result.Kifu.InitialPosition = sfen
+	}
+
+	// 指し手（moves[0]は開始局面で、指し手を持たない）
+	if err := parseMovesForJKF(jkf.Moves, 0, result.Branches[0], result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func checkKifuFormatJKF(content string) (*jkfKifu, bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "{") {
+		return nil, false
+	}
+	jkf := &jkfKifu{}
+	if err := json.Unmarshal([]byte(content), jkf); err != nil {
+		return nil, false
+	}
+	// 指し手のリストがあればフォーマット適合とする
+	if jkf.Moves == nil {
+		return nil, false
+	}
+	return jkf, true
+}
+
+func parseInitialForJKF(initial *jkfInitial) (*model.SFEN, error) {
+	if sfen, ok := presetToSfenJKF[initial.Preset]; ok {
+		return sfen.PSFEN(), nil
+	}
+	if initial.Preset != "OTHER" || initial.Data == nil {
+		return nil, fmt.Errorf("invalid initial preset of jkf: %s", initial.Preset)
+	}
+
+	position, err := model.NewBoardPosition(model.SfenAllInBox.PSFEN())
+	if err != nil {
+		return nil, err
+	}
+	data := initial.Data
+	position.IsBlackTurn = data.Color == 0
+
+	if len(data.Board) != 9 {
+		return nil, fmt.Errorf("invalid board size of jkf: %d", len(data.Board))
+	}
+	for x, column := range data.Board {
+		if len(column) != 9 {
+			return nil, fmt.Errorf("invalid board size of jkf at file %d", x+1)
+		}
+		for y, piece := range column {
+			if piece.Kind == "" {
+				continue
+			}
+			pieceType, ok := model.PieceTypeFromStringCSA[piece.Kind]
+			if !ok {
+				return nil, fmt.Errorf("invalid piece kind of jkf: %s", piece.Kind)
+			}
+			row, col := model.NewPiecePlaceFromFileRank(x+1, y+1).RowCol()
+			if piece.Color != nil && *piece.Color == 1 {
+				position.WhiteBoard[row][col] = pieceType
+			} else {
+				position.BlackBoard[row][col] = pieceType
+			}
+		}
+	}
+
+	for i, hands := range data.Hands {
+		for kind, cnt := range hands {
+			if cnt <= 0 {
+				continue
+			}
+			pieceType, ok := model.PieceTypeFromStringCSA[kind]
+			if !ok || pieceType&model.PIECE_PROMOTE != 0 {
+				return nil, fmt.Errorf("invalid piece kind in hands of jkf: %s", kind)
+			}
+			if i == 0 {
+				position.BlackHands[pieceType] = int32(cnt)
+			} else {
+				position.WhiteHands[pieceType] = int32(cnt)
+			}
+		}
+	}
+
+	sfen, err := position.ToSFEN(1)
+	if err != nil {
+		return nil, err
+	}
+	return &sfen, nil
+}
+
+// 指し手リストを再帰的にパースする（forksは分岐ブランチとして追加）
+func parseMovesForJKF(moves []*jkfMoveFormat, firstNumber int64, branch *model.KifuBranchWithMoves, result *model.ParsedKifu) error {
+	for i, moveFormat := range moves {
+		number := firstNumber + int64(i)
+
+		// この手に変わる分岐は、ひとつ前の手から分岐する
+		for _, fork := range moveFormat.Forks {
+			rootBranch, err := forkRootBranchForJKF(branch, number-1, result)
+			if err != nil {
+				return err
+			}
+			rootBranchID := rootBranch.ID
+			forkBranch := &model.KifuBranchWithMoves{
+				KifuBranch: &model.KifuBranch{
+					ID:           auxi.NewULID(), // dummy id
+					KifuID:       branch.KifuID,
+					RootBranchID: &rootBranchID,
+					RootNumber:   auxi.PInt64(number - 1),
+				},
+			}
+			result.Branches = append(result.Branches, forkBranch)
+			if err := parseMovesForJKF(fork, number, forkBranch, result); err != nil {
+				return err
+			}
+		}
+
+		var comment *string
+		if len(moveFormat.Comments) > 0 {
+			comment = auxi.PString(strings.Join(moveFormat.Comments, "\n"))
+		}
+
+		switch {
+		case moveFormat.Special != "": // エンディング
+			if ending, ok := model.EndingNameToEndingTypeCSA[moveFormat.Special]; ok {
+				branch.EndingNumber = auxi.PInt64(number)
+				branch.EndingType = &ending
+				branch.EndingComment = comment
+			}
+			return nil
+		case moveFormat.Move != nil: // 指し手
+			move, err := parseMoveForJKF(moveFormat.Move, number, branch.ID)
+			if err != nil {
+				return err
+			}
+			move.Comment = comment
+			if moveFormat.Time != nil && moveFormat.Time.Now != nil {
+				t := moveFormat.Time.Now
+				move.TimeSpentMs = auxi.PInt64(int64((t.H*3600 + t.M*60 + t.S) * 1000))
+			}
+			branch.Moves = append(branch.Moves, move)
+		default:
+			// 開始局面（コメントのみ）は指し手として扱わない
+			if number > 0 {
+				return fmt.Errorf("move is missing at %d", number)
+			}
+		}
+	}
+	return nil
+}
+
+// rootNumber手目を含むブランチ（分岐の初手に変わる分岐は、分岐元のブランチから分岐する）
+// 初手に変わる分岐はメインラインの分岐として表せないため、エラーとする
+func forkRootBranchForJKF(branch *model.KifuBranchWithMoves, rootNumber int64, result *model.ParsedKifu) (*model.KifuBranchWithMoves, error) {
+	for branch.RootBranchID != nil && *branch.RootNumber >= rootNumber {
+		branch = model.FindBranch(result.Branches, *branch.RootBranchID)
+		if branch == nil {
+			return nil, fmt.Errorf("root branch is missing at %d", rootNumber+1)
+		}
+	}
+	if rootNumber < 1 {
+		return nil, fmt.Errorf("variation of the first move is not supported")
+	}
+	return branch, nil
+}
+
+func parseMoveForJKF(jkfMove *jkfMove, number int64, branchID string) (*model.KifuMove, error) {
+	piece, ok := model.PieceTypeFromStringCSA[jkfMove.Piece]
+	if !ok {
+		return nil, fmt.Errorf("invalid piece type of jkf: %s", jkfMove.Piece)
+	}
+	if jkfMove.Promote != nil && *jkfMove.Promote {
+		piece = piece | model.PIECE_PROMOTE
+	}
+
+	fromPlace := model.PIECE_PLACE_IN_HAND
+	if jkfMove.From != nil {
+		if !isValidPlaceJKF(jkfMove.From) {
+			return nil, fmt.Errorf("invalid from place of jkf: %d%d", jkfMove.From.X, jkfMove.From.Y)
+		}
+		fromPlace = model.NewPiecePlaceFromFileRank(jkfMove.From.X, jkfMove.From.Y)
+	}
+	if jkfMove.To == nil || !isValidPlaceJKF(jkfMove.To) {
+		return nil, fmt.Errorf("invalid to place of jkf at move %d", number)
+	}
+	toPlace := model.NewPiecePlaceFromFileRank(jkfMove.To.X, jkfMove.To.Y)
+
+	move := &model.KifuMove{
+		BranchID:  branchID,
+		Number:    number,
+		Piece:     piece,
+		FromPlace: fromPlace,
+		ToPlace:   toPlace,
+	}
+	return move, nil
+}
+
+func isValidPlaceJKF(place *jkfPlace) bool {
+	return place.X >= 1 && place.X <= 9 && place.Y >= 1 && place.Y <= 9
+}
@@ -0,0 +1,108 @@
+// service/api/parser/jkf_test.go
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 分岐の分岐元（ブランチのインデックスと手数）
+type jkfForkRoot struct {
+	root   int
+	number int64
+}
+
+func jkfForkRoots(t *testing.T, result *model.ParsedKifu) []jkfForkRoot {
+	t.Helper()
+	index := make(map[string]int, len(result.Branches))
+	for i, branch := range result.Branches {
+		index[branch.ID] = i
+	}
+	roots := []jkfForkRoot{}
+	for _, branch := range result.Branches[1:] {
+		if branch.RootBranchID == nil || branch.RootNumber == nil {
+			t.Fatalf("variation has no root: %+v", branch.KifuBranch)
+		}
+		roots = append(roots, jkfForkRoot{root: index[*branch.RootBranchID], number: *branch.RootNumber})
+	}
+	return roots
+}
+
+func TestParseFromJKFForks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []jkfForkRoot
+	}{
+		{
+			name: "nested fork",
+			// 1.76歩 2.34歩 3.26歩（変化 3.22角成 4.同銀 （変化 4.同飛））
+			content: `{"header":{},"moves":[{},
+				{"move":{"color":0,"from":{"x":7,"y":7},"to":{"x":7,"y":6},"piece":"FU"}},
+				{"move":{"color":1,"from":{"x":3,"y":3},"to":{"x":3,"y":4},"piece":"FU"}},
+				{"move":{"color":0,"from":{"x":2,"y":7},"to":{"x":2,"y":6},"piece":"FU"},"forks":[[
+					{"move":{"color":0,"from":{"x":8,"y":8},"to":{"x":2,"y":2},"piece":"KA","promote":true}},
+					{"move":{"color":1,"from":{"x":3,"y":1},"to":{"x":2,"y":2},"piece":"GI"},"forks":[[
+						{"move":{"color":1,"from":{"x":8,"y":2},"to":{"x":2,"y":2},"piece":"HI"}}
+					]]}
+				]]}
+			]}`,
+			want: []jkfForkRoot{{root: 0, number: 2}, {root: 1, number: 3}},
+		},
+		{
+			name: "fork on the first move of a variation",
+			// 1.76歩 2.34歩 3.26歩（変化 3.66歩 （変化 3.56歩））
+			content: `{"header":{},"moves":[{},
+				{"move":{"color":0,"from":{"x":7,"y":7},"to":{"x":7,"y":6},"piece":"FU"}},
+				{"move":{"color":1,"from":{"x":3,"y":3},"to":{"x":3,"y":4},"piece":"FU"}},
+				{"move":{"color":0,"from":{"x":2,"y":7},"to":{"x":2,"y":6},"piece":"FU"},"forks":[[
+					{"move":{"color":0,"from":{"x":6,"y":7},"to":{"x":6,"y":6},"piece":"FU"},"forks":[[
+						{"move":{"color":0,"from":{"x":5,"y":7},"to":{"x":5,"y":6},"piece":"FU"}}
+					]]}
+				]]}
+			]}`,
+			want: []jkfForkRoot{{root: 0, number: 2}, {root: 0, number: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFromJKF(tt.content)
+			if err != nil {
+				t.Fatalf("ParseFromJKF() error = %v", err)
+			}
+			if result == nil {
+				t.Fatal("ParseFromJKF() returned nil")
+			}
+			got := jkfForkRoots(t, result)
+			if len(got) != len(tt.want) {
+				t.Fatalf("forks = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("fork[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+			// 分岐元のブランチは分岐する手を含む
+			for i, branch := range result.Branches[1:] {
+				root := result.Branches[got[i].root]
+				if root.MoveAt(*branch.RootNumber) == nil {
+					t.Errorf("fork[%d]: root branch has no move %d", i, *branch.RootNumber)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFromJKFFirstMoveFork(t *testing.T) {
+	// 1.76歩（変化 1.26歩）
+	content := `{"header":{},"moves":[{},
+		{"move":{"color":0,"from":{"x":7,"y":7},"to":{"x":7,"y":6},"piece":"FU"},"forks":[[
+			{"move":{"color":0,"from":{"x":2,"y":7},"to":{"x":2,"y":6},"piece":"FU"}}
+		]]}
+	]}`
+	if _, err := ParseFromJKF(content); err == nil {
+		t.Error("ParseFromJKF() error = nil, want error for a fork of the first move")
+	}
+}
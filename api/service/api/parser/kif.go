@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jcytp/kifup-api/common/auxi"
 	"github.com/jcytp/kifup-api/service/model"
@@ -45,7 +46,10 @@ func ParseFromKIF(content string) (*model.ParsedKifu, error) {
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		if strings.Contains(line, "：") {
+		if strings.HasPrefix(line, "*") {
+			// 指し手のコメント行（初手より前の開始局面へのコメントは無視）
+			appendMoveCommentForKIF(line, result.Branches[0])
+		} else if strings.Contains(line, "：") {
 			// 棋譜情報の行
 			if err := parseGameInfoLineForKIF(line, result, tmpTimeRule); err != nil {
 				return nil, err
@@ -58,13 +62,30 @@ func ParseFromKIF(content string) (*model.ParsedKifu, error) {
 			nextNumber++
 		}
 		// その他は無視
-		// 空行、コメント行、テーブルヘッダー行、勝敗宣言の行
+		// 空行、テーブルヘッダー行、勝敗宣言の行
 	}
 	result.Kifu.TimeRule = tmpTimeRule.GetTimeRule()
 
 	return result, nil
 }
 
+const kifMoveCommentMaxLength = 1000 // kifu_movesのコメントの上限
+
+func appendMoveCommentForKIF(line string, resultBranch *model.KifuBranchWithMoves) {
+	if len(resultBranch.Moves) == 0 {
+		return
+	}
+	move := resultBranch.Moves[len(resultBranch.Moves)-1]
+	comment, _ := strings.CutPrefix(line, "*")
+	if move.Comment != nil {
+		comment = *move.Comment + "\n" + comment
+	}
+	if utf8.RuneCountInString(comment) > kifMoveCommentMaxLength {
+		return
+	}
+	move.Comment = &comment
+}
+
 func checkKifuFormatKIF(lines []string) bool {
 	branch := &model.KifuBranchWithMoves{
 		KifuBranch: &model.KifuBranch{
@@ -332,8 +353,8 @@ func parseMoveLineForKIF(line string, resultBranch *model.KifuBranchWithMoves, l
 				return err
 			}
 		}
-		seconds += hours*3600 + minutes*60
-		timeSpent = &seconds
+		milliseconds := (seconds + hours*3600 + minutes*60) * 1000
+		timeSpent = &milliseconds
 	}
 
 	// 指し手の追加
@@ -0,0 +1,169 @@
+// service/api/parser/usi.go
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// USIの駒打ちの駒種（先後とも大文字）
+var pieceTypeOfUSIDrop = map[byte]model.PieceType{
+	'P': model.PIECE_FU,
+	'L': model.PIECE_KY,
+	'N': model.PIECE_KE,
+	'S': model.PIECE_GI,
+	'G': model.PIECE_KI,
+	'B': model.PIECE_KA,
+	'R': model.PIECE_HI,
+}
+
+// USIのpositionコマンド（position startpos|sfen ... moves ...）をパースする
+func ParseFromUSI(content string) (*model.ParsedKifu, error) {
+	tokens, ok := checkKifuFormatUSI(content)
+	if !ok {
+		return nil, nil
+	}
+
+	kifuID := auxi.NewULID()       // dummy id
+	mainBranchID := auxi.NewULID() // dummy id
+	result := &model.ParsedKifu{
+		Kifu: &model.Kifu{
+			Title:           "新規棋譜",
+			IsPublic:        false,
+			InitialPosition: model.SfenHirate.PSFEN(),
+		},
+		Options: []*model.KifuOption{},
+		Branches: []*model.KifuBranchWithMoves{
+			{
+				KifuBranch: &model.KifuBranch{
+					ID:           mainBranchID,
+					KifuID:       kifuID,
+					RootBranchID: nil,
+					RootNumber:   nil,
+				},
+			},
+		},
+	}
+
+	// 開始局面
+	switch tokens[0] {
+	case "startpos":
+		tokens = tokens[1:]
+	case "sfen":
+		if len(tokens) < 4 {
+			return nil, fmt.Errorf("invalid sfen of usi: too few parts")
+		}
+		sfenParts := tokens[1:4]
+		tokens = tokens[4:]
+		if len(tokens) > 0 && tokens[0] != "moves" { // 手数（省略可）
+			sfenParts = append(sfenParts, tokens[0])
+			tokens = tokens[1:]
+		} else {
+			sfenParts = append(sfenParts, "1")
+		}
+		result.Kifu.InitialPosition = model.SFEN(strings.Join(sfenParts, " ")).PSFEN()
+	}
+	position, err := model.NewBoardPosition(result.Kifu.InitialPosition)
+	if err != nil {
+		return nil, err
+	}
+
+	// 指し手
+	if len(tokens) == 0 {
+		return result, nil
+	}
+	if tokens[0] != "moves" {
+		return nil, fmt.Errorf("unexpected token of usi: %s", tokens[0])
+	}
+	mainBranch := result.Branches[0]
+	for i, usiMove := range tokens[1:] {
+		number := int64(i + 1)
+		move, err := parseMoveForUSI(usiMove, number, position)
+		if err != nil {
+			return nil, err
+		}
+		if err := position.Move(move); err != nil {
+			return nil, fmt.Errorf("illegal move of usi at %d: %s (%v)", number, usiMove, err)
+		}
+		move.BranchID = mainBranch.ID
+		mainBranch.Moves = append(mainBranch.Moves, move)
+	}
+
+	return result, nil
+}
+
+// "position"を除いたトークン列を返す
+func checkKifuFormatUSI(content string) ([]string, bool) {
+	tokens := strings.Fields(content)
+	if len(tokens) > 0 && tokens[0] == "position" {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 || (tokens[0] != "startpos" && tokens[0] != "sfen") {
+		return nil, false
+	}
+	return tokens, true
+}
+
+// 7g7f, 8h2b+, P*5e の形式の指し手を局面に対してKifuMoveに変換する
+func parseMoveForUSI(usiMove string, number int64, position *model.BoardPosition) (*model.KifuMove, error) {
+	promote := strings.HasSuffix(usiMove, "+")
+	notation := strings.TrimSuffix(usiMove, "+")
+	if len(notation) != 4 {
+		return nil, fmt.Errorf("invalid move of usi at %d: %s", number, usiMove)
+	}
+
+	toPlace, ok := parsePlaceForUSI(notation[2:4])
+	if !ok {
+		return nil, fmt.Errorf("invalid move of usi at %d: %s", number, usiMove)
+	}
+	move := &model.KifuMove{
+		Number:  number,
+		ToPlace: toPlace,
+	}
+
+	if notation[1] == '*' { // 駒打ち
+		piece, ok := pieceTypeOfUSIDrop[notation[0]]
+		if !ok || promote {
+			return nil, fmt.Errorf("invalid drop of usi at %d: %s", number, usiMove)
+		}
+		move.Piece = piece
+		move.FromPlace = model.PIECE_PLACE_IN_HAND
+		return move, nil
+	}
+
+	fromPlace, ok := parsePlaceForUSI(notation[0:2])
+	if !ok {
+		return nil, fmt.Errorf("invalid move of usi at %d: %s", number, usiMove)
+	}
+	row, col := fromPlace.RowCol()
+	piece := position.WhiteBoard[row][col]
+	if position.IsBlackTurn {
+		piece = position.BlackBoard[row][col]
+	}
+	if piece == model.PIECE_VACANCY {
+		return nil, fmt.Errorf("no piece to move of usi at %d: %s", number, usiMove)
+	}
+	if promote {
+		if piece&model.PIECE_PROMOTE != 0 || piece == model.PIECE_KI || piece == model.PIECE_OU {
+			return nil, fmt.Errorf("invalid promotion of usi at %d: %s", number, usiMove)
+		}
+		piece = piece | model.PIECE_PROMOTE
+	}
+	move.Piece = piece
+	move.FromPlace = fromPlace
+	return move, nil
+}
+
+// "7g" -> 7筋7段
+func parsePlaceForUSI(s string) (model.PiecePlace, bool) {
+	file := int(s[0] - '0')
+	rank := int(s[1]-'a') + 1
+	if file < 1 || file > 9 || rank < 1 || rank > 9 {
+		return 0, false
+	}
+	return model.NewPiecePlaceFromFileRank(file, rank), true
+}
@@ -0,0 +1,70 @@
+// service/api/parser/usi_test.go
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func TestParseFromUSI(t *testing.T) {
+	result, err := ParseFromUSI("position startpos moves 7g7f 3c3d 8h2b+ 3a2b B*4e")
+	if err != nil {
+		t.Fatalf("ParseFromUSI() error = %v", err)
+	}
+	if result == nil {
+		t.Fatal("ParseFromUSI() returned nil")
+	}
+	want := []struct {
+		piece model.PieceType
+		from  model.PiecePlace
+		to    model.PiecePlace
+	}{
+		{model.PIECE_FU, model.NewPiecePlaceFromFileRank(7, 7), model.NewPiecePlaceFromFileRank(7, 6)},
+		{model.PIECE_FU, model.NewPiecePlaceFromFileRank(3, 3), model.NewPiecePlaceFromFileRank(3, 4)},
+		{model.PIECE_KA | model.PIECE_PROMOTE, model.NewPiecePlaceFromFileRank(8, 8), model.NewPiecePlaceFromFileRank(2, 2)},
+		{model.PIECE_GI, model.NewPiecePlaceFromFileRank(3, 1), model.NewPiecePlaceFromFileRank(2, 2)},
+		{model.PIECE_KA, model.PIECE_PLACE_IN_HAND, model.NewPiecePlaceFromFileRank(4, 5)},
+	}
+	moves := result.Branches[0].Moves
+	if len(moves) != len(want) {
+		t.Fatalf("moves = %d, want %d", len(moves), len(want))
+	}
+	for i, move := range moves {
+		if move.Number != int64(i+1) || move.Piece != want[i].piece || move.FromPlace != want[i].from || move.ToPlace != want[i].to {
+			t.Errorf("move[%d] = %+v, want %+v", i, *move, want[i])
+		}
+	}
+}
+
+func TestParseFromUSISfen(t *testing.T) {
+	result, err := ParseFromUSI("sfen lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1 moves 3c3d")
+	if err != nil {
+		t.Fatalf("ParseFromUSI() error = %v", err)
+	}
+	if got := *result.Kifu.InitialPosition; got != "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1" {
+		t.Errorf("initial position = %s", got)
+	}
+	if len(result.Branches[0].Moves) != 1 {
+		t.Errorf("moves = %d, want 1", len(result.Branches[0].Moves))
+	}
+}
+
+func TestParseFromUSIErrors(t *testing.T) {
+	for _, content := range []string{
+		"position startpos moves 7g7",   // 指し手の形式
+		"position startpos moves 5e5d",  // 駒の無い場所
+		"position startpos moves 5i5h+", // 成れない駒
+		"position startpos moves 6i5i",  // 自分の駒がある場所
+		"position sfen 9/9 b",           // SFENの要素の不足
+	} {
+		if _, err := ParseFromUSI(content); err == nil {
+			t.Errorf("ParseFromUSI(%q) error = nil, want error", content)
+		}
+	}
+	// USI以外はフォーマット対象外
+	if result, err := ParseFromUSI("先手：藤井"); result != nil || err != nil {
+		t.Errorf("ParseFromUSI() = %v, %v, want nil, nil", result, err)
+	}
+}
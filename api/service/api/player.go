@@ -0,0 +1,490 @@
+// service/api/player.go
+
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+const (
+	playerCandidateLimit = 5  // 確認待ちに記録する候補の最大数
+	playerOpeningLimit   = 10 // 対局者のページ・統計に含める戦型（タグ）の数
+	playerOpponentLimit  = 10 // 対局者の統計に含める相手の数
+)
+
+// 棋譜上の名前から対局者を照合する
+//  1. 名前または段級位・タイトルを除いた名前が別名と一致すれば、その対局者
+//  2. 別名が前方一致する対局者がいれば（姓のみ・段級位付きなど）、候補として確認待ちにする
+//  3. 候補も無ければ、新しい対局者として登録する
+//
+// 非公開の棋譜では1のみ行う（非公開の棋譜の名前から対局者を登録しない）
+func resolveKifuPlayer(tx *db.Tx, player *model.KifuPlayer, allowCreate bool) error {
+	baseName := model.StripPlayerTitle(player.Name)
+	baseKey := model.PlayerNameKey(baseName)
+	for _, key := range []string{player.NameKey, baseKey} {
+		playerID, err := dao.GetPlayerIDByAliasKey(tx, key)
+		if err != nil {
+			return err
+		}
+		if playerID != nil {
+			player.PlayerID = playerID
+			return nil
+		}
+	}
+	if !allowCreate {
+		return nil
+	}
+
+	candidateIDs, err := dao.ListPlayerIDsByAliasKeyPrefix(tx, baseKey, playerCandidateLimit)
+	if err != nil {
+		return err
+	}
+	if len(candidateIDs) > 0 {
+		player.CandidateIDs = candidateIDs
+		return nil
+	}
+
+	playerID, err := insertPlayerWithAliases(tx, baseName, player.Name)
+	if err != nil {
+		return err
+	}
+	player.PlayerID = &playerID
+	return nil
+}
+
+// 対局者を登録し、表示名と棋譜上の名前を別名として登録する
+func insertPlayerWithAliases(tx *db.Tx, name string, aliases ...string) (string, error) {
+	playerID, err := dao.InsertPlayer(tx, &model.Player{Name: name})
+	if err != nil {
+		return "", err
+	}
+	for _, alias := range append([]string{name}, aliases...) {
+		err := dao.InsertPlayerAliasIfNotExists(tx, &model.PlayerAlias{
+			AliasKey: model.PlayerNameKey(alias),
+			Alias:    model.NormalizePlayerName(alias),
+			PlayerID: playerID,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return playerID, nil
+}
+
+// 棋譜の先手・後手の名前を対局者と照合し、勝敗とともに記録する（棋譜の作成・更新のトランザクション内で呼ぶ）
+func linkKifuPlayers(tx *db.Tx, kifuID string) error {
+	record, err := dao.GetKifuGameRecord(tx, kifuID)
+	if err != nil {
+		return err
+	}
+
+	players := make([]*model.KifuPlayer, 0, 2)
+	for side, name := range []*string{record.BlackPlayer, record.WhitePlayer} {
+		if name == nil {
+			continue
+		}
+		player := &model.KifuPlayer{
+			KifuID:    kifuID,
+			Side:      model.PlayerSide(side),
+			Name:      model.NormalizePlayerName(*name),
+			NameKey:   model.PlayerNameKey(*name),
+			Result:    record.Result(model.PlayerSide(side)),
+			MoveCount: record.MoveCount(),
+		}
+		if player.NameKey == "" {
+			continue
+		}
+		if err := resolveKifuPlayer(tx, player, record.IsPublic); err != nil {
+			return err
+		}
+		players = append(players, player)
+	}
+	return dao.ReplaceKifuPlayers(tx, kifuID, players)
+}
+
+// 対局者の対応が無い棋譜を照合する（対局者のテーブル追加前の棋譜の移行）
+func linkKifuPlayersWithoutPlayers() error {
+	kifuIDs, err := dao.ListKifuIDsWithoutPlayers()
+	if err != nil {
+		return err
+	}
+	for _, kifuID := range kifuIDs {
+		err := db.Transaction(func(tx *db.Tx) error {
+			return linkKifuPlayers(tx, kifuID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// 対局者のページ（公開棋譜の対局と、先手・後手の成績・戦型・対局期間）
+func GetPlayer(c *gin.Context, pgreq *handler.PaginationRequest) (*model.PlayerDetailResponse, *handler.PaginatedResponse, string, error) {
+	playerID := c.GetString("playerID")
+	limit, offset := pgreq.LimitOffset()
+
+	player, err := dao.GetPlayer(playerID)
+	if err != nil {
+		return nil, nil, "Failed to get player", err
+	}
+	aliases, err := dao.ListPlayerAliasesByPlayerIDs([]string{playerID})
+	if err != nil {
+		return nil, nil, "Failed to get player aliases", err
+	}
+	response := &model.PlayerDetailResponse{
+		PlayerResponse: player.ToResponse(aliases[playerID]),
+	}
+
+	// 成績・対局期間・戦型
+	records, msg, err := getPlayerSideRecords(playerID, &dao.PlayerStatsCondition{})
+	if err != nil {
+		return nil, nil, msg, err
+	}
+	response.Total, response.Black, response.White = model.NewPlayerRecordResponses(records)
+	if response.FirstStartedAt, response.LastStartedAt, err = dao.GetPlayerStartedRange(playerID); err != nil {
+		return nil, nil, "Failed to get player games", err
+	}
+	openings, err := dao.ListPlayerTagCounts(playerID, playerOpeningLimit)
+	if err != nil {
+		return nil, nil, "Failed to get player openings", err
+	}
+	response.Openings = make([]*model.TagCountResponse, 0, len(openings))
+	for _, opening := range openings {
+		response.Openings = append(response.Openings, opening.ToResponse())
+	}
+
+	// 対局（対局日時の新しい順）
+	cond := &dao.KifuSearchCondition{
+		PublicOnly: true,
+		PlayerID:   &playerID,
+		Sort:       model.KIFU_SORT_STARTED,
+	}
+	totalCount, err := dao.CountKifusByCondition(cond)
+	if err != nil {
+		return nil, nil, "Failed to get player games", err
+	}
+	kifus, _, err := dao.ListKifusByCondition(cond, limit, offset)
+	if err != nil {
+		return nil, nil, "Failed to get player games", err
+	}
+	summaries, msg, err := newKifuSummaryResponses(kifus)
+	if err != nil {
+		return nil, nil, msg, err
+	}
+	kifuIDs := make([]string, len(kifus))
+	for i, kifu := range kifus {
+		kifuIDs[i] = kifu.ID
+	}
+	kifuPlayers, err := dao.ListKifuPlayersByKifuIDs(kifuIDs)
+	if err != nil {
+		return nil, nil, "Failed to get player games", err
+	}
+	response.Games = make([]*model.PlayerGameResponse, 0, len(summaries))
+	for _, summary := range summaries {
+		var self, opponent *model.KifuPlayer
+		for _, kifuPlayer := range kifuPlayers[summary.ID] {
+			if self == nil && kifuPlayer.PlayerID != nil && *kifuPlayer.PlayerID == playerID {
+				self = kifuPlayer
+			} else {
+				opponent = kifuPlayer
+			}
+		}
+		if self == nil {
+			return nil, nil, "Failed to get player games", fmt.Errorf("kifu player not found: %s", summary.ID)
+		}
+		response.Games = append(response.Games, self.ToGameResponse(summary, opponent))
+	}
+
+	return response, pgreq.NewPaginatedResponse(totalCount), "", nil
+}
+
+// 古い集計が残っている場合のみ集計し直す（通常は対応を変えた書き込みで集計し直している）
+// 読み込みのたびに書き込みのトランザクション（DBの書き込みロック）を取らないよう、先に有無を確認する
+func refreshStalePlayerStats() (string, error) {
+	stale, err := dao.HasStalePlayerStats()
+	if err != nil {
+		return "Failed to check player stats", err
+	}
+	if !stale {
+		return "", nil
+	}
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.RefreshStalePlayerStats(tx); err != nil {
+			return "Failed to refresh player stats", err
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+// 対局者の先手・後手ごとの成績
+// 条件が無い場合は集計済みの値（集計が古い対局者は集計し直す）、条件がある場合はその都度集計する
+func getPlayerSideRecords(playerID string, cond *dao.PlayerStatsCondition) (map[model.PlayerSide]*model.PlayerRecord, string, error) {
+	if !cond.IsEmpty() {
+		records, err := dao.ListPlayerSideRecords(playerID, cond)
+		if err != nil {
+			return nil, "Failed to get player stats", err
+		}
+		return records, "", nil
+	}
+
+	if msg, err := refreshStalePlayerStats(); err != nil {
+		return nil, msg, err
+	}
+	stats, err := dao.ListPlayerStatsByPlayerIDs([]string{playerID})
+	if err != nil {
+		return nil, "Failed to get player stats", err
+	}
+	records := map[model.PlayerSide]*model.PlayerRecord{}
+	for _, stat := range stats[playerID] {
+		records[stat.Side] = &stat.PlayerRecord
+	}
+	return records, "", nil
+}
+
+// 対局者の一覧（公開棋譜の対局数の多い順、nameは別名の前方一致）
+func ListPlayers(c *gin.Context, pgreq *handler.PaginationRequest) ([]*model.PlayerSummaryResponse, *handler.PaginatedResponse, string, error) {
+	limit, offset := pgreq.LimitOffset()
+	nameKey := model.PlayerNameKey(c.Query("name"))
+	if len([]rune(nameKey)) > 100 {
+		return nil, nil, "Invalid name", fmt.Errorf("name is too long: %s", nameKey)
+	}
+
+	// 一覧は集計済みの値で並べる
+	if msg, err := refreshStalePlayerStats(); err != nil {
+		return nil, nil, msg, err
+	}
+	totalCount, err := dao.CountPlayersByStats(nameKey)
+	if err != nil {
+		return nil, nil, "Failed to get players", err
+	}
+	players, err := dao.ListPlayersByStats(nameKey, limit, offset)
+	if err != nil {
+		return nil, nil, "Failed to get players", err
+	}
+
+	playerIDs := make([]string, len(players))
+	for i, player := range players {
+		playerIDs[i] = player.ID
+	}
+	aliases, err := dao.ListPlayerAliasesByPlayerIDs(playerIDs)
+	if err != nil {
+		return nil, nil, "Failed to get player aliases", err
+	}
+	stats, err := dao.ListPlayerStatsByPlayerIDs(playerIDs)
+	if err != nil {
+		return nil, nil, "Failed to get player stats", err
+	}
+
+	response := make([]*model.PlayerSummaryResponse, 0, len(players))
+	for _, player := range players {
+		records := map[model.PlayerSide]*model.PlayerRecord{}
+		for _, stat := range stats[player.ID] {
+			records[stat.Side] = &stat.PlayerRecord
+		}
+		summary := &model.PlayerSummaryResponse{PlayerResponse: player.ToResponse(aliases[player.ID])}
+		summary.Total, summary.Black, summary.White = model.NewPlayerRecordResponses(records)
+		response = append(response, summary)
+	}
+	return response, pgreq.NewPaginatedResponse(totalCount), "", nil
+}
+
+// 対局者の統計（公開棋譜のうち、対局日の範囲・タグで絞り込んだ対局）
+// クエリ: started_from, started_to（YYYY-MM-DD、この日を含む）, tags（複数指定可、全てを持つ棋譜）
+func GetPlayerStats(c *gin.Context) (*model.PlayerStatsResponse, string, error) {
+	playerID := c.GetString("playerID")
+	if _, err := dao.GetPlayer(playerID); err != nil {
+		return nil, "Player not found", err
+	}
+
+	cond := &dao.PlayerStatsCondition{}
+	if s := c.Query("started_from"); s != "" {
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return nil, "Invalid date", err
+		}
+		cond.StartedFrom = &s
+	}
+	if s := c.Query("started_to"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, "Invalid date", err
+		}
+		before := t.AddDate(0, 0, 1).Format("2006-01-02")
+		cond.StartedBefore = &before
+	}
+	if len(c.QueryArray("tags")) > 10 {
+		return nil, "Too many tags", fmt.Errorf("too many tags: %d", len(c.QueryArray("tags")))
+	}
+	tags, msg, err := normalizeKifuTags(c.QueryArray("tags"))
+	if err != nil {
+		return nil, msg, err
+	}
+	for _, tag := range tags {
+		cond.TagKeys = append(cond.TagKeys, model.TagKey(tag))
+	}
+
+	response := &model.PlayerStatsResponse{PlayerID: playerID}
+	records, msg, err := getPlayerSideRecords(playerID, cond)
+	if err != nil {
+		return nil, msg, err
+	}
+	response.Total, response.Black, response.White = model.NewPlayerRecordResponses(records)
+
+	openings, err := dao.ListPlayerOpeningRecords(playerID, cond, playerOpeningLimit)
+	if err != nil {
+		return nil, "Failed to get player openings", err
+	}
+	response.Openings = make([]*model.PlayerOpeningResponse, 0, len(openings))
+	for _, opening := range openings {
+		response.Openings = append(response.Openings, opening.ToResponse())
+	}
+
+	opponents, err := dao.ListPlayerOpponentRecords(playerID, cond, playerOpponentLimit)
+	if err != nil {
+		return nil, "Failed to get player opponents", err
+	}
+	response.Opponents = make([]*model.PlayerOpponentResponse, 0, len(opponents))
+	for _, opponent := range opponents {
+		response.Opponents = append(response.Opponents, opponent.ToResponse())
+	}
+	return response, "", nil
+}
+
+// ------------------------------------------------------------
+// 確認待ちの対局者の一覧（管理者のみ）
+func ListPlayerReviews(c *gin.Context) ([]*model.PlayerReviewResponse, string, error) {
+	reviews, err := dao.ListPlayerReviews(100)
+	if err != nil {
+		return nil, "Failed to get player reviews", err
+	}
+
+	candidateIDs := []string{}
+	for _, review := range reviews {
+		candidateIDs = append(candidateIDs, review.CandidateIDs...)
+	}
+	players, err := dao.ListPlayersByIDs(candidateIDs)
+	if err != nil {
+		return nil, "Failed to get players", err
+	}
+	aliases, err := dao.ListPlayerAliasesByPlayerIDs(candidateIDs)
+	if err != nil {
+		return nil, "Failed to get player aliases", err
+	}
+
+	response := make([]*model.PlayerReviewResponse, 0, len(reviews))
+	for _, review := range reviews {
+		candidates := make([]*model.PlayerResponse, 0, len(review.CandidateIDs))
+		for _, candidateID := range review.CandidateIDs {
+			if player, ok := players[candidateID]; ok {
+				candidates = append(candidates, player.ToResponse(aliases[candidateID]))
+			}
+		}
+		response = append(response, &model.PlayerReviewResponse{
+			Name:       review.Name,
+			KifuCount:  review.KifuCount,
+			Candidates: candidates,
+		})
+	}
+	return response, "", nil
+}
+
+// ------------------------------------------------------------
+type requestResolvePlayerReview struct {
+	Name     string  `json:"name" binding:"required,min=1,max=100"` // 確認待ちの棋譜上の名前
+	PlayerID *string `json:"player_id"`                             // 対応する対局者（省略時は新しい対局者として登録）
+}
+
+type ResolvePlayerReviewResponse struct {
+	PlayerID  string `json:"player_id"`  // 対応させた対局者
+	KifuCount int64  `json:"kifu_count"` // 対局者を確定した棋譜の数
+}
+
+// 確認待ちの対局者の確定（管理者のみ）
+// 名前を対局者の別名として登録し、同じ名前の未確定の棋譜を対局者に対応させる
+func ResolvePlayerReview(c *gin.Context, req requestResolvePlayerReview) (*ResolvePlayerReviewResponse, string, error) {
+	name := model.NormalizePlayerName(req.Name)
+	nameKey := model.PlayerNameKey(name)
+
+	response := &ResolvePlayerReviewResponse{}
+	msg, err := inTransaction(func(tx *db.Tx) (string, error) {
+		count, err := dao.CountPlayerReviewsByNameKey(tx, nameKey)
+		if err != nil {
+			return "Failed to get player reviews", err
+		}
+		if count == 0 {
+			return "Player review not found", fmt.Errorf("no pending kifu players: %s", name)
+		}
+
+		if req.PlayerID == nil {
+			if response.PlayerID, err = insertPlayerWithAliases(tx, model.StripPlayerTitle(name), name); err != nil {
+				return "Failed to create player", err
+			}
+		} else {
+			response.PlayerID = *req.PlayerID
+			if _, err := dao.GetPlayer(response.PlayerID); err != nil {
+				return "Player not found", err
+			}
+			alias := &model.PlayerAlias{AliasKey: nameKey, Alias: name, PlayerID: response.PlayerID}
+			if err := dao.UpsertPlayerAlias(tx, alias); err != nil {
+				return "Failed to register player alias", err
+			}
+		}
+
+		if response.KifuCount, err = dao.LinkKifuPlayersByNameKey(tx, nameKey, response.PlayerID); err != nil {
+			return "Failed to link kifu players", err
+		}
+		if err := dao.RefreshStalePlayerStats(tx); err != nil {
+			return "Failed to refresh player stats", err
+		}
+		return "", nil
+	})
+	if err != nil {
+		return nil, msg, err
+	}
+	return response, "", nil
+}
+
+// ------------------------------------------------------------
+type requestAddPlayerAlias struct {
+	Alias string `json:"alias" binding:"required,min=1,max=100"` // 棋譜上の名前の表記
+}
+
+// 対局者の別名の登録（管理者のみ、他の対局者の別名の場合は付け替える）
+// 同じ名前の未確定の棋譜も対局者に対応させる
+func AddPlayerAlias(c *gin.Context, req requestAddPlayerAlias) (string, error) {
+	playerID := c.GetString("playerID")
+	alias := &model.PlayerAlias{
+		AliasKey: model.PlayerNameKey(req.Alias),
+		Alias:    model.NormalizePlayerName(req.Alias),
+		PlayerID: playerID,
+	}
+	if alias.AliasKey == "" {
+		return "Invalid alias", fmt.Errorf("empty player alias: %q", req.Alias)
+	}
+	if _, err := dao.GetPlayer(playerID); err != nil {
+		return "Player not found", err
+	}
+
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.UpsertPlayerAlias(tx, alias); err != nil {
+			return "Failed to register player alias", err
+		}
+		if _, err := dao.LinkKifuPlayersByNameKey(tx, alias.AliasKey, playerID); err != nil {
+			return "Failed to link kifu players", err
+		}
+		if err := dao.RefreshStalePlayerStats(tx); err != nil {
+			return "Failed to refresh player stats", err
+		}
+		return "", nil
+	})
+}
@@ -0,0 +1,189 @@
+// service/api/saved_search.go
+
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/handler"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+const maxSavedSearches = 20 // アカウントごとに保存できる検索条件の数
+
+type requestCreateSavedSearch struct {
+	Name        string   `json:"name" binding:"required,min=1,max=50"`
+	Keyword     string   `json:"keyword" binding:"max=100"`                   // タイトル・対局者・棋譜情報・タグ・コメント（空白区切りで全てを含む）
+	Tags        []string `json:"tags" binding:"max=10,dive,min=1,max=50"`     // タグ（表記の揺れ・別名を含む）
+	TagMode     string   `json:"tag_mode" binding:"omitempty,oneof=and or"`   // タグの条件（省略時はand）
+	Player      *string  `json:"player" binding:"omitempty,min=1,max=100"`    // 先手・後手のいずれか
+	Position    *string  `json:"position" binding:"omitempty,min=1,max=1000"` // メインラインに現れる局面（SFEN・BOD）
+	EmailDigest bool     `json:"email_digest"`                                // 一致した棋譜をメールでまとめて知らせる
+}
+
+func CreateSavedSearch(c *gin.Context, req requestCreateSavedSearch) (*model.SavedSearchResponse, string, error) {
+	accountID := handler.GetActorID(c)
+
+	count, err := dao.CountSavedSearchesByAccountID(accountID)
+	if err != nil {
+		return nil, "Failed to get saved searches", err
+	}
+	if count >= maxSavedSearches {
+		return nil, "Too many saved searches", fmt.Errorf("too many saved searches: %d (max %d)", count, maxSavedSearches)
+	}
+
+	tags, msg, err := normalizeKifuTags(req.Tags)
+	if err != nil {
+		return nil, msg, err
+	}
+	search := &model.SavedSearch{
+		AccountID:   accountID,
+		Name:        req.Name,
+		Keyword:     strings.Join(strings.Fields(req.Keyword), " "),
+		Tags:        tags,
+		TagMatchAll: req.TagMode != "or",
+		Player:      req.Player,
+		EmailDigest: req.EmailDigest,
+	}
+	if req.Position != nil {
+		sfen, err := parseInitialPosition(req.Position)
+		if err != nil {
+			return nil, "Invalid position", err
+		}
+		position, err := model.NewBoardPosition(sfen)
+		if err != nil {
+			return nil, "Invalid position", err
+		}
+		key, err := position.Key()
+		if err != nil {
+			return nil, "Invalid position", err
+		}
+		search.Position = sfen
+		search.PositionKey = &key
+	}
+	if search.Keyword == "" && len(search.Tags) == 0 && search.Player == nil && search.Position == nil {
+		return nil, "Search condition is required", fmt.Errorf("saved search has no condition")
+	}
+
+	if _, err := dao.InsertSavedSearch(search); err != nil {
+		return nil, "Failed to create saved search", err
+	}
+	return search.ToResponse(), "", nil
+}
+
+func ListSavedSearches(c *gin.Context) (*[]*model.SavedSearchResponse, string, error) {
+	accountID := handler.GetActorID(c)
+
+	searches, err := dao.ListSavedSearchesByAccountID(accountID)
+	if err != nil {
+		return nil, "Failed to get saved searches", err
+	}
+	responses := make([]*model.SavedSearchResponse, 0, len(searches))
+	for _, search := range searches {
+		responses = append(responses, search.ToResponse())
+	}
+	return &responses, "", nil
+}
+
+func DeleteSavedSearch(c *gin.Context) (string, error) {
+	accountID := handler.GetActorID(c)
+	searchID := c.GetString("searchID")
+
+	// 所有者の検索条件のみ削除する（通知も削除される）
+	if err := dao.DeleteSavedSearch(searchID, accountID); err != nil {
+		return "Failed to delete saved search", err
+	}
+	return "", nil
+}
+
+// ------------------------------------------------------------
+
+// 検索条件のうちSQLで絞り込む条件（局面は棋譜を再生して照合する）
+func savedSearchCondition(search *model.SavedSearch, kifuID string) *dao.KifuSearchCondition {
+	return &dao.KifuSearchCondition{
+		KifuIDs:     []string{kifuID},
+		PublicOnly:  true,
+		Keywords:    strings.Fields(search.Keyword),
+		Tags:        search.Tags,
+		TagMatchAll: search.TagMatchAll,
+		Player:      search.Player,
+	}
+}
+
+// 公開された棋譜を他のアカウントの検索条件と照合し、一致した検索条件の所有者に通知する
+// 棋譜を公開した更新のコミット後に、goroutineで呼び出す
+func notifySavedSearches(kifuID string) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Saved search matching panicked", "kifuID", kifuID, "panic", r)
+		}
+	}()
+
+	kifu, err := dao.GetKifu(kifuID)
+	if err != nil {
+		slog.Error("Failed to get kifu", "kifuID", kifuID, "error", err)
+		return
+	}
+	if !kifu.IsPublic {
+		return
+	}
+	searches, err := dao.ListSavedSearchesExceptAccountID(kifu.AccountID)
+	if err != nil {
+		slog.Error("Failed to get saved searches", "kifuID", kifuID, "error", err)
+		return
+	}
+
+	var positionKeys map[string]bool // メインラインの局面（局面の条件がある場合のみ再生する）
+	for _, search := range searches {
+		if search.PositionKey != nil {
+			if positionKeys == nil {
+				positionKeys, err = mainLinePositionKeySet(kifu)
+				if err != nil {
+					slog.Error("Failed to replay kifu", "kifuID", kifuID, "error", err)
+					return
+				}
+			}
+			if !positionKeys[*search.PositionKey] {
+				continue
+			}
+		}
+		count, err := dao.CountKifusByCondition(savedSearchCondition(search, kifuID))
+		if err != nil {
+			slog.Error("Failed to match saved search", "kifuID", kifuID, "searchID", search.ID, "error", err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+
+		notification := &model.Notification{
+			AccountID:     search.AccountID,
+			Type:          model.NOTIFICATION_SAVED_SEARCH,
+			SavedSearchID: &search.ID,
+			KifuID:        kifuID,
+		}
+		if err := dao.InsertNotification(notification); err != nil {
+			slog.Error("Failed to insert notification", "kifuID", kifuID, "searchID", search.ID, "error", err)
+		}
+	}
+}
+
+func mainLinePositionKeySet(kifu *model.Kifu) (map[string]bool, error) {
+	branches, _, err := listKifuBranchesWithMoves(kifu.ID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := kifu.MainLinePositionKeys(branches)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set, nil
+}
@@ -16,6 +16,9 @@ func SetupTables() {
 	if err := dao.CreateAccountTable(); err != nil {
 		log.Fatal("failed to create account table")
 	}
+	if err := dao.CreateAccountPlayerNameTable(); err != nil {
+		log.Fatal("failed to create account player name table")
+	}
 	if err := dao.CreateKifuTable(); err != nil {
 		log.Fatal("failed to create kifu table")
 	}
@@ -25,18 +28,60 @@ func SetupTables() {
 	if err := dao.CreateKifuTagTable(); err != nil {
 		log.Fatal("failed to create kifu tag table")
 	}
+	if err := dao.CreateTagAliasTable(); err != nil {
+		log.Fatal("failed to create tag alias table")
+	}
 	if err := dao.CreateKifuBranchTable(); err != nil {
 		log.Fatal("failed to create kifu branch table")
 	}
 	if err := dao.CreateKifuMoveTable(); err != nil {
 		log.Fatal("failed to create kifu move table")
 	}
+	if err := dao.CreateKifuAnnotationTable(); err != nil {
+		log.Fatal("failed to create kifu annotation table")
+	}
+	if err := fillKifuFingerprints(); err != nil {
+		log.Fatal("failed to fill kifu fingerprints")
+	}
+	if err := dao.CreateKifuSearchIndexTable(); err != nil {
+		log.Fatal("failed to create kifu search index table")
+	}
+	if err := dao.CreatePlayerTable(); err != nil {
+		log.Fatal("failed to create player table")
+	}
+	if err := dao.CreateKifuPlayerTable(); err != nil {
+		log.Fatal("failed to create kifu player table")
+	}
+	if err := dao.CreatePlayerStatsTable(); err != nil {
+		log.Fatal("failed to create player stats table")
+	}
+	if err := linkKifuPlayersWithoutPlayers(); err != nil {
+		log.Fatal("failed to link kifu players")
+	}
 	if err := dao.CreateKifuLikeTable(); err != nil {
 		log.Fatal("failed to create kifu like table")
 	}
 	if err := dao.CreateKifuCommentTable(); err != nil {
 		log.Fatal("failed to create kifu comment table")
 	}
+	if err := dao.CreateKifuRevisionTable(); err != nil {
+		log.Fatal("failed to create kifu revision table")
+	}
+	if err := dao.CreateImportJobTable(); err != nil {
+		log.Fatal("failed to create import job table")
+	}
+	if err := dao.CreateImportJobEntryTable(); err != nil {
+		log.Fatal("failed to create import job entry table")
+	}
+	if err := failInterruptedImportJobs(); err != nil {
+		log.Fatal("failed to mark interrupted import jobs")
+	}
+	if err := dao.CreateSavedSearchTable(); err != nil {
+		log.Fatal("failed to create saved search table")
+	}
+	if err := dao.CreateNotificationTable(); err != nil {
+		log.Fatal("failed to create notification table")
+	}
 }
 
 type GetServerStatusResponse struct {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jcytp/kifup-api/common/db"
 	"github.com/jcytp/kifup-api/common/handler"
 	"github.com/jcytp/kifup-api/service/dao"
 	"github.com/jcytp/kifup-api/service/model"
@@ -25,14 +26,15 @@ func LikeKifu(c *gin.Context) (string, error) {
 	}
 
 	// いいねを追加してカウンターを更新
-	if err := dao.InsertKifuLike(kifuID, accountID); err != nil {
-		return "Failed to insert like", err
-	}
-	if err := dao.IncrementKifuLikeCount(kifuID); err != nil {
-		return "Failed to update like count", err
-	}
-
-	return "", nil
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.InsertKifuLike(tx, kifuID, accountID); err != nil {
+			return "Failed to insert like", err
+		}
+		if err := dao.IncrementKifuLikeCount(tx, kifuID); err != nil {
+			return "Failed to update like count", err
+		}
+		return "", nil
+	})
 }
 
 func UnlikeKifu(c *gin.Context) (string, error) {
@@ -40,14 +42,15 @@ func UnlikeKifu(c *gin.Context) (string, error) {
 	kifuID := c.GetString("kifuID")
 
 	// いいねを削除してカウンターを更新
-	if err := dao.DeleteKifuLike(kifuID, accountID); err != nil {
-		return "Failed to delete kifu like", err
-	}
-	if err := dao.DecrementKifuLikeCount(kifuID); err != nil {
-		return "Failed to update kifu like count", err
-	}
-
-	return "", nil
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.DeleteKifuLike(tx, kifuID, accountID); err != nil {
+			return "Failed to delete kifu like", err
+		}
+		if err := dao.DecrementKifuLikeCount(tx, kifuID); err != nil {
+			return "Failed to update kifu like count", err
+		}
+		return "", nil
+	})
 }
 
 type requestPostKifuComment struct {
@@ -73,15 +76,16 @@ func PostKifuComment(c *gin.Context, req requestPostKifuComment) (string, error)
 		AccountID: accountID,
 		Content:   req.Content,
 	}
-	commentID, err := dao.InsertKifuComment(comment)
-	if err != nil {
-		return "Failed to insert kifu comment", err
-	}
-	if err := dao.IncrementKifuCommentCount(kifuID); err != nil {
-		return "Failed to update kifu comment count", err
-	}
-
-	return commentID, nil
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		commentID, err := dao.InsertKifuComment(tx, comment)
+		if err != nil {
+			return "Failed to insert kifu comment", err
+		}
+		if err := dao.IncrementKifuCommentCount(tx, kifuID); err != nil {
+			return "Failed to update kifu comment count", err
+		}
+		return commentID, nil
+	})
 }
 
 func ListKifuComments(c *gin.Context) (*[]*model.KifuCommentResponse, string, error) {
@@ -103,12 +107,20 @@ func ListKifuComments(c *gin.Context) (*[]*model.KifuCommentResponse, string, er
 		return nil, "Failed to get comments", err
 	}
 
-	// レスポンスを構築（アカウント情報も含める）
+	// レスポンスを構築（コメントしたアカウントの情報はまとめて取得する）
+	accountIDs := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		accountIDs = append(accountIDs, comment.AccountID)
+	}
+	accounts, err := dao.ListAccountsByIDs(accountIDs)
+	if err != nil {
+		return nil, "Failed to get account info", err
+	}
 	responses := make([]*model.KifuCommentResponse, 0, len(comments))
 	for _, comment := range comments {
-		account, err := dao.GetAccountByID(comment.AccountID)
-		if err != nil {
-			return nil, "Failed to get account info", err
+		account, ok := accounts[comment.AccountID]
+		if !ok {
+			return nil, "Failed to get account info", fmt.Errorf("account not found: %s", comment.AccountID)
 		}
 		responses = append(responses, comment.ToResponse(account))
 	}
@@ -0,0 +1,218 @@
+// service/api/tag.go
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/dao"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// タグの表記を揃える
+// 幅の違い等を正規化し、別名は登録済みのタグ名に、既存のタグと同じキーのものは既存の表記に置き換える（重複は除く）
+func normalizeKifuTags(names []string) ([]string, string, error) {
+	normalized := make([]string, 0, len(names))
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		name = model.NormalizeTagName(name)
+		if name == "" {
+			continue
+		}
+		normalized = append(normalized, name)
+		keys = append(keys, model.TagKey(name))
+	}
+
+	aliasNames, err := dao.ListTagAliasNamesByKeys(keys)
+	if err != nil {
+		return nil, "Failed to get tag aliases", err
+	}
+	for i, key := range keys {
+		if name, ok := aliasNames[key]; ok {
+			normalized[i] = name
+			keys[i] = model.TagKey(name)
+		}
+	}
+	existingNames, err := dao.ListKifuTagNamesByKeys(keys)
+	if err != nil {
+		return nil, "Failed to get kifu tags", err
+	}
+
+	tags := make([]string, 0, len(normalized))
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		name := normalized[i]
+		if existing, ok := existingNames[key]; ok {
+			name = existing
+		}
+		if len([]rune(name)) > model.KifuTagMaxLength {
+			return nil, "Tag is too long", fmt.Errorf("tag length exceeds %d: %s", model.KifuTagMaxLength, name)
+		}
+		tags = append(tags, name)
+	}
+	return tags, "", nil
+}
+
+// ------------------------------------------------------------
+// タグ一覧（公開棋譜に付いているタグを多い順に、prefix指定で入力補完）
+func ListTags(c *gin.Context) ([]*model.TagCountResponse, string, error) {
+	limit := 20
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			return nil, "Invalid limit", fmt.Errorf("invalid limit: %s", s)
+		}
+		limit = n
+	}
+	prefix := model.TagKey(c.Query("prefix"))
+	if len([]rune(prefix)) > model.KifuTagMaxLength {
+		return nil, "Invalid prefix", fmt.Errorf("prefix is too long: %s", prefix)
+	}
+
+	tags, err := dao.ListPopularKifuTags(prefix, limit)
+	if err != nil {
+		return nil, "Failed to get tags", err
+	}
+	response := make([]*model.TagCountResponse, 0, len(tags))
+	for _, tag := range tags {
+		response = append(response, tag.ToResponse())
+	}
+	return response, "", nil
+}
+
+// ------------------------------------------------------------
+type requestMergeTags struct {
+	From []string `json:"from" binding:"required,min=1,max=100,dive,min=1,max=50"` // 統合元のタグ名
+	To   string   `json:"to" binding:"required,min=1,max=50"`                      // 統合先のタグ名
+}
+
+type MergeTagsResponse struct {
+	Name       string `json:"name"`        // 統合先のタグ名
+	KifuCount  int    `json:"kifu_count"`  // タグを書き換えた棋譜の数
+	AliasCount int    `json:"alias_count"` // 登録した別名の数
+}
+
+// タグの統合（管理者のみ）
+// 統合元のタグ（表記の揺れを含む）を統合先のタグに書き換え、統合元の表記を統合先の別名として登録する
+func MergeTags(c *gin.Context, req requestMergeTags) (*MergeTagsResponse, string, error) {
+	to := model.NormalizeTagName(req.To)
+	if to == "" {
+		return nil, "Invalid tag name", fmt.Errorf("empty tag name: %q", req.To)
+	}
+	toKey := model.TagKey(to)
+
+	fromKeys := make([]string, 0, len(req.From))
+	fromNames := make([]string, 0, len(req.From))
+	aliases := make([]*model.TagAlias, 0, len(req.From))
+	seen := map[string]bool{toKey: true}
+	for _, name := range req.From {
+		name = model.NormalizeTagName(name)
+		key := model.TagKey(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		fromKeys = append(fromKeys, key)
+		fromNames = append(fromNames, name)
+		aliases = append(aliases, &model.TagAlias{
+			AliasKey: key,
+			Alias:    name,
+			Name:     to,
+		})
+	}
+
+	var kifuIDs []string
+	msg, err := inTransaction(func(tx *db.Tx) (string, error) {
+		var err error
+		kifuIDs, err = dao.MergeKifuTags(tx, fromKeys, to)
+		if err != nil {
+			return "Failed to merge kifu tags", err
+		}
+		if err := dao.RenameTagAliasTargets(tx, fromNames, to); err != nil {
+			return "Failed to update tag aliases", err
+		}
+		for _, alias := range aliases {
+			if err := dao.UpsertTagAlias(tx, alias); err != nil {
+				return "Failed to register tag alias", err
+			}
+		}
+		for _, kifuID := range kifuIDs {
+			if err := dao.RefreshKifuSearchDocument(tx, kifuID); err != nil {
+				return "Failed to update search index", err
+			}
+		}
+		return "", nil
+	})
+	if err != nil {
+		return nil, msg, err
+	}
+
+	return &MergeTagsResponse{
+		Name:       to,
+		KifuCount:  len(kifuIDs),
+		AliasCount: len(aliases),
+	}, "", nil
+}
+
+// ------------------------------------------------------------
+// タグの別名一覧（管理者のみ）
+func ListTagAliases(c *gin.Context) ([]*model.TagAliasResponse, string, error) {
+	aliases, err := dao.ListTagAliases()
+	if err != nil {
+		return nil, "Failed to get tag aliases", err
+	}
+	response := make([]*model.TagAliasResponse, 0, len(aliases))
+	for _, alias := range aliases {
+		response = append(response, alias.ToResponse())
+	}
+	return response, "", nil
+}
+
+// ------------------------------------------------------------
+type requestPutTagAlias struct {
+	Alias string `json:"alias" binding:"required,min=1,max=50"` // 別名
+	Name  string `json:"name" binding:"required,min=1,max=50"`  // 置き換えるタグ名
+}
+
+// タグの別名の登録・変更（管理者のみ、既存のタグは書き換えない）
+func PutTagAlias(c *gin.Context, req requestPutTagAlias) (string, error) {
+	alias := &model.TagAlias{
+		AliasKey: model.TagKey(req.Alias),
+		Alias:    model.NormalizeTagName(req.Alias),
+		Name:     model.NormalizeTagName(req.Name),
+	}
+	if alias.AliasKey == "" || alias.Name == "" {
+		return "Invalid tag name", fmt.Errorf("empty tag alias: %q -> %q", req.Alias, req.Name)
+	}
+	if alias.AliasKey == model.TagKey(alias.Name) {
+		return "Alias must differ from tag name", fmt.Errorf("alias equals tag name: %s", alias.Name)
+	}
+
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.UpsertTagAlias(tx, alias); err != nil {
+			return "Failed to register tag alias", err
+		}
+		return "", nil
+	})
+}
+
+// ------------------------------------------------------------
+// タグの別名の削除（管理者のみ）
+func DeleteTagAlias(c *gin.Context) (string, error) {
+	key := model.TagKey(c.GetString("alias"))
+
+	return inTransaction(func(tx *db.Tx) (string, error) {
+		if err := dao.DeleteTagAlias(tx, key); err != nil {
+			return "Failed to delete tag alias", err
+		}
+		return "", nil
+	})
+}
@@ -0,0 +1,21 @@
+// service/api/transaction.go
+
+package api
+
+import (
+	"github.com/jcytp/kifup-api/common/db"
+)
+
+// fをトランザクション内で実行する（エラーの場合はロールバックしてメッセージを返す）
+func inTransaction(f func(tx *db.Tx) (string, error)) (string, error) {
+	var msg string
+	err := db.Transaction(func(tx *db.Tx) error {
+		var err error
+		msg, err = f(tx)
+		return err
+	})
+	if err != nil && msg == "" {
+		msg = "Failed to commit transaction"
+	}
+	return msg, err
+}
@@ -0,0 +1,72 @@
+// service/dao/account_player_names.go
+// アカウントが自分とみなす対局者名
+
+package dao
+
+import (
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropAccountPlayerNameTable() error {
+	query := `DROP TABLE IF EXISTS account_player_names`
+	_, err := db.Exec(query)
+	return err
+}
+
+func CreateAccountPlayerNameTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS account_player_names (
+			account_id TEXT NOT NULL,
+			name_key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			PRIMARY KEY (account_id, name_key),
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+			CHECK (LENGTH(name) >= 1 AND LENGTH(name) <= 100)
+		)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+func ListAccountPlayerNames(accountID string) ([]*model.AccountPlayerName, error) {
+	query := `
+		SELECT account_id, name_key, name FROM account_player_names
+		WHERE account_id = ?
+		ORDER BY name
+	`
+	rows, err := db.Query(query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []*model.AccountPlayerName{}
+	for rows.Next() {
+		name := &model.AccountPlayerName{}
+		if err := rows.Scan(&name.AccountID, &name.NameKey, &name.Name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// アカウントの対局者名を置き換える（キーが重複する名前は最初のものを使う）
+func ReplaceAccountPlayerNames(tx *db.Tx, accountID string, names []*model.AccountPlayerName) error {
+	query := `DELETE FROM account_player_names WHERE account_id = ?`
+	if _, err := tx.Exec(query, accountID); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT OR IGNORE INTO account_player_names (account_id, name_key, name)
+		VALUES (?, ?, ?)
+	`
+	for _, name := range names {
+		if _, err := tx.Exec(query, accountID, name.NameKey, name.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// service/dao/account_stats.go
+// アカウントの統計（自分の棋譜の集計、非公開の棋譜を含む）
+
+package dao
+
+import (
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 月ごとの棋譜数（対局日時、無い場合は作成日時の月、古い順）
+// 日時は「YYYY-MM-DD hh:mm:ss ...」の文字列で保存されるため、先頭7文字が年月になる
+func ListAccountKifuMonthCounts(accountID string) ([]*model.MonthCount, error) {
+	query := `
+		SELECT SUBSTR(COALESCE(k.started_at, k.created_at), 1, 7) AS month, COUNT(*) FROM kifus k
+		WHERE k.account_id = ?
+		GROUP BY month
+		ORDER BY month
+	`
+	rows, err := db.Query(query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*model.MonthCount{}
+	for rows.Next() {
+		count := &model.MonthCount{}
+		if err := rows.Scan(&count.Month, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// 自分の棋譜の先手・後手の名前と勝敗
+func ListAccountKifuPlayers(accountID string) ([]*model.KifuPlayer, error) {
+	query := `
+		SELECT p.kifu_id, p.side, p.name, p.name_key, p.player_id, p.result, p.candidate_ids, p.move_count
+		FROM kifu_players p
+		INNER JOIN kifus k ON k.id = p.kifu_id
+		WHERE k.account_id = ?
+		ORDER BY p.kifu_id, p.side
+	`
+	rows, err := db.Query(query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := []*model.KifuPlayer{}
+	for rows.Next() {
+		player, err := scanKifuPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, player)
+	}
+	return players, nil
+}
+
+// 自分の棋譜のタグの多い順
+func ListAccountKifuTagCounts(accountID string, limit int) ([]*model.TagCount, error) {
+	query := `
+		SELECT t.name, COUNT(*) AS count FROM kifu_tags t
+		INNER JOIN kifus k ON k.id = t.kifu_id
+		WHERE k.account_id = ?
+		GROUP BY t.name
+		ORDER BY count DESC, t.name
+		LIMIT ?
+	`
+	rows, err := db.Query(query, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.TagCount{}
+	for rows.Next() {
+		tag := &model.TagCount{}
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// 自分の棋譜のメインラインの、消費時間のある指し手
+func ListAccountMoveTimes(accountID string) ([]*model.MoveTime, error) {
+	query := `
+		SELECT k.id, k.initial_position, m.number, m.time_spent_ms FROM kifus k
+		INNER JOIN kifu_branches b ON b.kifu_id = k.id AND b.root_branch_id IS NULL
+		INNER JOIN kifu_moves m ON m.branch_id = b.id
+		WHERE k.account_id = ? AND m.time_spent_ms IS NOT NULL
+		ORDER BY k.id, m.number
+	`
+	rows, err := db.Query(query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []*model.MoveTime{}
+	for rows.Next() {
+		move := &model.MoveTime{}
+		if err := rows.Scan(&move.KifuID, &move.InitialPosition, &move.Number, &move.TimeSpentMs); err != nil {
+			return nil, err
+		}
+		moves = append(moves, move)
+	}
+	return moves, nil
+}
@@ -29,14 +29,22 @@ func CreateAccountTable() error {
 			introduction TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			last_login_at TIMESTAMP,
+			allow_fork BOOLEAN NOT NULL DEFAULT true,
 			CHECK (LENGTH(name) >= 2),
 			CHECK (LENGTH(email) <= 255),
 			CHECK (LENGTH(introduction) <= 1000)
 		);
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_email ON accounts(email)
 	`
-	_, err := db.Exec(query)
-	return err
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return migrateAccountTable()
+}
+
+// 既存のDBに追加されたカラムを反映する（CREATE TABLEの末尾と同じ順で追加する）
+func migrateAccountTable() error {
+	return db.AddColumnIfNotExists("accounts", "allow_fork", "BOOLEAN NOT NULL DEFAULT true")
 }
 
 func InsertAccount(account *model.Account) (string, error) {
@@ -44,19 +52,22 @@ func InsertAccount(account *model.Account) (string, error) {
 	now := time.Now()
 	account.CreatedAt = now
 	account.LastLoginAt = now
+	account.AllowFork = true
 
 	query := `
 		INSERT INTO accounts (
 			id, name, email, pass_hash,
 			icon_id, introduction,
-			created_at, last_login_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			created_at, last_login_at,
+			allow_fork
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := db.Exec(
 		query,
 		account.ID, account.Name, account.Email, account.PassHash,
 		account.IconID, account.Introduction,
 		account.CreatedAt, account.LastLoginAt,
+		account.AllowFork,
 	)
 	return account.ID, err
 }
@@ -66,7 +77,8 @@ func UpdateAccount(account *model.Account) error {
 		UPDATE accounts SET
 			name = ?, email = ?, pass_hash = ?,
 			icon_id = ?, introduction = ?,
-			last_login_at = ?
+			last_login_at = ?,
+			allow_fork = ?
 		WHERE id = ?
 	`
 	_, err := db.Exec(
@@ -74,6 +86,7 @@ func UpdateAccount(account *model.Account) error {
 		account.Name, account.Email, account.PassHash,
 		account.IconID, account.Introduction,
 		account.LastLoginAt,
+		account.AllowFork,
 		account.ID,
 	)
 	return err
@@ -88,13 +101,13 @@ func DeleteAccount(id string) error {
 	return db.CheckAffectedRows(res, 1)
 }
 
-func GetAccountByID(id string) (*model.Account, error) {
-	query := `SELECT * FROM accounts WHERE id = ?`
+func scanAccount(row rowScanner) (*model.Account, error) {
 	account := &model.Account{}
-	err := db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&account.ID, &account.Name, &account.Email,
 		&account.PassHash, &account.IconID, &account.Introduction,
 		&account.CreatedAt, &account.LastLoginAt,
+		&account.AllowFork,
 	)
 	if err != nil {
 		return nil, err
@@ -102,16 +115,35 @@ func GetAccountByID(id string) (*model.Account, error) {
 	return account, nil
 }
 
+func GetAccountByID(id string) (*model.Account, error) {
+	query := `SELECT * FROM accounts WHERE id = ?`
+	return scanAccount(db.QueryRow(query, id))
+}
+
 func GetAccountByEmail(email string) (*model.Account, error) {
 	query := `SELECT * FROM accounts WHERE email = ?`
-	account := &model.Account{}
-	err := db.QueryRow(query, email).Scan(
-		&account.ID, &account.Name, &account.Email,
-		&account.PassHash, &account.IconID, &account.Introduction,
-		&account.CreatedAt, &account.LastLoginAt,
-	)
+	return scanAccount(db.QueryRow(query, email))
+}
+
+// 複数のアカウントをまとめて取得する（アカウントID→アカウント）
+func ListAccountsByIDs(ids []string) (map[string]*model.Account, error) {
+	accounts := make(map[string]*model.Account, len(ids))
+	if len(ids) == 0 {
+		return accounts, nil
+	}
+	query := `SELECT * FROM accounts WHERE id IN (` + placeholders(len(ids)) + `)`
+	rows, err := db.Query(query, stringArgs(ids)...)
 	if err != nil {
 		return nil, err
 	}
-	return account, nil
+	defer rows.Close()
+
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts[account.ID] = account
+	}
+	return accounts, nil
 }
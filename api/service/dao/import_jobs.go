@@ -0,0 +1,163 @@
+// service/dao/import_jobs.go
+
+package dao
+
+import (
+	"time"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func CreateImportJobTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS import_jobs (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			total_count INTEGER NOT NULL DEFAULT 0,
+			processed_count INTEGER NOT NULL DEFAULT 0,
+			succeeded_count INTEGER NOT NULL DEFAULT 0,
+			failed_count INTEGER NOT NULL DEFAULT 0,
+			skipped_count INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			message TEXT,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_import_jobs_account_id ON import_jobs(account_id)
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	// 既存のDBに追加されたカラムを反映する（SELECT *のScan順と一致するよう末尾に追加する）
+	return db.AddColumnIfNotExists("import_jobs", "message", "TEXT")
+}
+
+func CreateImportJobEntryTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS import_job_entries (
+			job_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			kifu_id TEXT,
+			message TEXT,
+			duplicate_of TEXT,
+			PRIMARY KEY (job_id, seq),
+			FOREIGN KEY (job_id) REFERENCES import_jobs(id) ON DELETE CASCADE
+		)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+func InsertImportJob(job *model.ImportJob) (string, error) {
+	job.ID = auxi.NewULID()
+	now := time.Now()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	query := `
+		INSERT INTO import_jobs (
+			id, account_id, status, total_count,
+			processed_count, succeeded_count, failed_count,
+			skipped_count, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := db.Exec(
+		query,
+		job.ID, job.AccountID, job.Status, job.TotalCount,
+		job.ProcessedCount, job.SucceededCount, job.FailedCount,
+		job.SkippedCount, job.CreatedAt, job.UpdatedAt,
+	)
+	return job.ID, err
+}
+
+func UpdateImportJobProgress(job *model.ImportJob) error {
+	job.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE import_jobs SET
+			status = ?,
+			processed_count = ?, succeeded_count = ?, failed_count = ?,
+			skipped_count = ?, updated_at = ?, message = ?
+		WHERE id = ?
+	`
+	res, err := db.Exec(
+		query,
+		job.Status,
+		job.ProcessedCount, job.SucceededCount, job.FailedCount,
+		job.SkippedCount, job.UpdatedAt, job.Message,
+		job.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
+
+func GetImportJob(jobID string) (*model.ImportJob, error) {
+	query := `SELECT * FROM import_jobs WHERE id = ?`
+	job := &model.ImportJob{}
+	err := db.QueryRow(query, jobID).Scan(
+		&job.ID, &job.AccountID, &job.Status, &job.TotalCount,
+		&job.ProcessedCount, &job.SucceededCount, &job.FailedCount,
+		&job.SkippedCount, &job.CreatedAt, &job.UpdatedAt, &job.Message,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// 終了していない（受付済み・取り込み中の）ジョブを失敗にする
+func FailUnfinishedImportJobs(message string) (int64, error) {
+	query := `
+		UPDATE import_jobs SET status = ?, message = ?, updated_at = ?
+		WHERE status IN (?, ?)
+	`
+	res, err := db.Exec(
+		query,
+		model.IMPORT_JOB_FAILED, message, time.Now(),
+		model.IMPORT_JOB_PENDING, model.IMPORT_JOB_RUNNING,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func InsertImportJobEntry(entry *model.ImportJobEntry) error {
+	query := `
+		INSERT INTO import_job_entries (
+			job_id, seq, name, kifu_id, message, duplicate_of
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := db.Exec(query, entry.JobID, entry.Seq, entry.Name, entry.KifuID, entry.Message, entry.DuplicateOf)
+	return err
+}
+
+func ListImportJobEntries(jobID string) ([]*model.ImportJobEntry, error) {
+	query := `
+		SELECT * FROM import_job_entries
+		WHERE job_id = ?
+		ORDER BY seq
+	`
+	rows, err := db.Query(query, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*model.ImportJobEntry{}
+	for rows.Next() {
+		entry := &model.ImportJobEntry{}
+		err := rows.Scan(&entry.JobID, &entry.Seq, &entry.Name, &entry.KifuID, &entry.Message, &entry.DuplicateOf)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,100 @@
+// service/dao/kifu_annotations.go
+
+package dao
+
+import (
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropKifuAnnotationTable() error {
+	query := `DROP TABLE IF EXISTS kifu_annotations`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 指し手が削除されると注釈も削除される
+func CreateKifuAnnotationTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS kifu_annotations (
+			id TEXT PRIMARY KEY,
+			branch_id TEXT NOT NULL,
+			number INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			from_place INTEGER,
+			to_place INTEGER,
+			mark TEXT,
+			color TEXT,
+			FOREIGN KEY (branch_id, number) REFERENCES kifu_moves(branch_id, number) ON DELETE CASCADE,
+			CHECK (type IN ('mark', 'arrow', 'highlight')),
+			CHECK (from_place IS NULL OR (from_place >= 0 AND from_place <= 255)),
+			CHECK (to_place IS NULL OR (to_place >= 0 AND to_place <= 255)),
+			CHECK (LENGTH(mark) <= 2),
+			CHECK (LENGTH(color) <= 20)
+		);
+		CREATE INDEX IF NOT EXISTS idx_kifu_annotations_branch_number ON kifu_annotations(branch_id, number)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+func InsertKifuAnnotations(tx *db.Tx, annotations []*model.KifuAnnotation) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO kifu_annotations (
+			id, branch_id, number, type,
+			from_place, to_place, mark, color
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	for _, annotation := range annotations {
+		annotation.ID = auxi.NewULID()
+		_, err := tx.Exec(
+			query,
+			annotation.ID, annotation.BranchID, annotation.Number, annotation.Type,
+			annotation.FromPlace, annotation.ToPlace, annotation.Mark, annotation.Color,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ClearKifuAnnotationsByMove(tx *db.Tx, branchID string, number int64) error {
+	query := `DELETE FROM kifu_annotations WHERE branch_id = ? AND number = ?`
+	_, err := tx.Exec(query, branchID, number)
+	return err
+}
+
+// 棋譜の全ブランチの注釈（登録順）
+func ListKifuAnnotationsByKifuID(kifuID string) ([]*model.KifuAnnotation, error) {
+	query := `
+		SELECT a.* FROM kifu_annotations a
+		JOIN kifu_branches b ON a.branch_id = b.id
+		WHERE b.kifu_id = ?
+		ORDER BY a.branch_id, a.number, a.rowid
+	`
+	rows, err := db.Query(query, kifuID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	annotations := []*model.KifuAnnotation{}
+	for rows.Next() {
+		annotation := &model.KifuAnnotation{}
+		err := rows.Scan(
+			&annotation.ID, &annotation.BranchID, &annotation.Number, &annotation.Type,
+			&annotation.FromPlace, &annotation.ToPlace, &annotation.Mark, &annotation.Color,
+		)
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, annotation)
+	}
+	return annotations, nil
+}
@@ -24,6 +24,7 @@ func CreateKifuBranchTable() error {
 			ending_number INTEGER,
 			ending_type INTEGER,
 			ending_comment TEXT,
+			sort_order INTEGER NOT NULL DEFAULT 0,
 			FOREIGN KEY (kifu_id) REFERENCES kifus(id) ON DELETE CASCADE,
 			FOREIGN KEY (root_branch_id) REFERENCES kifu_branches(id) ON DELETE CASCADE,
 			CHECK (root_number IS NULL OR root_number >= 0),
@@ -38,41 +39,92 @@ func CreateKifuBranchTable() error {
 		CREATE INDEX IF NOT EXISTS idx_kifu_branches_kifu_id ON kifu_branches(kifu_id);
 		CREATE INDEX IF NOT EXISTS idx_kifu_branches_root ON kifu_branches(root_branch_id, root_number)
 	`
-	_, err := db.Exec(query)
-	return err
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return migrateKifuBranchTable()
 }
 
-func InsertKifuBranch(branch *model.KifuBranch) (string, error) {
+// 既存のDBに追加されたカラムを反映する
+// カラムの順序がSELECT *のScan順と一致するよう、CREATE TABLEの末尾と同じ順で追加する
+func migrateKifuBranchTable() error {
+	return db.AddColumnIfNotExists("kifu_branches", "sort_order", "INTEGER NOT NULL DEFAULT 0")
+}
+
+func InsertKifuBranch(tx *db.Tx, branch *model.KifuBranch) (string, error) {
 	branch.ID = auxi.NewULID()
 
 	query := `
 		INSERT INTO kifu_branches (
 			id, kifu_id, root_branch_id, root_number,
-			ending_number, ending_type, ending_comment
-		) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ending_number, ending_type, ending_comment,
+			sort_order
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := db.Exec(
+	_, err := tx.Exec(
 		query,
 		branch.ID, branch.KifuID, branch.RootBranchID, branch.RootNumber,
 		branch.EndingNumber, branch.EndingType, branch.EndingComment,
+		branch.SortOrder,
 	)
 	return branch.ID, err
 }
 
-func ClearKifuBranchesByKifuID(kifuID string) error {
+func ClearKifuBranchesByKifuID(tx *db.Tx, kifuID string) error {
 	query := `DELETE FROM kifu_branches WHERE kifu_id = ?`
-	_, err := db.Exec(query, kifuID)
+	_, err := tx.Exec(query, kifuID)
 	return err
 }
 
-// func DeleteKifuBranch(branchID string, kifuID string) error {
-// 	query := `DELETE FROM kifu_branches WHERE id = ? AND kifu_id = ?`
-// 	res, err := db.Exec(query, branchID, kifuID)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	return db.CheckAffectedRows(res, 1)
-// }
+// 指し手も含めて削除する（子ブランチはbranchIDsに含めること）
+// branchIDsは親が子より前に並ぶため、子から順に削除する（親の削除で子はCASCADEで削除済みになりうる）
+func DeleteKifuBranches(tx *db.Tx, kifuID string, branchIDs []string) error {
+	for i := len(branchIDs) - 1; i >= 0; i-- {
+		if err := ClearKifuMovesByBranchID(tx, branchIDs[i]); err != nil {
+			return err
+		}
+		query := `DELETE FROM kifu_branches WHERE id = ? AND kifu_id = ?`
+		if _, err := tx.Exec(query, branchIDs[i], kifuID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 分岐元と兄弟間の順序を更新する
+func UpdateKifuBranchRoot(tx *db.Tx, branch *model.KifuBranch) error {
+	query := `
+		UPDATE kifu_branches SET
+			root_branch_id = ?, root_number = ?, sort_order = ?
+		WHERE id = ? AND kifu_id = ?
+	`
+	res, err := tx.Exec(
+		query,
+		branch.RootBranchID, branch.RootNumber, branch.SortOrder,
+		branch.ID, branch.KifuID,
+	)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
+
+func UpdateKifuBranchEnding(tx *db.Tx, branch *model.KifuBranch) error {
+	query := `
+		UPDATE kifu_branches SET
+			ending_number = ?, ending_type = ?, ending_comment = ?
+		WHERE id = ? AND kifu_id = ?
+	`
+	res, err := tx.Exec(
+		query,
+		branch.EndingNumber, branch.EndingType, branch.EndingComment,
+		branch.ID, branch.KifuID,
+	)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
 
 func ListKifuBranchesByKifuID(kifuID string) ([]*model.KifuBranch, error) {
 	query := `
@@ -81,7 +133,8 @@ func ListKifuBranchesByKifuID(kifuID string) ([]*model.KifuBranch, error) {
 		ORDER BY 
 			CASE WHEN root_branch_id IS NULL THEN 0 ELSE 1 END, -- メインラインを最初に
 			COALESCE(root_number, 0),                           -- 分岐発生手数で並べる
-			id                                                  -- 同一手数からの分岐は作成順
+			sort_order,                                         -- 同一手数からの分岐は指定順
+			id                                                  -- 同じ順序の場合は作成順
 	`
 	rows, err := db.Query(query, kifuID)
 	if err != nil {
@@ -96,7 +149,7 @@ func ListKifuBranchesByKifuID(kifuID string) ([]*model.KifuBranch, error) {
 			&branch.ID, &branch.KifuID,
 			&branch.RootBranchID, &branch.RootNumber,
 			&branch.EndingNumber, &branch.EndingType,
-			&branch.EndingComment,
+			&branch.EndingComment, &branch.SortOrder,
 		)
 		if err != nil {
 			return nil, err
@@ -29,7 +29,7 @@ func CreateKifuCommentTable() error {
 	return err
 }
 
-func InsertKifuComment(comment *model.KifuComment) (string, error) {
+func InsertKifuComment(tx *db.Tx, comment *model.KifuComment) (string, error) {
 	comment.ID = auxi.NewULID()
 	comment.CreatedAt = time.Now()
 
@@ -39,7 +39,7 @@ func InsertKifuComment(comment *model.KifuComment) (string, error) {
 			content, created_at
 		) VALUES (?, ?, ?, ?, ?)
     `
-	_, err := db.Exec(
+	_, err := tx.Exec(
 		query,
 		comment.ID, comment.KifuID, comment.AccountID,
 		comment.Content, comment.CreatedAt,
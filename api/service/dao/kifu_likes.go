@@ -23,21 +23,21 @@ func CreateKifuLikeTable() error {
 	return err
 }
 
-func InsertKifuLike(kifuID string, accountID string) error {
+func InsertKifuLike(tx *db.Tx, kifuID string, accountID string) error {
 	query := `
 		INSERT INTO kifu_likes (kifu_id, account_id)
 		VALUES (?, ?)
 	`
-	_, err := db.Exec(query, kifuID, accountID)
+	_, err := tx.Exec(query, kifuID, accountID)
 	return err
 }
 
-func DeleteKifuLike(kifuID string, accountID string) error {
+func DeleteKifuLike(tx *db.Tx, kifuID string, accountID string) error {
 	query := `
 		DELETE FROM kifu_likes
 		WHERE kifu_id = ? AND account_id = ?
 	`
-	res, err := db.Exec(query, kifuID, accountID)
+	res, err := tx.Exec(query, kifuID, accountID)
 	if err != nil {
 		return err
 	}
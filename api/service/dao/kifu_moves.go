@@ -38,7 +38,7 @@ func CreateKifuMoveTable() error {
 	return err
 }
 
-func InsertKifuMoves(moves []*model.KifuMove) error {
+func InsertKifuMoves(tx *db.Tx, moves []*model.KifuMove) error {
 	if len(moves) == 0 {
 		return nil
 	}
@@ -51,7 +51,7 @@ func InsertKifuMoves(moves []*model.KifuMove) error {
 		) VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 	for _, move := range moves {
-		_, err := db.Exec(
+		_, err := tx.Exec(
 			query,
 			move.BranchID, move.Number, move.Piece,
 			move.FromPlace, move.ToPlace,
@@ -64,13 +64,41 @@ func InsertKifuMoves(moves []*model.KifuMove) error {
 	return nil
 }
 
-func ListKifuMovesByBranchID(branchID string) ([]*model.KifuMove, error) {
+func UpdateKifuMove(tx *db.Tx, move *model.KifuMove) error {
 	query := `
-		SELECT * FROM kifu_moves 
-		WHERE branch_id = ? 
-		ORDER BY number
+		UPDATE kifu_moves SET
+			comment = ?, time_spent_ms = ?
+		WHERE branch_id = ? AND number = ?
 	`
-	rows, err := db.Query(query, branchID)
+	res, err := tx.Exec(query, move.Comment, move.TimeSpentMs, move.BranchID, move.Number)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
+
+// number手目より後の指し手を削除する
+func DeleteKifuMovesAfter(tx *db.Tx, branchID string, number int64) error {
+	query := `DELETE FROM kifu_moves WHERE branch_id = ? AND number > ?`
+	_, err := tx.Exec(query, branchID, number)
+	return err
+}
+
+func ClearKifuMovesByBranchID(tx *db.Tx, branchID string) error {
+	query := `DELETE FROM kifu_moves WHERE branch_id = ?`
+	_, err := tx.Exec(query, branchID)
+	return err
+}
+
+// 棋譜の全ブランチの指し手をまとめて取得する（ブランチごとに手数の順）
+func ListKifuMovesByKifuID(kifuID string) ([]*model.KifuMove, error) {
+	query := `
+		SELECT m.* FROM kifu_moves m
+		INNER JOIN kifu_branches b ON m.branch_id = b.id
+		WHERE b.kifu_id = ?
+		ORDER BY m.branch_id, m.number
+	`
+	rows, err := db.Query(query, kifuID)
 	if err != nil {
 		return nil, err
 	}
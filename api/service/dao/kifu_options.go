@@ -30,7 +30,7 @@ func CreateKifuOptionTable() error {
 	return err
 }
 
-func InsertKifuOptions(options []*model.KifuOption) error {
+func InsertKifuOptions(tx *db.Tx, options []*model.KifuOption) error {
 	if len(options) == 0 {
 		return nil
 	}
@@ -40,7 +40,7 @@ func InsertKifuOptions(options []*model.KifuOption) error {
 		VALUES (?, ?, ?)
 	`
 	for _, opt := range options {
-		_, err := db.Exec(query, opt.KifuID, opt.Name, opt.Value)
+		_, err := tx.Exec(query, opt.KifuID, opt.Name, opt.Value)
 		if err != nil {
 			return err
 		}
@@ -48,9 +48,9 @@ func InsertKifuOptions(options []*model.KifuOption) error {
 	return nil
 }
 
-func ClearKifuOptionsByKifuID(kifuID string) error {
+func ClearKifuOptionsByKifuID(tx *db.Tx, kifuID string) error {
 	query := `DELETE FROM kifu_options WHERE kifu_id = ?`
-	_, err := db.Exec(query, kifuID)
+	_, err := tx.Exec(query, kifuID)
 	return err
 }
 
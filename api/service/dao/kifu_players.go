@@ -0,0 +1,318 @@
+// service/dao/kifu_players.go
+// 棋譜の先手・後手と対局者の対応（棋譜の作成・更新時に棋譜上の名前から照合する）
+
+package dao
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropKifuPlayerTable() error {
+	query := `DROP TABLE IF EXISTS kifu_players`
+	_, err := db.Exec(query)
+	return err
+}
+
+func CreateKifuPlayerTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS kifu_players (
+			kifu_id TEXT NOT NULL,
+			side INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			name_key TEXT NOT NULL,
+			player_id TEXT,
+			result INTEGER,
+			candidate_ids TEXT,
+			PRIMARY KEY (kifu_id, side),
+			FOREIGN KEY (kifu_id) REFERENCES kifus(id) ON DELETE CASCADE,
+			FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE SET NULL,
+			CHECK (side IN (0, 1)),
+			CHECK (result IS NULL OR result IN (-1, 0, 1))
+		);
+		CREATE INDEX IF NOT EXISTS idx_kifu_players_player_id ON kifu_players(player_id);
+		CREATE INDEX IF NOT EXISTS idx_kifu_players_name_key ON kifu_players(name_key)
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return migrateKifuPlayerTable()
+}
+
+func migrateKifuPlayerTable() error {
+	if err := db.AddColumnIfNotExists("kifu_players", "move_count", "INTEGER"); err != nil {
+		return err
+	}
+	return fillKifuPlayerMoveCounts()
+}
+
+// move_count追加前の対応にメインラインの手数を設定する
+func fillKifuPlayerMoveCounts() error {
+	query := `
+		UPDATE kifu_players SET move_count = COALESCE((
+			SELECT COALESCE(b.ending_number - 1, (SELECT MAX(m.number) FROM kifu_moves m WHERE m.branch_id = b.id))
+			FROM kifu_branches b
+			WHERE b.kifu_id = kifu_players.kifu_id AND b.root_branch_id IS NULL
+		), 0)
+		WHERE move_count IS NULL
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 棋譜の対局者の名前と、メインラインの終局の情報を取得する
+func GetKifuGameRecord(tx *db.Tx, kifuID string) (*model.KifuGameRecord, error) {
+	query := `
+		SELECT
+			k.id, k.is_public, k.black_player, k.white_player, k.initial_position,
+			b.ending_type,
+			COALESCE(b.ending_number, (SELECT MAX(m.number) + 1 FROM kifu_moves m WHERE m.branch_id = b.id))
+		FROM kifus k
+		LEFT JOIN kifu_branches b ON b.kifu_id = k.id AND b.root_branch_id IS NULL
+		WHERE k.id = ?
+	`
+	record := &model.KifuGameRecord{}
+	err := tx.QueryRow(query, kifuID).Scan(
+		&record.KifuID,
+		&record.IsPublic,
+		&record.BlackPlayer,
+		&record.WhitePlayer,
+		&record.InitialPosition,
+		&record.EndingType,
+		&record.EndingNumber,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// 対局者の名前があるのに対応が無い棋譜のID（対局者のテーブル追加前の棋譜の移行）
+func ListKifuIDsWithoutPlayers() ([]string, error) {
+	query := `
+		SELECT k.id FROM kifus k
+		WHERE (COALESCE(k.black_player, '') != '' OR COALESCE(k.white_player, '') != '')
+			AND NOT EXISTS (SELECT 1 FROM kifu_players p WHERE p.kifu_id = k.id)
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kifuIDs := []string{}
+	for rows.Next() {
+		var kifuID string
+		if err := rows.Scan(&kifuID); err != nil {
+			return nil, err
+		}
+		kifuIDs = append(kifuIDs, kifuID)
+	}
+	return kifuIDs, nil
+}
+
+// 棋譜の対局者の対応を置き換える
+func ReplaceKifuPlayers(tx *db.Tx, kifuID string, players []*model.KifuPlayer) error {
+	query := `DELETE FROM kifu_players WHERE kifu_id = ?`
+	if _, err := tx.Exec(query, kifuID); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO kifu_players (kifu_id, side, name, name_key, player_id, result, candidate_ids, move_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	for _, player := range players {
+		var candidateIDs *string
+		if len(player.CandidateIDs) > 0 {
+			joined := strings.Join(player.CandidateIDs, ",")
+			candidateIDs = &joined
+		}
+		_, err := tx.Exec(
+			query,
+			kifuID, player.Side, player.Name, player.NameKey,
+			player.PlayerID, player.Result, candidateIDs, player.MoveCount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanKifuPlayer(row rowScanner) (*model.KifuPlayer, error) {
+	player := &model.KifuPlayer{}
+	var candidateIDs sql.NullString
+	err := row.Scan(
+		&player.KifuID,
+		&player.Side,
+		&player.Name,
+		&player.NameKey,
+		&player.PlayerID,
+		&player.Result,
+		&candidateIDs,
+		&player.MoveCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if candidateIDs.Valid && candidateIDs.String != "" {
+		player.CandidateIDs = strings.Split(candidateIDs.String, ",")
+	}
+	return player, nil
+}
+
+func ListKifuPlayersByKifuIDs(kifuIDs []string) (map[string][]*model.KifuPlayer, error) {
+	players := make(map[string][]*model.KifuPlayer, len(kifuIDs))
+	if len(kifuIDs) == 0 {
+		return players, nil
+	}
+	query := `
+		SELECT kifu_id, side, name, name_key, player_id, result, candidate_ids, move_count FROM kifu_players
+		WHERE kifu_id IN (` + placeholders(len(kifuIDs)) + `)
+		ORDER BY kifu_id, side
+	`
+	rows, err := db.Query(query, stringArgs(kifuIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		player, err := scanKifuPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		players[player.KifuID] = append(players[player.KifuID], player)
+	}
+	return players, nil
+}
+
+// 名前のキーが一致する未確定の対応を、対局者に確定する
+func LinkKifuPlayersByNameKey(tx *db.Tx, nameKey string, playerID string) (int64, error) {
+	query := `
+		UPDATE kifu_players SET player_id = ?, candidate_ids = NULL
+		WHERE name_key = ? AND player_id IS NULL
+	`
+	res, err := tx.Exec(query, playerID, nameKey)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ------------------------------------------------------------
+// 確認待ち
+
+// 確認待ちの対応（名前のキーごとに、棋譜の多い順）
+func ListPlayerReviews(limit int) ([]*model.PlayerReview, error) {
+	query := `
+		SELECT name_key, MIN(name), COUNT(*), MAX(candidate_ids) FROM kifu_players
+		WHERE player_id IS NULL AND candidate_ids IS NOT NULL
+		GROUP BY name_key
+		ORDER BY COUNT(*) DESC, name_key
+		LIMIT ?
+	`
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*model.PlayerReview{}
+	for rows.Next() {
+		review := &model.PlayerReview{}
+		var candidateIDs string
+		if err := rows.Scan(&review.NameKey, &review.Name, &review.KifuCount, &candidateIDs); err != nil {
+			return nil, err
+		}
+		review.CandidateIDs = strings.Split(candidateIDs, ",")
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// 名前のキーが一致する確認待ちの対応の数
+func CountPlayerReviewsByNameKey(tx *db.Tx, nameKey string) (int64, error) {
+	query := `
+		SELECT COUNT(*) FROM kifu_players
+		WHERE name_key = ? AND player_id IS NULL AND candidate_ids IS NOT NULL
+	`
+	var count int64
+	if err := tx.QueryRow(query, nameKey).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ------------------------------------------------------------
+// 対局者ごとの集計（公開棋譜のみ）
+
+// 最も古い・新しい対局日時（対局日時のある棋譜が無ければnil）
+func GetPlayerStartedRange(playerID string) (*time.Time, *time.Time, error) {
+	first, err := getPlayerStartedAt(playerID, "ASC")
+	if err != nil {
+		return nil, nil, err
+	}
+	last, err := getPlayerStartedAt(playerID, "DESC")
+	if err != nil {
+		return nil, nil, err
+	}
+	return first, last, nil
+}
+
+func getPlayerStartedAt(playerID string, order string) (*time.Time, error) {
+	query := `
+		SELECT k.started_at FROM kifu_players p
+		INNER JOIN kifus k ON k.id = p.kifu_id
+		WHERE p.player_id = ? AND k.is_public = true AND k.started_at IS NOT NULL
+		ORDER BY k.started_at ` + order + `
+		LIMIT 1
+	`
+	rows, err := db.Query(query, playerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var startedAt time.Time
+	if err := rows.Scan(&startedAt); err != nil {
+		return nil, err
+	}
+	return &startedAt, nil
+}
+
+// 対局した棋譜のタグの多い順
+func ListPlayerTagCounts(playerID string, limit int) ([]*model.TagCount, error) {
+	query := `
+		SELECT t.name, COUNT(*) AS count FROM kifu_players p
+		INNER JOIN kifus k ON k.id = p.kifu_id
+		INNER JOIN kifu_tags t ON t.kifu_id = p.kifu_id
+		WHERE p.player_id = ? AND k.is_public = true
+		GROUP BY t.name
+		ORDER BY count DESC, t.name
+		LIMIT ?
+	`
+	rows, err := db.Query(query, playerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.TagCount{}
+	for rows.Next() {
+		tag := &model.TagCount{}
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
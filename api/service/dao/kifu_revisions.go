@@ -0,0 +1,102 @@
+// service/dao/kifu_revisions.go
+
+package dao
+
+import (
+	"time"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func CreateKifuRevisionTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS kifu_revisions (
+			id TEXT PRIMARY KEY,
+			kifu_id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			account_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			snapshot TEXT NOT NULL,
+			restored_of TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (kifu_id) REFERENCES kifus(id) ON DELETE CASCADE,
+			CHECK (version >= 1)
+		);
+		CREATE INDEX IF NOT EXISTS idx_kifu_revisions_kifu_id ON kifu_revisions(kifu_id, version)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 作成日時が未設定の場合は現在時刻を使う
+func InsertKifuRevision(tx *db.Tx, revision *model.KifuRevision) (string, error) {
+	revision.ID = auxi.NewULID()
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO kifu_revisions (
+			id, kifu_id, version, account_id,
+			action, snapshot, restored_of, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := tx.Exec(
+		query,
+		revision.ID, revision.KifuID, revision.Version, revision.AccountID,
+		revision.Action, revision.Snapshot, revision.RestoredOf, revision.CreatedAt,
+	)
+	return revision.ID, err
+}
+
+func CountKifuRevisionsByKifuID(kifuID string) (int, error) {
+	query := `SELECT COUNT(*) FROM kifu_revisions WHERE kifu_id = ?`
+	var count int
+	err := db.QueryRow(query, kifuID).Scan(&count)
+	return count, err
+}
+
+func GetKifuRevision(kifuID string, revisionID string) (*model.KifuRevision, error) {
+	query := `SELECT * FROM kifu_revisions WHERE kifu_id = ? AND id = ?`
+	revision := &model.KifuRevision{}
+	err := db.QueryRow(query, kifuID, revisionID).Scan(
+		&revision.ID, &revision.KifuID, &revision.Version, &revision.AccountID,
+		&revision.Action, &revision.Snapshot, &revision.RestoredOf, &revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// 新しい順（スナップショットは含まない）
+func ListKifuRevisionsByKifuID(kifuID string, limit int, offset int) ([]*model.KifuRevision, error) {
+	query := `
+		SELECT id, kifu_id, version, account_id, action, restored_of, created_at
+		FROM kifu_revisions
+		WHERE kifu_id = ?
+		ORDER BY version DESC, created_at DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := db.Query(query, kifuID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*model.KifuRevision{}
+	for rows.Next() {
+		revision := &model.KifuRevision{}
+		err := rows.Scan(
+			&revision.ID, &revision.KifuID, &revision.Version, &revision.AccountID,
+			&revision.Action, &revision.RestoredOf, &revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
@@ -0,0 +1,331 @@
+// service/dao/kifu_search.go
+
+package dao
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// FROM句以降の結合と条件を組み立てる
+type queryBuilder struct {
+	joins      []string
+	joinArgs   []any
+	conditions []string
+	args       []any
+}
+
+func (b *queryBuilder) join(clause string, args ...any) {
+	b.joins = append(b.joins, clause)
+	b.joinArgs = append(b.joinArgs, args...)
+}
+
+func (b *queryBuilder) where(condition string, args ...any) {
+	b.conditions = append(b.conditions, condition)
+	b.args = append(b.args, args...)
+}
+
+// 結合とWHERE句
+func (b *queryBuilder) clauses() string {
+	clause := strings.Join(b.joins, "\n")
+	if len(b.conditions) > 0 {
+		clause += "\nWHERE " + strings.Join(b.conditions, " AND ")
+	}
+	return clause
+}
+
+// 結合とWHERE句のパラメーター
+func (b *queryBuilder) clauseArgs() []any {
+	return append(append([]any{}, b.joinArgs...), b.args...)
+}
+
+// 複数の値のIN句用のプレースホルダー（?, ?, ...）
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// IN句のパラメーター
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+// LIKE検索用に%と_をエスケープする（ESCAPE '\'と併用）
+func likePattern(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `%`, `\%`)
+	s = strings.ReplaceAll(s, `_`, `\_`)
+	return "%" + s + "%"
+}
+
+// 棋譜検索の条件（nil・空の条件では絞り込まない）
+type KifuSearchCondition struct {
+	KifuIDs         []string          // 対象の棋譜
+	AccountID       *string           // 所有者
+	PublicOnly      bool              // 公開棋譜のみ
+	Keywords        []string          // タイトル・対局者・棋譜情報・タグ・コメントの部分一致（全てを含む）
+	Tags            []string          // タグ（表記の揺れを除いたキーで比較する）
+	TagMatchAll     bool              // trueは全てのタグを持つ（AND）、falseはいずれかのタグを持つ（OR）
+	Player          *string           // 先手・後手のいずれかの部分一致
+	BlackPlayer     *string           // 先手の部分一致
+	WhitePlayer     *string           // 後手の部分一致
+	PlayerID        *string           // 先手・後手のいずれかの対局者（players）
+	StartedFrom     *string           // 対局日の範囲の開始（YYYY-MM-DD）
+	StartedBefore   *string           // 対局日の範囲の終了（YYYY-MM-DD、この日を含まない）
+	EndingType      *model.EndingType // メインラインの終局の種類
+	InitialPosition *model.SFEN       // 開始局面（手合割）
+	Sort            model.KifuSort    // 並び順（省略時はキーワード検索では一致の度合いの順、それ以外は更新日時の新しい順）
+	After           []any             // カーソル（ListKifusByConditionが返した並びのキーより後の棋譜）
+}
+
+func (cond *KifuSearchCondition) build() *queryBuilder {
+	b := &queryBuilder{}
+	if cond.KifuIDs != nil {
+		b.where("k.id IN ("+placeholders(len(cond.KifuIDs))+")", stringArgs(cond.KifuIDs)...)
+	}
+	if cond.AccountID != nil {
+		b.where("k.account_id = ?", *cond.AccountID)
+	}
+	if cond.PublicOnly {
+		b.where("k.is_public = true")
+	}
+	if len(cond.Keywords) > 0 {
+		cond.buildKeywords(b)
+	}
+	if len(cond.Tags) > 0 {
+		args := make([]any, 0, len(cond.Tags)+1)
+		for _, tag := range cond.Tags {
+			args = append(args, model.TagKey(tag))
+		}
+		if cond.TagMatchAll {
+			args = append(args, len(cond.Tags))
+			b.where(`(
+				SELECT COUNT(DISTINCT t.name_key) FROM kifu_tags t
+				WHERE t.kifu_id = k.id AND t.name_key IN (`+placeholders(len(cond.Tags))+`)
+			) = ?`, args...)
+		} else {
+			b.where(`EXISTS (
+				SELECT 1 FROM kifu_tags t
+				WHERE t.kifu_id = k.id AND t.name_key IN (`+placeholders(len(cond.Tags))+`)
+			)`, args...)
+		}
+	}
+	if cond.Player != nil {
+		pattern := likePattern(*cond.Player)
+		b.where(`(k.black_player LIKE ? ESCAPE '\' OR k.white_player LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	if cond.BlackPlayer != nil {
+		b.where(`k.black_player LIKE ? ESCAPE '\'`, likePattern(*cond.BlackPlayer))
+	}
+	if cond.WhitePlayer != nil {
+		b.where(`k.white_player LIKE ? ESCAPE '\'`, likePattern(*cond.WhitePlayer))
+	}
+	if cond.PlayerID != nil {
+		b.where(`EXISTS (
+			SELECT 1 FROM kifu_players kp
+			WHERE kp.kifu_id = k.id AND kp.player_id = ?
+		)`, *cond.PlayerID)
+	}
+	// started_atは「YYYY-MM-DD hh:mm:ss ...」の文字列で保存されるため、日付の文字列と比較できる
+	if cond.StartedFrom != nil {
+		b.where("k.started_at >= ?", *cond.StartedFrom)
+	}
+	if cond.StartedBefore != nil {
+		b.where("k.started_at < ?", *cond.StartedBefore)
+	}
+	if cond.EndingType != nil {
+		b.where(`EXISTS (
+			SELECT 1 FROM kifu_branches eb
+			WHERE eb.kifu_id = k.id AND eb.root_branch_id IS NULL AND eb.ending_type = ?
+		)`, *cond.EndingType)
+	}
+	if cond.InitialPosition != nil {
+		// 手数の表記の違いは無視する（平手はNULLも含む）
+		board := strings.TrimSuffix(string(*cond.InitialPosition), " 1")
+		if *cond.InitialPosition == model.SfenHirate {
+			b.where("(k.initial_position IS NULL OR k.initial_position LIKE ?)", board+" %")
+		} else {
+			b.where("k.initial_position LIKE ?", board+" %")
+		}
+	}
+	return b
+}
+
+// trigramで索引できない3文字未満のキーワード
+func isShortKeyword(keyword string) bool {
+	return utf8.RuneCountInString(keyword) < 3
+}
+
+// FTS5の検索式の語句（"で囲み、"は重ねてエスケープする）
+func ftsPhrase(keyword string) string {
+	return `"` + strings.ReplaceAll(keyword, `"`, `""`) + `"`
+}
+
+// キーワードは全文検索インデックスで絞り込み、一致の度合いの順にする
+// 3文字未満のキーワードは索引できないため、検索対象の文字列の部分一致で絞り込む
+func (cond *KifuSearchCondition) buildKeywords(b *queryBuilder) {
+	b.join("INNER JOIN kifu_search_documents d ON d.kifu_id = k.id")
+	phrases := []string{}
+	for _, keyword := range cond.Keywords {
+		if !isShortKeyword(keyword) {
+			phrases = append(phrases, ftsPhrase(keyword))
+			continue
+		}
+		pattern := likePattern(keyword)
+		b.where(`(
+			d.title LIKE ? ESCAPE '\' OR d.players LIKE ? ESCAPE '\' OR d.options LIKE ? ESCAPE '\'
+			OR d.tags LIKE ? ESCAPE '\' OR d.comments LIKE ? ESCAPE '\'
+		)`, pattern, pattern, pattern, pattern, pattern)
+	}
+	if len(phrases) > 0 {
+		b.join("INNER JOIN kifu_search ON kifu_search.rowid = d.id")
+		b.where("kifu_search MATCH ?", strings.Join(phrases, " "))
+	}
+}
+
+// 全文検索インデックスで絞り込むキーワードがあるか（一致の度合いで並べられるか）
+func (cond *KifuSearchCondition) usesFullText() bool {
+	for _, keyword := range cond.Keywords {
+		if !isShortKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// 並び順のキー（最後のキーはIDで、同順位の棋譜の順序を一意にする）
+type kifuSortKeys struct {
+	exprs   []string
+	columns []string // カーソル用に読み込むキーの値（日時は保存された文字列のまま読み込む）
+	desc    bool
+}
+
+var kifuSortKeysMap = map[model.KifuSort]kifuSortKeys{
+	model.KIFU_SORT_UPDATED: {
+		[]string{"k.updated_at", "k.id"},
+		[]string{"CAST(k.updated_at AS TEXT)", "k.id"},
+		true,
+	},
+	model.KIFU_SORT_LIKED: {
+		[]string{"k.like_count", "k.id"},
+		[]string{"k.like_count", "k.id"},
+		true,
+	},
+	model.KIFU_SORT_COMMENTED: {
+		[]string{"k.comment_count", "k.id"},
+		[]string{"k.comment_count", "k.id"},
+		true,
+	},
+	model.KIFU_SORT_STARTED: {
+		[]string{"COALESCE(k.started_at, '')", "k.id"},
+		[]string{"COALESCE(k.started_at, '')", "k.id"},
+		true,
+	},
+	model.KIFU_SORT_TITLE: {
+		[]string{"k.title", "k.id"},
+		[]string{"k.title", "k.id"},
+		false,
+	},
+	model.KIFU_SORT_RELEVANCE: { // rankは一致しているほど小さい
+		[]string{"kifu_search.rank", "k.id"},
+		[]string{"kifu_search.rank", "k.id"},
+		false,
+	},
+}
+
+func (cond *KifuSearchCondition) sortKeys() kifuSortKeys {
+	sort := cond.Sort
+	if sort == "" && cond.usesFullText() {
+		sort = model.KIFU_SORT_RELEVANCE
+	}
+	if sort == model.KIFU_SORT_RELEVANCE && !cond.usesFullText() {
+		sort = model.KIFU_SORT_UPDATED
+	}
+	if keys, ok := kifuSortKeysMap[sort]; ok {
+		return keys
+	}
+	return kifuSortKeysMap[model.KIFU_SORT_UPDATED]
+}
+
+// 並びのキーの値を追加で読み込む
+type sortKeyScanner struct {
+	row  rowScanner
+	keys []any
+}
+
+func (s *sortKeyScanner) Scan(dest ...any) error {
+	for i := range s.keys {
+		dest = append(dest, &s.keys[i])
+	}
+	return s.row.Scan(dest...)
+}
+
+func CountKifusByCondition(cond *KifuSearchCondition) (int, error) {
+	b := cond.build()
+	query := `SELECT COUNT(*) FROM kifus k ` + b.clauses()
+	var count int
+	if err := db.QueryRow(query, b.clauseArgs()...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// 条件に一致する棋譜を並び順で取得する
+// 続きがある場合は、最後の棋譜の並びのキー（次のページのAfterに指定する）も返す
+func ListKifusByCondition(cond *KifuSearchCondition, limit int, offset int) ([]*model.Kifu, []any, error) {
+	b := cond.build()
+	keys := cond.sortKeys()
+	if cond.After != nil {
+		if len(cond.After) != len(keys.exprs) {
+			return nil, nil, fmt.Errorf("invalid cursor: %d keys", len(cond.After))
+		}
+		operator := ">"
+		if keys.desc {
+			operator = "<"
+		}
+		b.where("("+strings.Join(keys.exprs, ", ")+") "+operator+" ("+placeholders(len(cond.After))+")", cond.After...)
+	}
+	orders := make([]string, len(keys.exprs))
+	for i, expr := range keys.exprs {
+		orders[i] = expr
+		if keys.desc {
+			orders[i] += " DESC"
+		}
+	}
+
+	// 続きの有無を判定するため1件多く取得する
+	query := `
+		SELECT k.*, ` + strings.Join(keys.columns, ", ") + ` FROM kifus k
+		` + b.clauses() + `
+		ORDER BY ` + strings.Join(orders, ", ") + `
+		LIMIT ? OFFSET ?
+	`
+	rows, err := db.Query(query, append(b.clauseArgs(), limit+1, offset)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	kifus := []*model.Kifu{}
+	var lastKeys []any
+	for rows.Next() {
+		if len(kifus) == limit {
+			return kifus, lastKeys, nil
+		}
+		scanner := &sortKeyScanner{row: rows, keys: make([]any, len(keys.exprs))}
+		kifu, err := scanKifu(scanner)
+		if err != nil {
+			return nil, nil, err
+		}
+		kifus = append(kifus, kifu)
+		lastKeys = scanner.keys
+	}
+	return kifus, nil, nil
+}
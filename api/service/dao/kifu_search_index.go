@@ -0,0 +1,139 @@
+// service/dao/kifu_search_index.go
+// 棋譜のキーワード検索用の全文検索インデックス（FTS5）
+//   kifu_search_documents: 棋譜ごとの検索対象の文字列（タイトル・対局者・棋譜情報・タグ・コメント）
+//   kifu_search: kifu_search_documentsを外部コンテンツとするFTS5テーブル（トリガーで同期する）
+// 日本語は単語に区切れないため、trigramトークナイザーで3文字ごとに索引する
+
+package dao
+
+import (
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropKifuSearchIndexTable() error {
+	query := `
+		DROP TABLE IF EXISTS kifu_search;
+		DROP TABLE IF EXISTS kifu_search_documents
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+func CreateKifuSearchIndexTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS kifu_search_documents (
+			id INTEGER PRIMARY KEY,
+			kifu_id TEXT NOT NULL UNIQUE,
+			title TEXT NOT NULL,
+			players TEXT NOT NULL,
+			options TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			comments TEXT NOT NULL,
+			FOREIGN KEY (kifu_id) REFERENCES kifus(id) ON DELETE CASCADE
+		);
+		CREATE VIRTUAL TABLE IF NOT EXISTS kifu_search USING fts5(
+			title, players, options, tags, comments,
+			content='kifu_search_documents', content_rowid='id', tokenize='trigram'
+		);
+		CREATE TRIGGER IF NOT EXISTS kifu_search_documents_ai AFTER INSERT ON kifu_search_documents BEGIN
+			INSERT INTO kifu_search (rowid, title, players, options, tags, comments)
+			VALUES (new.id, new.title, new.players, new.options, new.tags, new.comments);
+		END;
+		CREATE TRIGGER IF NOT EXISTS kifu_search_documents_ad AFTER DELETE ON kifu_search_documents BEGIN
+			INSERT INTO kifu_search (kifu_search, rowid, title, players, options, tags, comments)
+			VALUES ('delete', old.id, old.title, old.players, old.options, old.tags, old.comments);
+		END;
+		CREATE TRIGGER IF NOT EXISTS kifu_search_documents_au AFTER UPDATE ON kifu_search_documents BEGIN
+			INSERT INTO kifu_search (kifu_search, rowid, title, players, options, tags, comments)
+			VALUES ('delete', old.id, old.title, old.players, old.options, old.tags, old.comments);
+			INSERT INTO kifu_search (rowid, title, players, options, tags, comments)
+			VALUES (new.id, new.title, new.players, new.options, new.tags, new.comments);
+		END;
+		INSERT INTO kifu_search (kifu_search, rank) VALUES ('rank', 'bm25(10.0, 5.0, 2.0, 5.0, 1.0)')
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return syncKifuSearchDocuments()
+}
+
+// 棋譜から検索対象の文字列を集める（棋譜の削除は外部キーで連動する）
+const selectKifuSearchDocuments = `
+	SELECT
+		k.id,
+		k.title,
+		TRIM(COALESCE(k.black_player, '') || ' ' || COALESCE(k.white_player, '')),
+		COALESCE((SELECT GROUP_CONCAT(o.value, ' ') FROM kifu_options o WHERE o.kifu_id = k.id), ''),
+		COALESCE((SELECT GROUP_CONCAT(t.name, ' ') FROM kifu_tags t WHERE t.kifu_id = k.id), ''),
+		COALESCE((
+			SELECT GROUP_CONCAT(m.comment, CHAR(10)) FROM kifu_moves m
+			INNER JOIN kifu_branches br ON m.branch_id = br.id
+			WHERE br.kifu_id = k.id AND m.comment IS NOT NULL AND m.comment != ''
+		), '')
+	FROM kifus k
+`
+
+// 検索対象の文字列が無い棋譜を索引する（インデックス追加前の棋譜の移行）
+func syncKifuSearchDocuments() error {
+	query := `
+		INSERT INTO kifu_search_documents (kifu_id, title, players, options, tags, comments)
+	` + selectKifuSearchDocuments + `
+		WHERE NOT EXISTS (SELECT 1 FROM kifu_search_documents d WHERE d.kifu_id = k.id)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 棋譜の現在の内容で索引を更新する（棋譜の作成・更新のトランザクション内で呼ぶ）
+func RefreshKifuSearchDocument(tx *db.Tx, kifuID string) error {
+	query := `
+		INSERT INTO kifu_search_documents (kifu_id, title, players, options, tags, comments)
+	` + selectKifuSearchDocuments + `
+		WHERE k.id = ?
+		ON CONFLICT (kifu_id) DO UPDATE SET
+			title = excluded.title,
+			players = excluded.players,
+			options = excluded.options,
+			tags = excluded.tags,
+			comments = excluded.comments
+	`
+	res, err := tx.Exec(query, kifuID)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
+
+func ListKifuSearchDocumentsByKifuIDs(kifuIDs []string) (map[string]*model.KifuSearchDocument, error) {
+	documents := make(map[string]*model.KifuSearchDocument, len(kifuIDs))
+	if len(kifuIDs) == 0 {
+		return documents, nil
+	}
+	query := `
+		SELECT kifu_id, title, players, options, tags, comments FROM kifu_search_documents
+		WHERE kifu_id IN (` + placeholders(len(kifuIDs)) + `)
+	`
+	rows, err := db.Query(query, stringArgs(kifuIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var document model.KifuSearchDocument
+		err := rows.Scan(
+			&document.KifuID,
+			&document.Title,
+			&document.Players,
+			&document.Options,
+			&document.Tags,
+			&document.Comments,
+		)
+		if err != nil {
+			return nil, err
+		}
+		documents[document.KifuID] = &document
+	}
+	return documents, nil
+}
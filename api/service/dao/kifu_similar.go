@@ -0,0 +1,113 @@
+// service/dao/kifu_similar.go
+// 似ている棋譜の候補
+
+package dao
+
+import (
+	"strings"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+// 似ている棋譜の候補の条件（基準の棋譜の特徴）
+type SimilarKifuCondition struct {
+	KifuID    string                // 基準の棋譜（候補から除く）
+	TagKeys   []string              // タグのキー
+	PlayerIDs []string              // 対局者ID
+	NameKeys  []string              // 対局者の名前のキー
+	TimeRule  *model.TimeRuleString // 持ち時間
+	FirstMove *model.KifuMove       // メインラインの初手
+}
+
+// 基準の棋譜とタグ・対局者・持ち時間・初手のいずれかが共通する公開棋譜のID
+// 共通する項目の多い順（同数は更新日時の新しい順）にlimit件まで返す（類似度は取得後に計算する）
+func ListSimilarKifuCandidateIDs(cond *SimilarKifuCondition, limit int) ([]string, error) {
+	terms := []string{}
+	args := []any{}
+	if len(cond.TagKeys) > 0 {
+		terms = append(terms, `(
+			SELECT COUNT(*) FROM kifu_tags t
+			WHERE t.kifu_id = k.id AND t.name_key IN (`+placeholders(len(cond.TagKeys))+`)
+		)`)
+		args = append(args, stringArgs(cond.TagKeys)...)
+	}
+	if len(cond.PlayerIDs) > 0 || len(cond.NameKeys) > 0 {
+		terms = append(terms, `(
+			SELECT COUNT(*) FROM kifu_players p
+			WHERE p.kifu_id = k.id AND (p.player_id IN (`+placeholders(len(cond.PlayerIDs))+`) OR p.name_key IN (`+placeholders(len(cond.NameKeys))+`))
+		)`)
+		args = append(args, stringArgs(cond.PlayerIDs)...)
+		args = append(args, stringArgs(cond.NameKeys)...)
+	}
+	if cond.TimeRule != nil {
+		terms = append(terms, "(k.time_rule IS NOT NULL AND k.time_rule = ?)")
+		args = append(args, string(*cond.TimeRule))
+	}
+	if cond.FirstMove != nil {
+		terms = append(terms, `EXISTS (
+			SELECT 1 FROM kifu_branches b
+			INNER JOIN kifu_moves m ON m.branch_id = b.id AND m.number = 1
+			WHERE b.kifu_id = k.id AND b.root_branch_id IS NULL
+				AND m.piece = ? AND m.from_place = ? AND m.to_place = ?
+		)`)
+		args = append(args, cond.FirstMove.Piece, cond.FirstMove.FromPlace, cond.FirstMove.ToPlace)
+	}
+	if len(terms) == 0 {
+		return []string{}, nil
+	}
+
+	query := `
+		SELECT id FROM (
+			SELECT k.id, k.updated_at, ` + strings.Join(terms, " + ") + ` AS shared FROM kifus k
+			WHERE k.is_public = true AND k.id <> ?
+		)
+		WHERE shared > 0
+		ORDER BY shared DESC, updated_at DESC
+		LIMIT ?
+	`
+	rows, err := db.Query(query, append(args, cond.KifuID, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kifuIDs := []string{}
+	for rows.Next() {
+		var kifuID string
+		if err := rows.Scan(&kifuID); err != nil {
+			return nil, err
+		}
+		kifuIDs = append(kifuIDs, kifuID)
+	}
+	return kifuIDs, nil
+}
+
+// メインラインのmaxNumber手目までの指し手（棋譜ID -> 手数の順の指し手）
+func ListKifuOpeningMovesByKifuIDs(kifuIDs []string, maxNumber int64) (map[string][]*model.KifuMove, error) {
+	moves := make(map[string][]*model.KifuMove, len(kifuIDs))
+	if len(kifuIDs) == 0 {
+		return moves, nil
+	}
+	query := `
+		SELECT b.kifu_id, m.branch_id, m.number, m.piece, m.from_place, m.to_place FROM kifu_moves m
+		INNER JOIN kifu_branches b ON b.id = m.branch_id AND b.root_branch_id IS NULL
+		WHERE b.kifu_id IN (` + placeholders(len(kifuIDs)) + `) AND m.number <= ?
+		ORDER BY b.kifu_id, m.number
+	`
+	rows, err := db.Query(query, append(stringArgs(kifuIDs), maxNumber)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kifuID string
+		move := &model.KifuMove{}
+		if err := rows.Scan(&kifuID, &move.BranchID, &move.Number, &move.Piece, &move.FromPlace, &move.ToPlace); err != nil {
+			return nil, err
+		}
+		moves[kifuID] = append(moves[kifuID], move)
+	}
+	return moves, nil
+}
@@ -3,6 +3,8 @@
 package dao
 
 import (
+	"strings"
+
 	"github.com/jcytp/kifup-api/common/db"
 	"github.com/jcytp/kifup-api/service/model"
 )
@@ -18,6 +20,7 @@ func CreateKifuTagTable() error {
 		CREATE TABLE IF NOT EXISTS kifu_tags (
 			kifu_id TEXT NOT NULL,
 			name TEXT NOT NULL,
+			name_key TEXT NOT NULL DEFAULT '',
 			PRIMARY KEY (kifu_id, name),
 			FOREIGN KEY (kifu_id) REFERENCES kifus(id) ON DELETE CASCADE,
 			CHECK (LENGTH(name) >= 1 AND LENGTH(name) <= 50)
@@ -25,21 +28,61 @@ func CreateKifuTagTable() error {
 		CREATE INDEX IF NOT EXISTS idx_kifu_tags_kifu_id ON kifu_tags(kifu_id);
 		CREATE INDEX IF NOT EXISTS idx_kifu_tags_name ON kifu_tags(name)
 	`
-	_, err := db.Exec(query)
-	return err
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return migrateKifuTagTable()
+}
+
+// 既存のDBに追加されたカラムを反映する
+func migrateKifuTagTable() error {
+	if err := db.AddColumnIfNotExists("kifu_tags", "name_key", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	query := `CREATE INDEX IF NOT EXISTS idx_kifu_tags_name_key ON kifu_tags(name_key)`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return fillKifuTagNameKeys()
+}
+
+// name_key追加前のタグにキーを設定する
+func fillKifuTagNameKeys() error {
+	rows, err := db.Query(`SELECT DISTINCT name FROM kifu_tags WHERE name_key = ''`)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+
+	query := `UPDATE kifu_tags SET name_key = ? WHERE name = ?`
+	for _, name := range names {
+		if _, err := db.Exec(query, model.TagKey(name), name); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func InsertKifuTags(tags []*model.KifuTag) error {
+func InsertKifuTags(tx *db.Tx, tags []*model.KifuTag) error {
 	if len(tags) == 0 {
 		return nil
 	}
 
 	query := `
-		INSERT INTO kifu_tags (kifu_id, name)
-		VALUES (?, ?)
+		INSERT INTO kifu_tags (kifu_id, name, name_key)
+		VALUES (?, ?, ?)
 	`
 	for _, tag := range tags {
-		_, err := db.Exec(query, tag.KifuID, tag.Name)
+		_, err := tx.Exec(query, tag.KifuID, tag.Name, model.TagKey(tag.Name))
 		if err != nil {
 			return err
 		}
@@ -47,14 +90,14 @@ func InsertKifuTags(tags []*model.KifuTag) error {
 	return nil
 }
 
-func ClearKifuTagsByKifuID(kifuID string) error {
+func ClearKifuTagsByKifuID(tx *db.Tx, kifuID string) error {
 	query := `DELETE FROM kifu_tags WHERE kifu_id = ?`
-	_, err := db.Exec(query, kifuID)
+	_, err := tx.Exec(query, kifuID)
 	return err
 }
 
 func ListKifuTagsByKifuID(kifuID string) ([]*model.KifuTag, error) {
-	query := `SELECT * FROM kifu_tags WHERE kifu_id = ?`
+	query := `SELECT kifu_id, name FROM kifu_tags WHERE kifu_id = ?`
 	rows, err := db.Query(query, kifuID)
 	if err != nil {
 		return nil, err
@@ -73,34 +116,128 @@ func ListKifuTagsByKifuID(kifuID string) ([]*model.KifuTag, error) {
 	return tags, nil
 }
 
-// 不使用：タグによる棋譜の検索用
-// func ListPublicKifusByKifuTag(name string, limit int, offset int) ([]*model.Kifu, error) {
-// 	query := `
-// 		SELECT k.* FROM kifus k
-// 		INNER JOIN kifu_tags t ON k.id = t.kifu_id
-// 		WHERE t.name = ? AND k.is_public = true
-// 		ORDER BY k.updated_at DESC
-// 		LIMIT ? OFFSET ?
-// 	`
-// 	rows, err := db.Query(query, name, limit, offset)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	defer rows.Close()
-
-// 	kifus := []*model.Kifu{}
-// 	for rows.Next() {
-// 		kifu := &model.Kifu{}
-// 		err := rows.Scan(
-// 			&kifu.ID, &kifu.AccountID, &kifu.Title, &kifu.IsPublic,
-// 			&kifu.BlackPlayer, &kifu.WhitePlayer, &kifu.StartedAt, &kifu.EndedAt,
-// 			&kifu.TimeRule, &kifu.StartsWithBlack, &kifu.OriginalFormat,
-// 			&kifu.CreatedAt, &kifu.UpdatedAt,
-// 		)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		kifus = append(kifus, kifu)
-// 	}
-// 	return kifus, nil
-// }
+// 複数の棋譜のタグをまとめて取得する（棋譜ID→タグリスト）
+func ListKifuTagsByKifuIDs(kifuIDs []string) (map[string][]*model.KifuTag, error) {
+	tags := make(map[string][]*model.KifuTag, len(kifuIDs))
+	if len(kifuIDs) == 0 {
+		return tags, nil
+	}
+	query := `SELECT kifu_id, name FROM kifu_tags WHERE kifu_id IN (` + placeholders(len(kifuIDs)) + `)`
+	rows, err := db.Query(query, stringArgs(kifuIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tag := &model.KifuTag{}
+		err := rows.Scan(&tag.KifuID, &tag.Name)
+		if err != nil {
+			return nil, err
+		}
+		tags[tag.KifuID] = append(tags[tag.KifuID], tag)
+	}
+	return tags, nil
+}
+
+// キーごとに、既存のタグで最も多く使われている表記を取得する（キー→タグ名）
+func ListKifuTagNamesByKeys(keys []string) (map[string]string, error) {
+	names := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return names, nil
+	}
+	query := `
+		SELECT name_key, name FROM kifu_tags
+		WHERE name_key IN (` + placeholders(len(keys)) + `)
+		GROUP BY name_key, name
+		ORDER BY COUNT(*) ASC, MIN(rowid) DESC
+	`
+	rows, err := db.Query(query, stringArgs(keys)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, name string
+		if err := rows.Scan(&key, &name); err != nil {
+			return nil, err
+		}
+		names[key] = name // 後の行（多く使われている表記）で上書きする
+	}
+	return names, nil
+}
+
+// 公開棋譜に付いているタグを、付いている棋譜の多い順に取得する
+// keyPrefixを指定した場合は、キーまたは別名のキーが前方一致するタグのみ
+func ListPopularKifuTags(keyPrefix string, limit int) ([]*model.TagCount, error) {
+	b := &queryBuilder{}
+	b.join("INNER JOIN kifus k ON k.id = t.kifu_id")
+	b.where("k.is_public = true")
+	if keyPrefix != "" {
+		pattern := strings.TrimPrefix(likePattern(keyPrefix), "%")
+		b.where(`(
+			t.name_key LIKE ? ESCAPE '\'
+			OR t.name IN (SELECT a.name FROM tag_aliases a WHERE a.alias_key LIKE ? ESCAPE '\')
+		)`, pattern, pattern)
+	}
+	query := `
+		SELECT t.name, COUNT(*) AS count FROM kifu_tags t
+		` + b.clauses() + `
+		GROUP BY t.name
+		ORDER BY count DESC, t.name
+		LIMIT ?
+	`
+	rows, err := db.Query(query, append(b.clauseArgs(), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.TagCount{}
+	for rows.Next() {
+		tag := &model.TagCount{}
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// キーがfromKeysまたはtoNameと同じタグをtoNameに置き換え、タグが変わった棋譜のIDを返す
+func MergeKifuTags(tx *db.Tx, fromKeys []string, toName string) ([]string, error) {
+	keys := append([]string{model.TagKey(toName)}, fromKeys...)
+	target := `name_key IN (` + placeholders(len(keys)) + `) AND name != ?`
+	targetArgs := append(stringArgs(keys), toName)
+
+	query := `SELECT DISTINCT kifu_id FROM kifu_tags WHERE ` + target
+	rows, err := tx.Query(query, targetArgs...)
+	if err != nil {
+		return nil, err
+	}
+	kifuIDs := []string{}
+	for rows.Next() {
+		var kifuID string
+		if err := rows.Scan(&kifuID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		kifuIDs = append(kifuIDs, kifuID)
+	}
+	rows.Close()
+
+	// 統合先のタグを追加してから統合元のタグを削除する（既に統合先のタグがある棋譜は重複させない）
+	query = `
+		INSERT OR IGNORE INTO kifu_tags (kifu_id, name, name_key)
+		SELECT DISTINCT kifu_id, ?, ? FROM kifu_tags
+		WHERE ` + target
+	if _, err := tx.Exec(query, append([]any{toName, model.TagKey(toName)}, targetArgs...)...); err != nil {
+		return nil, err
+	}
+	query = `DELETE FROM kifu_tags WHERE ` + target
+	if _, err := tx.Exec(query, targetArgs...); err != nil {
+		return nil, err
+	}
+	return kifuIDs, nil
+}
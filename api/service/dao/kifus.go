@@ -34,6 +34,9 @@ func CreateKifuTable() error {
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             like_count INTEGER NOT NULL DEFAULT 0,
             comment_count INTEGER NOT NULL DEFAULT 0,
+			fingerprint TEXT,
+			version INTEGER NOT NULL DEFAULT 1,
+			forked_from TEXT,
 			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
 			CHECK (LENGTH(title) >= 1 AND LENGTH(title) <= 100),
 			CHECK (LENGTH(black_player) <= 100),
@@ -42,20 +45,69 @@ func CreateKifuTable() error {
 			CHECK (LENGTH(initial_position) <= 200)
 		);
 		CREATE INDEX IF NOT EXISTS idx_kifus_account_id ON kifus(account_id);
-		CREATE INDEX IF NOT EXISTS idx_kifus_is_public ON kifus(is_public);
-		CREATE INDEX IF NOT EXISTS idx_kifus_updated_at ON kifus(updated_at)
+		CREATE INDEX IF NOT EXISTS idx_kifus_is_public ON kifus(is_public)
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return migrateKifuTable()
+}
+
+// 既存のDBに追加されたカラムを反映する
+// カラムの順序がSELECT *のScan順と一致するよう、CREATE TABLEの末尾と同じ順で追加する
+func migrateKifuTable() error {
+	if err := db.AddColumnIfNotExists("kifus", "fingerprint", "TEXT"); err != nil {
+		return err
+	}
+	if err := db.AddColumnIfNotExists("kifus", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := db.AddColumnIfNotExists("kifus", "forked_from", "TEXT"); err != nil {
+		return err
+	}
+	// フィンガープリントと一覧の並び順ごとのインデックス（同順位はIDで並べる）
+	query := `
+		CREATE INDEX IF NOT EXISTS idx_kifus_fingerprint ON kifus(fingerprint);
+		DROP INDEX IF EXISTS idx_kifus_updated_at;
+		CREATE INDEX IF NOT EXISTS idx_kifus_updated_at_id ON kifus(updated_at, id);
+		CREATE INDEX IF NOT EXISTS idx_kifus_like_count ON kifus(like_count, id);
+		CREATE INDEX IF NOT EXISTS idx_kifus_comment_count ON kifus(comment_count, id);
+		CREATE INDEX IF NOT EXISTS idx_kifus_started_at ON kifus(COALESCE(started_at, ''), id);
+		CREATE INDEX IF NOT EXISTS idx_kifus_title ON kifus(title, id)
 	`
 	_, err := db.Exec(query)
 	return err
 }
 
-func InsertKifu(kifu *model.Kifu) (string, error) {
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKifu(row rowScanner) (*model.Kifu, error) {
+	kifu := &model.Kifu{}
+	err := row.Scan(
+		&kifu.ID, &kifu.AccountID, &kifu.Title, &kifu.IsPublic,
+		&kifu.BlackPlayer, &kifu.WhitePlayer, &kifu.StartedAt,
+		&kifu.TimeRule, &kifu.InitialPosition,
+		&kifu.CreatedAt, &kifu.UpdatedAt,
+		&kifu.LikeCount, &kifu.CommentCount,
+		&kifu.Fingerprint, &kifu.Version,
+		&kifu.ForkedFrom,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return kifu, nil
+}
+
+func InsertKifu(tx *db.Tx, kifu *model.Kifu) (string, error) {
 	kifu.ID = auxi.NewULID()
 	now := time.Now()
 	kifu.CreatedAt = now
 	kifu.UpdatedAt = now
 	kifu.LikeCount = 0
 	kifu.CommentCount = 0
+	kifu.Version = 1
 
 	query := `
 		INSERT INTO kifus (
@@ -63,21 +115,25 @@ func InsertKifu(kifu *model.Kifu) (string, error) {
 			black_player, white_player, started_at,
 			time_rule, initial_position,
 			created_at, updated_at,
-			like_count, comment_count
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			like_count, comment_count,
+			fingerprint, version,
+			forked_from
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := db.Exec(
+	_, err := tx.Exec(
 		query,
 		kifu.ID, kifu.AccountID, kifu.Title, kifu.IsPublic,
 		kifu.BlackPlayer, kifu.WhitePlayer, kifu.StartedAt,
 		kifu.TimeRule, kifu.InitialPosition,
 		kifu.CreatedAt, kifu.UpdatedAt,
 		kifu.LikeCount, kifu.CommentCount,
+		kifu.Fingerprint, kifu.Version,
+		kifu.ForkedFrom,
 	)
 	return kifu.ID, err
 }
 
-func UpdateKifu(kifu *model.Kifu) error {
+func UpdateKifu(tx *db.Tx, kifu *model.Kifu) error {
 	kifu.UpdatedAt = time.Now()
 
 	// 初期局面は変更不可（新規作成が必要）
@@ -89,7 +145,7 @@ func UpdateKifu(kifu *model.Kifu) error {
 			updated_at = ?
 		WHERE id = ? AND account_id = ?
 	`
-	res, err := db.Exec(
+	res, err := tx.Exec(
 		query,
 		kifu.Title, kifu.IsPublic,
 		kifu.BlackPlayer, kifu.WhitePlayer, kifu.StartedAt,
@@ -120,126 +176,65 @@ func GetKifu(kifuID string) (*model.Kifu, error) {
 		SELECT * FROM kifus
 		WHERE id = ?
 	`
-	kifu := &model.Kifu{}
-	err := db.QueryRow(query, kifuID).Scan(
-		&kifu.ID, &kifu.AccountID, &kifu.Title, &kifu.IsPublic,
-		&kifu.BlackPlayer, &kifu.WhitePlayer, &kifu.StartedAt,
-		&kifu.TimeRule, &kifu.InitialPosition,
-		&kifu.CreatedAt, &kifu.UpdatedAt,
-		&kifu.LikeCount, &kifu.CommentCount,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return kifu, nil
-}
-
-func CountKifusByAccountID(accountID string) (int, error) {
-	query := `
-		SELECT COUNT(*) FROM kifus
-		WHERE account_id = ?
-	`
-	var count int
-	if err := db.QueryRow(query, accountID).Scan(&count); err != nil {
-		return 0, err
-	}
-	return count, nil
+	return scanKifu(db.QueryRow(query, kifuID))
 }
 
-func ListKifusByAccountID(accountID string, limit int, offset int) ([]*model.Kifu, error) {
+// 版が一致する場合のみ版を1つ進める（他の更新との競合検出に使用）
+func IncrementKifuVersion(tx *db.Tx, kifuID string, version int64) error {
 	query := `
-		SELECT * FROM kifus
-		WHERE account_id = ?
-		ORDER BY updated_at DESC
-		LIMIT ? OFFSET ?
+		UPDATE kifus
+		SET version = version + 1, updated_at = ?
+		WHERE id = ? AND version = ?
 	`
-	rows, err := db.Query(query, accountID, limit, offset)
+	res, err := tx.Exec(query, time.Now(), kifuID, version)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	kifus := []*model.Kifu{}
-	for rows.Next() {
-		kifu := &model.Kifu{}
-		err := rows.Scan(
-			&kifu.ID, &kifu.AccountID, &kifu.Title, &kifu.IsPublic,
-			&kifu.BlackPlayer, &kifu.WhitePlayer, &kifu.StartedAt,
-			&kifu.TimeRule, &kifu.InitialPosition,
-			&kifu.CreatedAt, &kifu.UpdatedAt,
-			&kifu.LikeCount, &kifu.CommentCount,
-		)
-		if err != nil {
-			return nil, err
-		}
-		kifus = append(kifus, kifu)
+		return err
 	}
-	return kifus, nil
+	return db.CheckAffectedRows(res, 1)
 }
 
-func CountPublicKifus() (int, error) {
+func UpdateKifuFingerprint(tx *db.Tx, kifuID string, fingerprint string) error {
 	query := `
-		SELECT COUNT(*) FROM kifus
-		WHERE is_public = true 
+		UPDATE kifus
+		SET fingerprint = ?
+		WHERE id = ?
 	`
-	var count int
-	if err := db.QueryRow(query).Scan(&count); err != nil {
-		return 0, err
+	res, err := tx.Exec(query, fingerprint, kifuID)
+	if err != nil {
+		return err
 	}
-	return count, nil
+	return db.CheckAffectedRows(res, 1)
 }
 
-func ListPublicKifus(limit int, offset int) ([]*model.Kifu, error) {
-	query := `
-		SELECT * FROM kifus 
-		WHERE is_public = true 
-		ORDER BY updated_at DESC
-		LIMIT ? OFFSET ?
-	`
-	rows, err := db.Query(query, limit, offset)
+// フィンガープリントが未設定の棋譜（fingerprint追加前の棋譜の移行）
+func ListKifuIDsWithoutFingerprint() ([]string, error) {
+	query := `SELECT id FROM kifus WHERE fingerprint IS NULL`
+	rows, err := db.Query(query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	kifus := []*model.Kifu{}
+	kifuIDs := []string{}
 	for rows.Next() {
-		kifu := &model.Kifu{}
-		err := rows.Scan(
-			&kifu.ID, &kifu.AccountID, &kifu.Title, &kifu.IsPublic,
-			&kifu.BlackPlayer, &kifu.WhitePlayer, &kifu.StartedAt,
-			&kifu.TimeRule, &kifu.InitialPosition,
-			&kifu.CreatedAt, &kifu.UpdatedAt,
-			&kifu.LikeCount, &kifu.CommentCount,
-		)
-		if err != nil {
+		var kifuID string
+		if err := rows.Scan(&kifuID); err != nil {
 			return nil, err
 		}
-		kifus = append(kifus, kifu)
-	}
-	return kifus, nil
-}
-
-func CountPublicKifusByAccountID(accountID string) (int, error) {
-	query := `
-		SELECT COUNT(*) FROM kifus
-		WHERE is_public = true AND account_id = ?
-	`
-	var count int
-	if err := db.QueryRow(query, accountID).Scan(&count); err != nil {
-		return 0, err
+		kifuIDs = append(kifuIDs, kifuID)
 	}
-	return count, nil
+	return kifuIDs, nil
 }
 
-func ListPublicKifusByAccountID(accountID string, limit int, offset int) ([]*model.Kifu, error) {
+// 同一の棋譜（自身の棋譜、または公開棋譜）を取得
+func ListDuplicateKifus(fingerprint string, accountID string, limit int) ([]*model.Kifu, error) {
 	query := `
 		SELECT * FROM kifus
-		WHERE is_public = true AND account_id = ?
-		ORDER BY updated_at DESC
-		LIMIT ? OFFSET ?
+		WHERE fingerprint = ? AND (account_id = ? OR is_public = true)
+		ORDER BY created_at
+		LIMIT ?
 	`
-	rows, err := db.Query(query, accountID, limit, offset)
+	rows, err := db.Query(query, fingerprint, accountID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -247,14 +242,7 @@ func ListPublicKifusByAccountID(accountID string, limit int, offset int) ([]*mod
 
 	kifus := []*model.Kifu{}
 	for rows.Next() {
-		kifu := &model.Kifu{}
-		err := rows.Scan(
-			&kifu.ID, &kifu.AccountID, &kifu.Title, &kifu.IsPublic,
-			&kifu.BlackPlayer, &kifu.WhitePlayer, &kifu.StartedAt,
-			&kifu.TimeRule, &kifu.InitialPosition,
-			&kifu.CreatedAt, &kifu.UpdatedAt,
-			&kifu.LikeCount, &kifu.CommentCount,
-		)
+		kifu, err := scanKifu(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -263,39 +251,39 @@ func ListPublicKifusByAccountID(accountID string, limit int, offset int) ([]*mod
 	return kifus, nil
 }
 
-func IncrementKifuLikeCount(kifuID string) error {
+func IncrementKifuLikeCount(tx *db.Tx, kifuID string) error {
 	query := `
 		UPDATE kifus
 		SET like_count = like_count + 1
 		WHERE id = ?
 	`
-	res, err := db.Exec(query, kifuID)
+	res, err := tx.Exec(query, kifuID)
 	if err != nil {
 		return err
 	}
 	return db.CheckAffectedRows(res, 1)
 }
 
-func DecrementKifuLikeCount(kifuID string) error {
+func DecrementKifuLikeCount(tx *db.Tx, kifuID string) error {
 	query := `
 		UPDATE kifus
 		SET like_count = like_count - 1
 		WHERE id = ? AND like_count > 0
 	`
-	res, err := db.Exec(query, kifuID)
+	res, err := tx.Exec(query, kifuID)
 	if err != nil {
 		return err
 	}
 	return db.CheckAffectedRows(res, 1)
 }
 
-func IncrementKifuCommentCount(kifuID string) error {
+func IncrementKifuCommentCount(tx *db.Tx, kifuID string) error {
 	query := `
 		UPDATE kifus
 		SET comment_count = comment_count + 1
 		WHERE id = ?
 	`
-	res, err := db.Exec(query, kifuID)
+	res, err := tx.Exec(query, kifuID)
 	if err != nil {
 		return err
 	}
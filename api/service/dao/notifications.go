@@ -0,0 +1,153 @@
+// service/dao/notifications.go
+// アカウントへの通知（非公開になった棋譜の通知は表示・送信しない）
+
+package dao
+
+import (
+	"time"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func CreateNotificationTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS notifications (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			saved_search_id TEXT,
+			kifu_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			read_at TIMESTAMP,
+			emailed_at TIMESTAMP,
+			UNIQUE (saved_search_id, kifu_id),
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+			FOREIGN KEY (saved_search_id) REFERENCES saved_searches(id) ON DELETE CASCADE,
+			FOREIGN KEY (kifu_id) REFERENCES kifus(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_notifications_account_id ON notifications(account_id, created_at)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 通知を追加する（同じ検索条件・棋譜の通知が既にある場合は追加しない）
+func InsertNotification(notification *model.Notification) error {
+	notification.ID = auxi.NewULID()
+	notification.CreatedAt = time.Now()
+
+	query := `
+		INSERT OR IGNORE INTO notifications (
+			id, account_id, type, saved_search_id, kifu_id, created_at
+		) VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := db.Exec(
+		query,
+		notification.ID, notification.AccountID, notification.Type,
+		notification.SavedSearchID, notification.KifuID, notification.CreatedAt,
+	)
+	return err
+}
+
+func CountNotificationsByAccountID(accountID string, unreadOnly bool) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM notifications n
+		INNER JOIN kifus k ON k.id = n.kifu_id AND k.is_public = true
+		WHERE n.account_id = ? AND (? = false OR n.read_at IS NULL)
+	`
+	var count int
+	if err := db.QueryRow(query, accountID, unreadOnly).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func ListNotificationsByAccountID(accountID string, unreadOnly bool, limit int, offset int) ([]*model.Notification, error) {
+	query := `
+		SELECT n.id, n.account_id, n.type, n.saved_search_id, n.kifu_id, n.created_at, n.read_at, n.emailed_at
+		FROM notifications n
+		INNER JOIN kifus k ON k.id = n.kifu_id AND k.is_public = true
+		WHERE n.account_id = ? AND (? = false OR n.read_at IS NULL)
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := db.Query(query, accountID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		notification := &model.Notification{}
+		err := rows.Scan(
+			&notification.ID, &notification.AccountID, &notification.Type, &notification.SavedSearchID,
+			&notification.KifuID, &notification.CreatedAt, &notification.ReadAt, &notification.EmailedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+func MarkNotificationRead(notificationID string, accountID string) error {
+	query := `
+		UPDATE notifications SET read_at = COALESCE(read_at, ?)
+		WHERE id = ? AND account_id = ?
+	`
+	res, err := db.Exec(query, time.Now(), notificationID, accountID)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
+
+func MarkAllNotificationsRead(accountID string) error {
+	query := `
+		UPDATE notifications SET read_at = ?
+		WHERE account_id = ? AND read_at IS NULL
+	`
+	_, err := db.Exec(query, time.Now(), accountID)
+	return err
+}
+
+// メールで知らせる通知（メール通知を有効にした検索条件の、未読・未送信の通知）
+func ListNotificationDigestItems() ([]*model.NotificationDigestItem, error) {
+	query := `
+		SELECT n.id, n.account_id, a.email, s.id, s.name, k.id, k.title FROM notifications n
+		INNER JOIN saved_searches s ON s.id = n.saved_search_id AND s.email_digest = true
+		INNER JOIN kifus k ON k.id = n.kifu_id AND k.is_public = true
+		INNER JOIN accounts a ON a.id = n.account_id
+		WHERE n.read_at IS NULL AND n.emailed_at IS NULL
+		ORDER BY n.account_id, s.created_at, s.id, n.created_at
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*model.NotificationDigestItem{}
+	for rows.Next() {
+		item := &model.NotificationDigestItem{}
+		err := rows.Scan(&item.NotificationID, &item.AccountID, &item.Email, &item.SavedSearchID, &item.SavedSearchName, &item.KifuID, &item.KifuTitle)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func MarkNotificationsEmailed(notificationIDs []string) error {
+	if len(notificationIDs) == 0 {
+		return nil
+	}
+	query := `UPDATE notifications SET emailed_at = ? WHERE id IN (` + placeholders(len(notificationIDs)) + `)`
+	_, err := db.Exec(query, append([]any{time.Now()}, stringArgs(notificationIDs)...)...)
+	return err
+}
@@ -0,0 +1,318 @@
+// service/dao/player_stats.go
+// 対局者の成績の集計（公開棋譜のみ）
+// 条件の無い先手・後手ごとの成績はplayer_statsに保存し、棋譜の対局者の対応が変わった対局者のみ集計し直す
+// 集計し直すのは対応を変える書き込みのトランザクション内で、読み込み時は古い集計が残っている場合のみ
+
+package dao
+
+import (
+	"time"
+
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropPlayerStatsTable() error {
+	query := `
+		DROP TRIGGER IF EXISTS kifu_players_stats_ai;
+		DROP TRIGGER IF EXISTS kifu_players_stats_ad;
+		DROP TRIGGER IF EXISTS kifu_players_stats_au;
+		DROP TRIGGER IF EXISTS kifus_player_stats_ad;
+		DROP TABLE IF EXISTS player_stats
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 棋譜の対局者の対応が追加・削除・変更されたら（棋譜の削除を含む）、対局者の集計を古いとする
+func CreatePlayerStatsTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS player_stats (
+			player_id TEXT NOT NULL,
+			side INTEGER NOT NULL,
+			games INTEGER NOT NULL,
+			wins INTEGER NOT NULL,
+			losses INTEGER NOT NULL,
+			draws INTEGER NOT NULL,
+			move_total INTEGER NOT NULL,
+			move_games INTEGER NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (player_id, side),
+			FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_player_stats_games ON player_stats(games);
+		CREATE TRIGGER IF NOT EXISTS kifu_players_stats_ai AFTER INSERT ON kifu_players
+		WHEN NEW.player_id IS NOT NULL BEGIN
+			UPDATE players SET stats_stale = true WHERE id = NEW.player_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS kifu_players_stats_ad AFTER DELETE ON kifu_players
+		WHEN OLD.player_id IS NOT NULL BEGIN
+			UPDATE players SET stats_stale = true WHERE id = OLD.player_id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS kifu_players_stats_au AFTER UPDATE ON kifu_players BEGIN
+			UPDATE players SET stats_stale = true WHERE id IN (OLD.player_id, NEW.player_id);
+		END;
+		CREATE TRIGGER IF NOT EXISTS kifus_player_stats_ad AFTER DELETE ON kifus BEGIN
+			UPDATE players SET stats_stale = true
+			WHERE id IN (SELECT player_id FROM kifu_players WHERE kifu_id = OLD.id);
+		END
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+// 成績の集計列（kifu_players p）
+const playerRecordColumns = `
+	COUNT(*),
+	COUNT(CASE WHEN p.result = 1 THEN 1 END),
+	COUNT(CASE WHEN p.result = -1 THEN 1 END),
+	COUNT(CASE WHEN p.result = 0 THEN 1 END),
+	COALESCE(SUM(CASE WHEN p.move_count > 0 THEN p.move_count END), 0),
+	COUNT(CASE WHEN p.move_count > 0 THEN 1 END)
+`
+
+// 成績の集計列の読み込み先
+func playerRecordDest(record *model.PlayerRecord) []any {
+	return []any{
+		&record.Games, &record.Wins, &record.Losses, &record.Draws,
+		&record.MoveTotal, &record.MoveGames,
+	}
+}
+
+// 集計が古い対局者がいるか
+func HasStalePlayerStats() (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM players WHERE stats_stale = true)`
+	var exists bool
+	if err := db.QueryRow(query).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// 集計が古い対局者の成績を集計し直す
+func RefreshStalePlayerStats(tx *db.Tx) error {
+	query := `
+		DELETE FROM player_stats
+		WHERE player_id IN (SELECT id FROM players WHERE stats_stale = true)
+	`
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO player_stats (player_id, side, games, wins, losses, draws, move_total, move_games, updated_at)
+		SELECT p.player_id, p.side, ` + playerRecordColumns + `, ?
+		FROM kifu_players p
+		INNER JOIN kifus k ON k.id = p.kifu_id
+		WHERE k.is_public = true
+			AND p.player_id IN (SELECT id FROM players WHERE stats_stale = true)
+		GROUP BY p.player_id, p.side
+	`
+	if _, err := tx.Exec(query, time.Now()); err != nil {
+		return err
+	}
+
+	query = `UPDATE players SET stats_stale = false WHERE stats_stale = true`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// 対局者の先手・後手ごとの成績（集計済みの値、公開棋譜の無い側は含まない）
+func ListPlayerStatsByPlayerIDs(playerIDs []string) (map[string][]*model.PlayerStats, error) {
+	stats := make(map[string][]*model.PlayerStats, len(playerIDs))
+	if len(playerIDs) == 0 {
+		return stats, nil
+	}
+	query := `
+		SELECT player_id, side, games, wins, losses, draws, move_total, move_games, updated_at FROM player_stats
+		WHERE player_id IN (` + placeholders(len(playerIDs)) + `)
+		ORDER BY player_id, side
+	`
+	rows, err := db.Query(query, stringArgs(playerIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		stat := &model.PlayerStats{}
+		dest := append([]any{&stat.PlayerID, &stat.Side}, playerRecordDest(&stat.PlayerRecord)...)
+		if err := rows.Scan(append(dest, &stat.UpdatedAt)...); err != nil {
+			return nil, err
+		}
+		stats[stat.PlayerID] = append(stats[stat.PlayerID], stat)
+	}
+	return stats, nil
+}
+
+// 名前が前方一致する対局者（公開棋譜の対局数の多い順、集計済みの値を使う）
+func ListPlayersByStats(aliasKeyPrefix string, limit int, offset int) ([]*model.Player, error) {
+	query := `
+		SELECT pl.id, pl.name, pl.created_at FROM players pl
+		INNER JOIN player_stats s ON s.player_id = pl.id
+		WHERE ? = '' OR EXISTS (
+			SELECT 1 FROM player_aliases a
+			WHERE a.player_id = pl.id AND a.alias_key LIKE ? ESCAPE '\'
+		)
+		GROUP BY pl.id
+		ORDER BY SUM(s.games) DESC, pl.name, pl.id
+		LIMIT ? OFFSET ?
+	`
+	rows, err := db.Query(query, aliasKeyPrefix, likePattern(aliasKeyPrefix)[1:], limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := []*model.Player{}
+	for rows.Next() {
+		player := &model.Player{}
+		if err := rows.Scan(&player.ID, &player.Name, &player.CreatedAt); err != nil {
+			return nil, err
+		}
+		players = append(players, player)
+	}
+	return players, nil
+}
+
+func CountPlayersByStats(aliasKeyPrefix string) (int, error) {
+	query := `
+		SELECT COUNT(DISTINCT s.player_id) FROM player_stats s
+		WHERE ? = '' OR EXISTS (
+			SELECT 1 FROM player_aliases a
+			WHERE a.player_id = s.player_id AND a.alias_key LIKE ? ESCAPE '\'
+		)
+	`
+	var count int
+	if err := db.QueryRow(query, aliasKeyPrefix, likePattern(aliasKeyPrefix)[1:]).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ------------------------------------------------------------
+// 条件付きの集計（その都度集計する）
+
+// 対局者の集計の条件（nil・空の条件では絞り込まない）
+type PlayerStatsCondition struct {
+	StartedFrom   *string  // 対局日の範囲の開始（YYYY-MM-DD）
+	StartedBefore *string  // 対局日の範囲の終了（YYYY-MM-DD、この日を含まない）
+	TagKeys       []string // タグのキー（全てのタグを持つ棋譜）
+}
+
+// 条件が無いか（集計済みの値を使えるか）
+func (cond *PlayerStatsCondition) IsEmpty() bool {
+	return cond.StartedFrom == nil && cond.StartedBefore == nil && len(cond.TagKeys) == 0
+}
+
+// 対局者の公開棋譜のうち、条件に一致する対局（kifu_players p, kifus k）
+func (cond *PlayerStatsCondition) build(playerID string) *queryBuilder {
+	b := &queryBuilder{}
+	b.join("INNER JOIN kifus k ON k.id = p.kifu_id")
+	b.where("p.player_id = ?", playerID)
+	b.where("k.is_public = true")
+	// started_atは「YYYY-MM-DD hh:mm:ss ...」の文字列で保存されるため、日付の文字列と比較できる
+	if cond.StartedFrom != nil {
+		b.where("k.started_at >= ?", *cond.StartedFrom)
+	}
+	if cond.StartedBefore != nil {
+		b.where("k.started_at < ?", *cond.StartedBefore)
+	}
+	if len(cond.TagKeys) > 0 {
+		args := append(stringArgs(cond.TagKeys), len(cond.TagKeys))
+		b.where(`(
+			SELECT COUNT(DISTINCT ft.name_key) FROM kifu_tags ft
+			WHERE ft.kifu_id = p.kifu_id AND ft.name_key IN (`+placeholders(len(cond.TagKeys))+`)
+		) = ?`, args...)
+	}
+	return b
+}
+
+// 先手・後手ごとの成績
+func ListPlayerSideRecords(playerID string, cond *PlayerStatsCondition) (map[model.PlayerSide]*model.PlayerRecord, error) {
+	b := cond.build(playerID)
+	query := `SELECT p.side, ` + playerRecordColumns + ` FROM kifu_players p ` + b.clauses() + ` GROUP BY p.side`
+	rows, err := db.Query(query, b.clauseArgs()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := map[model.PlayerSide]*model.PlayerRecord{}
+	for rows.Next() {
+		var side model.PlayerSide
+		record := &model.PlayerRecord{}
+		if err := rows.Scan(append([]any{&side}, playerRecordDest(record)...)...); err != nil {
+			return nil, err
+		}
+		records[side] = record
+	}
+	return records, nil
+}
+
+// 戦型（タグ）ごとの成績（対局数の多い順）
+func ListPlayerOpeningRecords(playerID string, cond *PlayerStatsCondition, limit int) ([]*model.PlayerOpeningRecord, error) {
+	b := cond.build(playerID)
+	b.join("INNER JOIN kifu_tags t ON t.kifu_id = p.kifu_id")
+	query := `
+		SELECT t.name, ` + playerRecordColumns + ` FROM kifu_players p ` + b.clauses() + `
+		GROUP BY t.name
+		ORDER BY COUNT(*) DESC, t.name
+		LIMIT ?
+	`
+	rows, err := db.Query(query, append(b.clauseArgs(), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*model.PlayerOpeningRecord{}
+	for rows.Next() {
+		record := &model.PlayerOpeningRecord{}
+		if err := rows.Scan(append([]any{&record.Name}, playerRecordDest(&record.PlayerRecord)...)...); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// 相手ごとの成績（対局数の多い順、対局者が確定していない相手は名前のキーごと）
+func ListPlayerOpponentRecords(playerID string, cond *PlayerStatsCondition, limit int) ([]*model.PlayerOpponentRecord, error) {
+	b := cond.build(playerID)
+	b.join("INNER JOIN kifu_players o ON o.kifu_id = p.kifu_id AND o.side != p.side")
+	b.join("LEFT JOIN players op ON op.id = o.player_id")
+	// 対局者が確定している相手は表示名、確定していない相手は棋譜上の名前
+	query := `
+		SELECT o.player_id, COALESCE(MIN(op.name), MIN(o.name)), MAX(k.started_at), ` + playerRecordColumns + `
+		FROM kifu_players p ` + b.clauses() + `
+		GROUP BY o.player_id, CASE WHEN o.player_id IS NULL THEN o.name_key END
+		ORDER BY COUNT(*) DESC, MAX(k.started_at) DESC, o.player_id, MIN(o.name)
+		LIMIT ?
+	`
+	rows, err := db.Query(query, append(b.clauseArgs(), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*model.PlayerOpponentRecord{}
+	for rows.Next() {
+		record := &model.PlayerOpponentRecord{}
+		var lastStartedAt *string
+		dest := append([]any{&record.PlayerID, &record.Name, &lastStartedAt}, playerRecordDest(&record.PlayerRecord)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if lastStartedAt != nil {
+			// 集計した値は型の情報が失われるため、保存時の文字列から読み込む
+			t, err := db.ParseTime(*lastStartedAt)
+			if err != nil {
+				return nil, err
+			}
+			record.LastStartedAt = &t
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
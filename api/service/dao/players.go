@@ -0,0 +1,194 @@
+// service/dao/players.go
+// 対局者と、その別名（棋譜上の名前の表記）
+
+package dao
+
+import (
+	"time"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropPlayerTable() error {
+	query := `
+		DROP TABLE IF EXISTS player_aliases;
+		DROP TABLE IF EXISTS players
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+func CreatePlayerTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS players (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (LENGTH(name) >= 1 AND LENGTH(name) <= 100)
+		);
+		CREATE TABLE IF NOT EXISTS player_aliases (
+			alias_key TEXT PRIMARY KEY,
+			alias TEXT NOT NULL,
+			player_id TEXT NOT NULL,
+			FOREIGN KEY (player_id) REFERENCES players(id) ON DELETE CASCADE,
+			CHECK (LENGTH(alias) >= 1 AND LENGTH(alias) <= 100)
+		);
+		CREATE INDEX IF NOT EXISTS idx_player_aliases_player_id ON player_aliases(player_id)
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return migratePlayerTable()
+}
+
+func migratePlayerTable() error {
+	// 成績の集計（player_stats）が古いか
+	if err := db.AddColumnIfNotExists("players", "stats_stale", "BOOLEAN NOT NULL DEFAULT true"); err != nil {
+		return err
+	}
+	// 集計が古い対局者だけのインデックス（古い集計の有無を確認する）
+	query := `CREATE INDEX IF NOT EXISTS idx_players_stats_stale ON players(id) WHERE stats_stale = true`
+	_, err := db.Exec(query)
+	return err
+}
+
+func InsertPlayer(tx *db.Tx, player *model.Player) (string, error) {
+	player.ID = auxi.NewULID()
+	player.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO players (id, name, created_at)
+		VALUES (?, ?, ?)
+	`
+	_, err := tx.Exec(query, player.ID, player.Name, player.CreatedAt)
+	return player.ID, err
+}
+
+func GetPlayer(playerID string) (*model.Player, error) {
+	query := `SELECT id, name, created_at FROM players WHERE id = ?`
+	player := &model.Player{}
+	if err := db.QueryRow(query, playerID).Scan(&player.ID, &player.Name, &player.CreatedAt); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+func ListPlayersByIDs(playerIDs []string) (map[string]*model.Player, error) {
+	players := make(map[string]*model.Player, len(playerIDs))
+	if len(playerIDs) == 0 {
+		return players, nil
+	}
+	query := `SELECT id, name, created_at FROM players WHERE id IN (` + placeholders(len(playerIDs)) + `)`
+	rows, err := db.Query(query, stringArgs(playerIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		player := &model.Player{}
+		if err := rows.Scan(&player.ID, &player.Name, &player.CreatedAt); err != nil {
+			return nil, err
+		}
+		players[player.ID] = player
+	}
+	return players, nil
+}
+
+// ------------------------------------------------------------
+// 別名
+
+// 別名を登録する（既に他の対局者の別名の場合は付け替える）
+func UpsertPlayerAlias(tx *db.Tx, alias *model.PlayerAlias) error {
+	query := `
+		INSERT INTO player_aliases (alias_key, alias, player_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT (alias_key) DO UPDATE SET
+			alias = excluded.alias,
+			player_id = excluded.player_id
+	`
+	_, err := tx.Exec(query, alias.AliasKey, alias.Alias, alias.PlayerID)
+	return err
+}
+
+// 別名を登録する（既に登録されている別名は変更しない）
+func InsertPlayerAliasIfNotExists(tx *db.Tx, alias *model.PlayerAlias) error {
+	query := `
+		INSERT OR IGNORE INTO player_aliases (alias_key, alias, player_id)
+		VALUES (?, ?, ?)
+	`
+	_, err := tx.Exec(query, alias.AliasKey, alias.Alias, alias.PlayerID)
+	return err
+}
+
+// 別名のキーが一致する対局者のID（無ければnil）
+func GetPlayerIDByAliasKey(tx *db.Tx, aliasKey string) (*string, error) {
+	query := `SELECT player_id FROM player_aliases WHERE alias_key = ?`
+	rows, err := tx.Query(query, aliasKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var playerID string
+	if err := rows.Scan(&playerID); err != nil {
+		return nil, err
+	}
+	return &playerID, nil
+}
+
+// 別名のキーが前方一致する対局者のID（姓のみ・段級位付きの名前の候補）
+func ListPlayerIDsByAliasKeyPrefix(tx *db.Tx, keyPrefix string, limit int) ([]string, error) {
+	query := `
+		SELECT DISTINCT player_id FROM player_aliases
+		WHERE alias_key LIKE ? ESCAPE '\'
+		ORDER BY player_id
+		LIMIT ?
+	`
+	rows, err := tx.Query(query, likePattern(keyPrefix)[1:], limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playerIDs := []string{}
+	for rows.Next() {
+		var playerID string
+		if err := rows.Scan(&playerID); err != nil {
+			return nil, err
+		}
+		playerIDs = append(playerIDs, playerID)
+	}
+	return playerIDs, nil
+}
+
+func ListPlayerAliasesByPlayerIDs(playerIDs []string) (map[string][]*model.PlayerAlias, error) {
+	aliases := make(map[string][]*model.PlayerAlias, len(playerIDs))
+	if len(playerIDs) == 0 {
+		return aliases, nil
+	}
+	query := `
+		SELECT alias_key, alias, player_id FROM player_aliases
+		WHERE player_id IN (` + placeholders(len(playerIDs)) + `)
+		ORDER BY alias
+	`
+	rows, err := db.Query(query, stringArgs(playerIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		alias := &model.PlayerAlias{}
+		if err := rows.Scan(&alias.AliasKey, &alias.Alias, &alias.PlayerID); err != nil {
+			return nil, err
+		}
+		aliases[alias.PlayerID] = append(aliases[alias.PlayerID], alias)
+	}
+	return aliases, nil
+}
@@ -0,0 +1,151 @@
+// service/dao/saved_searches.go
+// 保存した検索条件
+
+package dao
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jcytp/kifup-api/common/auxi"
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func CreateSavedSearchTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS saved_searches (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			keyword TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '[]',
+			tag_match_all BOOLEAN NOT NULL DEFAULT true,
+			player TEXT,
+			position TEXT,
+			position_key TEXT,
+			email_digest BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+			CHECK (LENGTH(name) >= 1 AND LENGTH(name) <= 50)
+		);
+		CREATE INDEX IF NOT EXISTS idx_saved_searches_account_id ON saved_searches(account_id)
+	`
+	_, err := db.Exec(query)
+	return err
+}
+
+const savedSearchColumns = `
+	id, account_id, name, keyword, tags, tag_match_all,
+	player, position, position_key, email_digest, created_at
+`
+
+func scanSavedSearch(row rowScanner) (*model.SavedSearch, error) {
+	search := &model.SavedSearch{}
+	var tags string
+	err := row.Scan(
+		&search.ID, &search.AccountID, &search.Name, &search.Keyword, &tags, &search.TagMatchAll,
+		&search.Player, &search.Position, &search.PositionKey, &search.EmailDigest, &search.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &search.Tags); err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+func InsertSavedSearch(search *model.SavedSearch) (string, error) {
+	search.ID = auxi.NewULID()
+	search.CreatedAt = time.Now()
+	if search.Tags == nil {
+		search.Tags = []string{}
+	}
+	tags, err := json.Marshal(search.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		INSERT INTO saved_searches (` + savedSearchColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = db.Exec(
+		query,
+		search.ID, search.AccountID, search.Name, search.Keyword, string(tags), search.TagMatchAll,
+		search.Player, search.Position, search.PositionKey, search.EmailDigest, search.CreatedAt,
+	)
+	return search.ID, err
+}
+
+func DeleteSavedSearch(searchID string, accountID string) error {
+	query := `DELETE FROM saved_searches WHERE id = ? AND account_id = ?`
+	res, err := db.Exec(query, searchID, accountID)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
+
+func CountSavedSearchesByAccountID(accountID string) (int, error) {
+	query := `SELECT COUNT(*) FROM saved_searches WHERE account_id = ?`
+	var count int
+	if err := db.QueryRow(query, accountID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func ListSavedSearchesByAccountID(accountID string) ([]*model.SavedSearch, error) {
+	query := `
+		SELECT ` + savedSearchColumns + ` FROM saved_searches
+		WHERE account_id = ?
+		ORDER BY created_at
+	`
+	return querySavedSearches(query, accountID)
+}
+
+// 他のアカウントの検索条件（公開された棋譜と照合する）
+func ListSavedSearchesExceptAccountID(accountID string) ([]*model.SavedSearch, error) {
+	query := `
+		SELECT ` + savedSearchColumns + ` FROM saved_searches
+		WHERE account_id <> ?
+		ORDER BY created_at
+	`
+	return querySavedSearches(query, accountID)
+}
+
+func ListSavedSearchesByIDs(ids []string) (map[string]*model.SavedSearch, error) {
+	searches := make(map[string]*model.SavedSearch, len(ids))
+	if len(ids) == 0 {
+		return searches, nil
+	}
+	query := `SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE id IN (` + placeholders(len(ids)) + `)`
+	list, err := querySavedSearches(query, stringArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, search := range list {
+		searches[search.ID] = search
+	}
+	return searches, nil
+}
+
+func querySavedSearches(query string, args ...any) ([]*model.SavedSearch, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []*model.SavedSearch{}
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, nil
+}
@@ -0,0 +1,133 @@
+// service/dao/tag_aliases.go
+// タグの別名（別名で入力・検索されたタグを登録済みのタグ名に置き換える）
+
+package dao
+
+import (
+	"github.com/jcytp/kifup-api/common/db"
+	"github.com/jcytp/kifup-api/service/model"
+)
+
+func DropTagAliasTable() error {
+	query := `DROP TABLE IF EXISTS tag_aliases`
+	_, err := db.Exec(query)
+	return err
+}
+
+func CreateTagAliasTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS tag_aliases (
+			alias_key TEXT PRIMARY KEY,
+			alias TEXT NOT NULL,
+			name TEXT NOT NULL,
+			CHECK (LENGTH(alias) >= 1 AND LENGTH(alias) <= 50),
+			CHECK (LENGTH(name) >= 1 AND LENGTH(name) <= 50)
+		);
+		CREATE INDEX IF NOT EXISTS idx_tag_aliases_name ON tag_aliases(name)
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	return insertDefaultTagAliases()
+}
+
+// 初期の別名を登録する（登録済み・削除済みの別名は変更しない）
+func insertDefaultTagAliases() error {
+	query := `SELECT COUNT(*) FROM tag_aliases`
+	var count int
+	if err := db.QueryRow(query).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	query = `
+		INSERT OR IGNORE INTO tag_aliases (alias_key, alias, name)
+		VALUES (?, ?, ?)
+	`
+	for alias, name := range model.DefaultTagAliases {
+		if _, err := db.Exec(query, model.TagKey(alias), alias, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ListTagAliases() ([]*model.TagAlias, error) {
+	query := `SELECT alias_key, alias, name FROM tag_aliases ORDER BY name, alias_key`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []*model.TagAlias{}
+	for rows.Next() {
+		alias := &model.TagAlias{}
+		if err := rows.Scan(&alias.AliasKey, &alias.Alias, &alias.Name); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, nil
+}
+
+// 別名のキーから置き換えるタグ名を取得する（キー→タグ名）
+func ListTagAliasNamesByKeys(keys []string) (map[string]string, error) {
+	names := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return names, nil
+	}
+	query := `SELECT alias_key, name FROM tag_aliases WHERE alias_key IN (` + placeholders(len(keys)) + `)`
+	rows, err := db.Query(query, stringArgs(keys)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, name string
+		if err := rows.Scan(&key, &name); err != nil {
+			return nil, err
+		}
+		names[key] = name
+	}
+	return names, nil
+}
+
+func UpsertTagAlias(tx *db.Tx, alias *model.TagAlias) error {
+	query := `
+		INSERT INTO tag_aliases (alias_key, alias, name)
+		VALUES (?, ?, ?)
+		ON CONFLICT (alias_key) DO UPDATE SET
+			alias = excluded.alias,
+			name = excluded.name
+	`
+	_, err := tx.Exec(query, alias.AliasKey, alias.Alias, alias.Name)
+	return err
+}
+
+// 統合したタグを置き換え先とする別名を、統合先のタグ名に付け替える
+// 統合先のタグ名自体が別名になっている場合は、その別名を削除する
+func RenameTagAliasTargets(tx *db.Tx, fromNames []string, toName string) error {
+	query := `DELETE FROM tag_aliases WHERE alias_key = ?`
+	if _, err := tx.Exec(query, model.TagKey(toName)); err != nil {
+		return err
+	}
+	if len(fromNames) == 0 {
+		return nil
+	}
+	query = `UPDATE tag_aliases SET name = ? WHERE name IN (` + placeholders(len(fromNames)) + `)`
+	_, err := tx.Exec(query, append([]any{toName}, stringArgs(fromNames)...)...)
+	return err
+}
+
+func DeleteTagAlias(tx *db.Tx, aliasKey string) error {
+	query := `DELETE FROM tag_aliases WHERE alias_key = ?`
+	res, err := tx.Exec(query, aliasKey)
+	if err != nil {
+		return err
+	}
+	return db.CheckAffectedRows(res, 1)
+}
@@ -16,6 +16,15 @@ type Account struct {
 	Introduction string    `db:"introduction"` // 自己紹介
 	CreatedAt    time.Time `db:"created_at"`
 	LastLoginAt  time.Time `db:"last_login_at"`
+	AllowFork    bool      `db:"allow_fork"` // 他のアカウントによる棋譜のフォークを許可するか
+}
+
+// table: `account_player_names`
+// 棋譜の先手・後手のうち自分とみなす名前（アカウントの統計で使う）
+type AccountPlayerName struct {
+	AccountID string `db:"account_id"`
+	NameKey   string `db:"name_key"` // 名前のキー（PlayerNameKey）
+	Name      string `db:"name"`
 }
 
 // ------------------------------------------------------------
@@ -27,6 +36,8 @@ type AccountResponse struct {
 	Introduction string    `json:"introduction"`
 	CreatedAt    time.Time `json:"created_at"`
 	LastLoginAt  time.Time `json:"last_login_at"`
+	AllowFork    bool      `json:"allow_fork"`
+	PlayerNames  *[]string `json:"player_names,omitempty"` // 自分とみなす対局者名（本人のみ）
 }
 
 func (t *Account) ToResponse() *AccountResponse {
@@ -37,6 +48,7 @@ func (t *Account) ToResponse() *AccountResponse {
 		Introduction: t.Introduction,
 		CreatedAt:    t.CreatedAt,
 		LastLoginAt:  t.LastLoginAt,
+		AllowFork:    t.AllowFork,
 	}
 	return resp
 }
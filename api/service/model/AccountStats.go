@@ -0,0 +1,188 @@
+// service/model/AccountStats.go
+// アカウントの統計（自分の棋譜の集計、非公開の棋譜を含む）
+
+package model
+
+// 月ごとの棋譜数
+type MonthCount struct {
+	Month string // YYYY-MM（対局日時、無い場合は作成日時）
+	Count int64
+}
+
+// 消費時間のある指し手
+type MoveTime struct {
+	KifuID          string
+	InitialPosition *SFEN // 開始局面（平手初期局面はNULL）
+	Number          int64
+	TimeSpentMs     int64
+}
+
+// ------------------------------------------------------------
+// 消費時間の傾向
+
+// 局面の段階（手数で分ける、Toが0は上限なし）
+var timeUsePhases = []struct {
+	Name string
+	From int64
+	To   int64
+}{
+	{"opening", 1, 40},
+	{"middlegame", 41, 80},
+	{"endgame", 81, 0},
+}
+
+// 消費時間の分布の区切り（ミリ秒、最後の区切り以上は1つにまとめる）
+var timeUseBuckets = []int64{10_000, 30_000, 60_000, 180_000, 600_000}
+
+// 消費時間の集計
+type TimeUseStats struct {
+	Moves        int64
+	TotalMs      int64
+	PhaseMoves   []int64
+	PhaseTotalMs []int64
+	BucketMoves  []int64
+	Longest      *MoveTime
+}
+
+func NewTimeUseStats() *TimeUseStats {
+	return &TimeUseStats{
+		PhaseMoves:   make([]int64, len(timeUsePhases)),
+		PhaseTotalMs: make([]int64, len(timeUsePhases)),
+		BucketMoves:  make([]int64, len(timeUseBuckets)+1),
+	}
+}
+
+func (t *TimeUseStats) Add(move *MoveTime) {
+	t.Moves++
+	t.TotalMs += move.TimeSpentMs
+	for i, phase := range timeUsePhases {
+		if move.Number >= phase.From && (phase.To == 0 || move.Number <= phase.To) {
+			t.PhaseMoves[i]++
+			t.PhaseTotalMs[i] += move.TimeSpentMs
+			break
+		}
+	}
+	bucket := len(timeUseBuckets)
+	for i, limit := range timeUseBuckets {
+		if move.TimeSpentMs < limit {
+			bucket = i
+			break
+		}
+	}
+	t.BucketMoves[bucket]++
+	if t.Longest == nil || move.TimeSpentMs > t.Longest.TimeSpentMs {
+		t.Longest = move
+	}
+}
+
+// ------------------------------------------------------------
+// レスポンス
+
+type MonthCountResponse struct {
+	Month string `json:"month"` // YYYY-MM
+	Count int64  `json:"count"`
+}
+
+func (t *MonthCount) ToResponse() *MonthCountResponse {
+	return &MonthCountResponse{
+		Month: t.Month,
+		Count: t.Count,
+	}
+}
+
+type TimeUsePhaseResponse struct {
+	Name      string   `json:"name"`       // opening / middlegame / endgame
+	FromMove  int64    `json:"from_move"`  // 手数の範囲の開始
+	ToMove    *int64   `json:"to_move"`    // 手数の範囲の終了（上限なしはNULL）
+	Moves     int64    `json:"moves"`      // 指し手の数
+	AverageMs *float64 `json:"average_ms"` // 平均消費時間（指し手が無い場合はNULL）
+}
+
+type TimeUseBucketResponse struct {
+	FromMs int64  `json:"from_ms"` // 消費時間の範囲の開始（この値を含む）
+	ToMs   *int64 `json:"to_ms"`   // 消費時間の範囲の終了（この値を含まない、上限なしはNULL）
+	Moves  int64  `json:"moves"`
+}
+
+type TimeUseLongestResponse struct {
+	KifuID      string `json:"kifu_id"`
+	Number      int64  `json:"number"`
+	TimeSpentMs int64  `json:"time_spent_ms"`
+}
+
+// 消費時間の傾向（自分の手番の指し手）
+type TimeUseResponse struct {
+	Moves        int64                    `json:"moves"`        // 消費時間のある指し手の数
+	TotalMs      int64                    `json:"total_ms"`     // 消費時間の合計
+	AverageMs    *float64                 `json:"average_ms"`   // 1手あたりの平均消費時間（指し手が無い場合はNULL）
+	Phases       []*TimeUsePhaseResponse  `json:"phases"`       // 序盤・中盤・終盤ごとの平均
+	Distribution []*TimeUseBucketResponse `json:"distribution"` // 消費時間の分布
+	Longest      *TimeUseLongestResponse  `json:"longest"`      // 最も長考した指し手（無い場合はNULL）
+}
+
+func averageMs(totalMs int64, moves int64) *float64 {
+	if moves == 0 {
+		return nil
+	}
+	average := float64(totalMs) / float64(moves)
+	return &average
+}
+
+func (t *TimeUseStats) ToResponse() *TimeUseResponse {
+	resp := &TimeUseResponse{
+		Moves:        t.Moves,
+		TotalMs:      t.TotalMs,
+		AverageMs:    averageMs(t.TotalMs, t.Moves),
+		Phases:       make([]*TimeUsePhaseResponse, 0, len(timeUsePhases)),
+		Distribution: make([]*TimeUseBucketResponse, 0, len(t.BucketMoves)),
+	}
+	for i, phase := range timeUsePhases {
+		phaseResp := &TimeUsePhaseResponse{
+			Name:      phase.Name,
+			FromMove:  phase.From,
+			Moves:     t.PhaseMoves[i],
+			AverageMs: averageMs(t.PhaseTotalMs[i], t.PhaseMoves[i]),
+		}
+		if phase.To != 0 {
+			to := phase.To
+			phaseResp.ToMove = &to
+		}
+		resp.Phases = append(resp.Phases, phaseResp)
+	}
+	from := int64(0)
+	for i, moves := range t.BucketMoves {
+		bucket := &TimeUseBucketResponse{FromMs: from, Moves: moves}
+		if i < len(timeUseBuckets) {
+			to := timeUseBuckets[i]
+			bucket.ToMs = &to
+			from = to
+		}
+		resp.Distribution = append(resp.Distribution, bucket)
+	}
+	if t.Longest != nil {
+		resp.Longest = &TimeUseLongestResponse{
+			KifuID:      t.Longest.KifuID,
+			Number:      t.Longest.Number,
+			TimeSpentMs: t.Longest.TimeSpentMs,
+		}
+	}
+	return resp
+}
+
+// 対局者名として一致した対局の成績
+type AccountResultsResponse struct {
+	PlayerNames []string              `json:"player_names"` // 自分とみなす対局者名
+	Total       *PlayerRecordResponse `json:"total"`
+	Black       *PlayerRecordResponse `json:"black"`
+	White       *PlayerRecordResponse `json:"white"`
+}
+
+type AccountStatsResponse struct {
+	KifuCount     int64                   `json:"kifu_count"`     // 棋譜の数（非公開を含む）
+	Months        []*MonthCountResponse   `json:"months"`         // 月ごとの棋譜数（古い順）
+	Results       *AccountResultsResponse `json:"results"`        // 対局者名として一致した対局の成績
+	Openings      []*TagCountResponse     `json:"openings"`       // よく指す戦型（タグの多い順）
+	TimeUse       *TimeUseResponse        `json:"time_use"`       // 消費時間の傾向（対局者名が一致した側の指し手）
+	MostLiked     []*KifuSummaryResponse  `json:"most_liked"`     // いいねの多い棋譜
+	MostCommented []*KifuSummaryResponse  `json:"most_commented"` // コメントの多い棋譜
+}
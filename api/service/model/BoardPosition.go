@@ -36,6 +36,26 @@ const (
 	SfenJumaiOchi         SFEN = "4k4/9/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1"
 )
 
+// 手合割の名前と開始局面
+var HandicapNameToSFEN = map[string]SFEN{
+	"平手":    SfenHirate,
+	"香落ち":   SfenKyoOchi,
+	"右香落ち":  SfenMigiKyoOchi,
+	"角落ち":   SfenKakuOchi,
+	"飛車落ち":  SfenHishaOchi,
+	"飛香落ち":  SfenHiKyoOchi,
+	"二枚落ち":  SfenNimaiOchi,
+	"三枚落ち":  SfenSanmaiOchi,
+	"四枚落ち":  SfenYonmaiOchi,
+	"五枚落ち":  SfenGomaiOchi,
+	"左五枚落ち": SfenHidariGomaiOchi,
+	"六枚落ち":  SfenRokumaiOchi,
+	"左七枚落ち": SfenHidariNanamaiOchi,
+	"右七枚落ち": SfenMigiNanamaiOchi,
+	"八枚落ち":  SfenHachimaiOchi,
+	"十枚落ち":  SfenJumaiOchi,
+}
+
 func (sfen SFEN) PSFEN() *SFEN {
 	return &sfen
 }
@@ -0,0 +1,242 @@
+// service/model/BoardPositionBOD.go
+// 局面図（BOD形式）とBoardPositionデータの相互変換
+
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BOD形式の盤面の駒（1文字表記）
+var pieceTypeNameBOD = map[PieceType]string{
+	PIECE_FU: "歩",
+	PIECE_KY: "香",
+	PIECE_KE: "桂",
+	PIECE_GI: "銀",
+	PIECE_KI: "金",
+	PIECE_KA: "角",
+	PIECE_HI: "飛",
+	PIECE_OU: "玉",
+	PIECE_TO: "と",
+	PIECE_NY: "杏",
+	PIECE_NK: "圭",
+	PIECE_NG: "全",
+	PIECE_UM: "馬",
+	PIECE_RY: "龍",
+}
+
+var pieceTypeFromNameBOD = map[string]PieceType{
+	"歩": PIECE_FU,
+	"香": PIECE_KY,
+	"桂": PIECE_KE,
+	"銀": PIECE_GI,
+	"金": PIECE_KI,
+	"角": PIECE_KA,
+	"飛": PIECE_HI,
+	"玉": PIECE_OU,
+	"王": PIECE_OU,
+	"と": PIECE_TO,
+	"杏": PIECE_NY,
+	"圭": PIECE_NK,
+	"全": PIECE_NG,
+	"馬": PIECE_UM,
+	"龍": PIECE_RY,
+	"竜": PIECE_RY,
+}
+
+// 持ち駒の表記順
+var handOrderBOD = []PieceType{PIECE_HI, PIECE_KA, PIECE_KI, PIECE_GI, PIECE_KE, PIECE_KY, PIECE_FU}
+
+const kanjiNumberString = "一二三四五六七八九"
+
+// BOD -> BoardPosition
+func NewBoardPositionFromBOD(bod string) (*BoardPosition, error) {
+	bp, err := NewBoardPosition(SfenAllInBox.PSFEN())
+	if err != nil {
+		return nil, err
+	}
+
+	row := 0
+	for _, line := range strings.Split(bod, "\n") {
+		line = strings.TrimRight(line, "\r 　")
+		switch {
+		case strings.HasPrefix(line, "|"): // 盤面
+			if row >= 9 {
+				return nil, fmt.Errorf("too many board rows in bod")
+			}
+			if err := parseBoardRowBOD(line, row, bp); err != nil {
+				return nil, err
+			}
+			row++
+		case strings.HasPrefix(line, "先手の持駒"), strings.HasPrefix(line, "下手の持駒"):
+			if err := parseHandsBOD(line, bp.BlackHands); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "後手の持駒"), strings.HasPrefix(line, "上手の持駒"):
+			if err := parseHandsBOD(line, bp.WhiteHands); err != nil {
+				return nil, err
+			}
+		case line == "後手番", line == "上手番":
+			bp.IsBlackTurn = false
+		case line == "先手番", line == "下手番":
+			bp.IsBlackTurn = true
+		}
+	}
+	if row != 9 {
+		return nil, fmt.Errorf("invalid number of board rows in bod: expected 9, got %d", row)
+	}
+	return bp, nil
+}
+
+// "|v香v桂 ・ ・ ・ ・ ・ 桂 香|一" の形式の1行をパースする
+func parseBoardRowBOD(line string, row int, bp *BoardPosition) error {
+	runes := []rune(strings.TrimPrefix(line, "|"))
+	col := 0
+	for i := 0; i+1 < len(runes) && runes[i] != '|'; i += 2 {
+		if col > 8 {
+			return fmt.Errorf("row %d exceeds board size in bod", row+1)
+		}
+		prefix, name := runes[i], string(runes[i+1])
+		if name != "・" {
+			piece, ok := pieceTypeFromNameBOD[name]
+			if !ok {
+				return fmt.Errorf("invalid piece '%s' at row %d col %d in bod", name, row+1, col+1)
+			}
+			if prefix == 'v' || prefix == 'V' {
+				bp.WhiteBoard[row][col] = piece
+			} else {
+				bp.BlackBoard[row][col] = piece
+			}
+		}
+		col++
+	}
+	if col != 9 {
+		return fmt.Errorf("row %d has incorrect length in bod: expected 9 positions, got %d", row+1, col)
+	}
+	return nil
+}
+
+// "先手の持駒：飛　角　歩三" の形式の1行をパースする
+func parseHandsBOD(line string, hands map[PieceType]int32) error {
+	parts := strings.SplitN(strings.Replace(line, ":", "：", 1), "：", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid hands line in bod: %s", line)
+	}
+	value := strings.TrimSpace(strings.ReplaceAll(parts[1], "　", " "))
+	if value == "" || value == "なし" {
+		return nil
+	}
+	for _, item := range strings.Fields(value) {
+		runes := []rune(item)
+		piece, ok := pieceTypeFromNameBOD[string(runes[0])]
+		if !ok || piece&PIECE_PROMOTE != 0 || piece == PIECE_OU {
+			return fmt.Errorf("invalid piece in hands of bod: %s", item)
+		}
+		count := int32(1)
+		if len(runes) > 1 {
+			n, err := parseKanjiNumber(string(runes[1:]))
+			if err != nil {
+				return err
+			}
+			count = n
+		}
+		hands[piece] += count
+	}
+	return nil
+}
+
+// 一〜十八の漢数字をパースする
+func parseKanjiNumber(s string) (int32, error) {
+	runes := []rune(s)
+	result := int32(0)
+	if runes[0] == '十' {
+		result = 10
+		runes = runes[1:]
+	}
+	if len(runes) == 1 {
+		i := strings.IndexRune(kanjiNumberString, runes[0])
+		if i < 0 {
+			return 0, fmt.Errorf("invalid kanji number: %s", s)
+		}
+		result += int32(i/len("一")) + 1
+	} else if len(runes) > 1 {
+		return 0, fmt.Errorf("invalid kanji number: %s", s)
+	}
+	return result, nil
+}
+
+func formatKanjiNumber(n int32) string {
+	result := ""
+	if n >= 10 {
+		result = "十"
+		n -= 10
+	}
+	if n > 0 {
+		result += string([]rune(kanjiNumberString)[n-1])
+	}
+	return result
+}
+
+// BoardPosition -> BOD
+func (bp *BoardPosition) ToBOD() (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString(formatHandsBOD("後手の持駒", bp.WhiteHands))
+	sb.WriteString("  ９ ８ ７ ６ ５ ４ ３ ２ １\n")
+	sb.WriteString("+---------------------------+\n")
+	rankRunes := []rune(kanjiNumberString)
+	for i := 0; i < 9; i++ {
+		sb.WriteString("|")
+		for j := 0; j < 9; j++ {
+			blackPiece := bp.BlackBoard[i][j]
+			whitePiece := bp.WhiteBoard[i][j]
+			switch {
+			case blackPiece != PIECE_VACANCY && whitePiece != PIECE_VACANCY:
+				return "", fmt.Errorf("both black and white piece exists at row %d col %d", i, j)
+			case blackPiece != PIECE_VACANCY:
+				name, ok := pieceTypeNameBOD[blackPiece]
+				if !ok {
+					return "", fmt.Errorf("invalid black piece at row %d col %d", i, j)
+				}
+				sb.WriteString(" " + name)
+			case whitePiece != PIECE_VACANCY:
+				name, ok := pieceTypeNameBOD[whitePiece]
+				if !ok {
+					return "", fmt.Errorf("invalid white piece at row %d col %d", i, j)
+				}
+				sb.WriteString("v" + name)
+			default:
+				sb.WriteString(" ・")
+			}
+		}
+		sb.WriteString("|")
+		sb.WriteRune(rankRunes[i])
+		sb.WriteString("\n")
+	}
+	sb.WriteString("+---------------------------+\n")
+	sb.WriteString(formatHandsBOD("先手の持駒", bp.BlackHands))
+	if !bp.IsBlackTurn {
+		sb.WriteString("後手番\n")
+	}
+	return sb.String(), nil
+}
+
+func formatHandsBOD(label string, hands map[PieceType]int32) string {
+	items := []string{}
+	for _, piece := range handOrderBOD {
+		cnt := hands[piece]
+		if cnt <= 0 {
+			continue
+		}
+		item := pieceTypeNameBOD[piece]
+		if cnt > 1 {
+			item += formatKanjiNumber(cnt)
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return label + "：なし\n"
+	}
+	return label + "：" + strings.Join(items, "　") + "　\n"
+}
@@ -0,0 +1,73 @@
+// service/model/BoardPositionBOD_test.go
+
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoardPositionBODRoundTrip(t *testing.T) {
+	for _, sfen := range []SFEN{
+		SfenHirate,
+		SfenHishaOchi,
+		"ln1g3nl/1r1sk1g2/p1pppp1pp/6p2/1p5P1/2P6/PP1PPPP1P/1SK4R1/LN1G1GSNL w Bb2s 22",
+		"4k4/9/4P4/9/9/9/9/9/4K4 b G2r2b3g4s4n4l17p 1",
+	} {
+		position, err := NewBoardPosition(sfen.PSFEN())
+		if err != nil {
+			t.Fatalf("NewBoardPosition(%s) error = %v", sfen, err)
+		}
+		bod, err := position.ToBOD()
+		if err != nil {
+			t.Fatalf("ToBOD(%s) error = %v", sfen, err)
+		}
+		parsed, err := NewBoardPositionFromBOD(bod)
+		if err != nil {
+			t.Fatalf("NewBoardPositionFromBOD(%s) error = %v\n%s", sfen, err, bod)
+		}
+		want, _ := position.ToSFEN(1)
+		got, err := parsed.ToSFEN(1)
+		if err != nil {
+			t.Fatalf("ToSFEN(%s) error = %v", sfen, err)
+		}
+		if got != want {
+			t.Errorf("round trip = %s, want %s\n%s", got, want, bod)
+		}
+	}
+}
+
+func TestBoardPositionToBOD(t *testing.T) {
+	position, err := NewBoardPosition(SfenHirate.PSFEN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bod, err := position.ToBOD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"後手の持駒：なし",
+		"|v香v桂v銀v金v玉v金v銀v桂v香|一",
+		"| ・ 角 ・ ・ ・ ・ ・ 飛 ・|八",
+		"先手の持駒：なし",
+	} {
+		if !strings.Contains(bod, line+"\n") {
+			t.Errorf("ToBOD() does not contain %q\n%s", line, bod)
+		}
+	}
+	if strings.Contains(bod, "後手番") {
+		t.Errorf("ToBOD() of black turn contains 後手番\n%s", bod)
+	}
+}
+
+func TestNewBoardPositionFromBODErrors(t *testing.T) {
+	for _, bod := range []string{
+		"",
+		"後手の持駒：なし\n|v香v桂|一\n",
+	} {
+		if _, err := NewBoardPositionFromBOD(bod); err == nil {
+			t.Errorf("NewBoardPositionFromBOD(%q) error = nil, want error", bod)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// service/model/BoardPositionRule.go
+// 駒の利き・王手・合法手・詰みの判定
+
+package model
+
+// 先手から見た方向（行, 列）。後手は行を反転する
+type direction struct {
+	row int
+	col int
+}
+
+var (
+	directionsKI = []direction{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, 0}}
+	directionsGI = []direction{{-1, -1}, {-1, 0}, {-1, 1}, {1, -1}, {1, 1}}
+	directionsKE = []direction{{-2, -1}, {-2, 1}}
+	directionsOU = []direction{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+	directionsHI = []direction{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	directionsKA = []direction{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+)
+
+// 駒の1マスずつの移動方向と、飛び駒の移動方向
+func pieceDirections(piece PieceType) (steps []direction, slides []direction) {
+	switch piece {
+	case PIECE_FU:
+		return []direction{{-1, 0}}, nil
+	case PIECE_KY:
+		return nil, []direction{{-1, 0}}
+	case PIECE_KE:
+		return directionsKE, nil
+	case PIECE_GI:
+		return directionsGI, nil
+	case PIECE_KI, PIECE_TO, PIECE_NY, PIECE_NK, PIECE_NG:
+		return directionsKI, nil
+	case PIECE_KA:
+		return nil, directionsKA
+	case PIECE_HI:
+		return nil, directionsHI
+	case PIECE_UM:
+		return directionsHI, directionsKA
+	case PIECE_RY:
+		return directionsKA, directionsHI
+	case PIECE_OU:
+		return directionsOU, nil
+	}
+	return nil, nil
+}
+
+func (bp *BoardPosition) boards(black bool) (own *[9][9]PieceType, opponent *[9][9]PieceType) {
+	if black {
+		return &bp.BlackBoard, &bp.WhiteBoard
+	}
+	return &bp.WhiteBoard, &bp.BlackBoard
+}
+
+// (row, col)の駒が移動できるマス（自駒のあるマスを除く、王手放置などは考慮しない）
+func (bp *BoardPosition) pieceTargets(black bool, row int, col int) []PiecePlace {
+	own, opponent := bp.boards(black)
+	piece := own[row][col]
+	sign := 1
+	if !black {
+		sign = -1
+	}
+
+	targets := []PiecePlace{}
+	steps, slides := pieceDirections(piece)
+	for _, d := range steps {
+		r, c := row+d.row*sign, col+d.col
+		if r < 0 || r >= 9 || c < 0 || c >= 9 || own[r][c] != PIECE_VACANCY {
+			continue
+		}
+		targets = append(targets, PiecePlace(r<<4|c))
+	}
+	for _, d := range slides {
+		for r, c := row+d.row*sign, col+d.col; r >= 0 && r < 9 && c >= 0 && c < 9; r, c = r+d.row*sign, c+d.col {
+			if own[r][c] != PIECE_VACANCY {
+				break
+			}
+			targets = append(targets, PiecePlace(r<<4|c))
+			if opponent[r][c] != PIECE_VACANCY {
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// 玉の位置（無ければfalse）
+func (bp *BoardPosition) kingPlace(black bool) (PiecePlace, bool) {
+	own, _ := bp.boards(black)
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			if own[row][col] == PIECE_OU {
+				return PiecePlace(row<<4 | col), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// 指定した手番側の駒がplaceに利いているか
+func (bp *BoardPosition) isAttacked(place PiecePlace, byBlack bool) bool {
+	own, _ := bp.boards(byBlack)
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			if own[row][col] == PIECE_VACANCY {
+				continue
+			}
+			for _, target := range bp.pieceTargets(byBlack, row, col) {
+				if target == place {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// 手番側の玉に王手がかかっているか
+func (bp *BoardPosition) IsCheck() bool {
+	king, ok := bp.kingPlace(bp.IsBlackTurn)
+	if !ok {
+		return false
+	}
+	return bp.isAttacked(king, !bp.IsBlackTurn)
+}
+
+// 行き所のない駒にならないか（rowは先手から見た行）
+func canStayAt(piece PieceType, row int) bool {
+	switch piece {
+	case PIECE_FU, PIECE_KY:
+		return row >= 1
+	case PIECE_KE:
+		return row >= 2
+	}
+	return true
+}
+
+// 手番側の合法手（打ち歩詰めは考慮しない）
+func (bp *BoardPosition) LegalMoves() []*KifuMove {
+	black := bp.IsBlackTurn
+	own, opponent := bp.boards(black)
+	relativeRow := func(row int) int { // 先手から見た行
+		if black {
+			return row
+		}
+		return 8 - row
+	}
+
+	candidates := []*KifuMove{}
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			piece := own[row][col]
+			if piece == PIECE_VACANCY {
+				continue
+			}
+			from := PiecePlace(row<<4 | col)
+			for _, to := range bp.pieceTargets(black, row, col) {
+				toRow, _ := to.RowCol()
+				canPromote := piece&PIECE_PROMOTE == 0 && piece != PIECE_KI && piece != PIECE_OU &&
+					(relativeRow(row) < 3 || relativeRow(toRow) < 3)
+				if canPromote {
+					candidates = append(candidates, &KifuMove{Piece: piece | PIECE_PROMOTE, FromPlace: from, ToPlace: to})
+				}
+				if canStayAt(piece, relativeRow(toRow)) {
+					candidates = append(candidates, &KifuMove{Piece: piece, FromPlace: from, ToPlace: to})
+				}
+			}
+		}
+	}
+
+	// 持ち駒を打つ手
+	hands := bp.BlackHands
+	if !black {
+		hands = bp.WhiteHands
+	}
+	for piece, cnt := range hands {
+		if cnt <= 0 {
+			continue
+		}
+		for row := 0; row < 9; row++ {
+			if !canStayAt(piece, relativeRow(row)) {
+				continue
+			}
+			for col := 0; col < 9; col++ {
+				if own[row][col] != PIECE_VACANCY || opponent[row][col] != PIECE_VACANCY {
+					continue
+				}
+				if piece == PIECE_FU && hasPawnInColumn(own, col) { // 二歩
+					continue
+				}
+				candidates = append(candidates, &KifuMove{Piece: piece, FromPlace: PIECE_PLACE_IN_HAND, ToPlace: PiecePlace(row<<4 | col)})
+			}
+		}
+	}
+
+	// 自玉に王手がかかる手を除く
+	moves := []*KifuMove{}
+	for _, move := range candidates {
+		next := bp.Copy()
+		if err := next.Move(move); err != nil {
+			continue
+		}
+		if king, ok := next.kingPlace(black); ok && next.isAttacked(king, !black) {
+			continue
+		}
+		moves = append(moves, move)
+	}
+	return moves
+}
+
+func hasPawnInColumn(board *[9][9]PieceType, col int) bool {
+	for row := 0; row < 9; row++ {
+		if board[row][col] == PIECE_FU {
+			return true
+		}
+	}
+	return false
+}
+
+// 手番側が詰んでいるか（王手がかかっていて合法手が無い）
+func (bp *BoardPosition) IsCheckmate() bool {
+	return bp.IsCheck() && len(bp.LegalMoves()) == 0
+}
+
+// 同一局面の判定用のキー（盤面・持ち駒・手番）
+func (bp *BoardPosition) Key() (string, error) {
+	sfen, err := bp.ToSFEN(1)
+	if err != nil {
+		return "", err
+	}
+	return string(sfen), nil
+}
@@ -0,0 +1,90 @@
+// service/model/BoardPositionRule_test.go
+
+package model
+
+import "testing"
+
+func newTestPosition(t *testing.T, sfen SFEN) *BoardPosition {
+	t.Helper()
+	position, err := NewBoardPosition(sfen.PSFEN())
+	if err != nil {
+		t.Fatalf("NewBoardPosition(%s) error = %v", sfen, err)
+	}
+	return position
+}
+
+func TestLegalMovesHirate(t *testing.T) {
+	if got := len(newTestPosition(t, SfenHirate).LegalMoves()); got != 30 {
+		t.Errorf("LegalMoves() = %d moves, want 30", got)
+	}
+}
+
+func TestLegalMovesNifu(t *testing.T) {
+	// 5筋に歩がある場合、5筋には歩を打てない
+	position := newTestPosition(t, "4k4/9/9/9/9/9/4P4/9/4K4 b P 1")
+	for _, move := range position.LegalMoves() {
+		_, col := move.ToPlace.RowCol()
+		if move.FromPlace == PIECE_PLACE_IN_HAND && col == 4 {
+			t.Errorf("LegalMoves() contains nifu drop: %+v", *move)
+		}
+	}
+}
+
+func TestLegalMovesPin(t *testing.T) {
+	// 飛車に釘付けにされた金は横に動けない
+	position := newTestPosition(t, "4r4/9/9/9/9/9/9/4G4/4K4 b - 1")
+	for _, move := range position.LegalMoves() {
+		if move.Piece == PIECE_KI {
+			_, col := move.ToPlace.RowCol()
+			if col != 4 {
+				t.Errorf("LegalMoves() contains pinned gold move: %+v", *move)
+			}
+		}
+	}
+}
+
+func TestIsCheckmate(t *testing.T) {
+	tests := []struct {
+		name      string
+		sfen      SFEN
+		check     bool
+		checkmate bool
+	}{
+		{name: "hirate", sfen: SfenHirate, check: false, checkmate: false},
+		{name: "head mate", sfen: "4k4/4G4/4P4/9/9/9/9/9/4K4 w - 1", check: true, checkmate: true},
+		{name: "king takes gold", sfen: "4k4/4G4/9/9/9/9/9/9/4K4 w - 1", check: true, checkmate: false},
+		{name: "no check", sfen: "4k4/9/4G4/9/9/9/9/9/4K4 w - 1", check: false, checkmate: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := newTestPosition(t, tt.sfen)
+			if got := position.IsCheck(); got != tt.check {
+				t.Errorf("IsCheck() = %v, want %v", got, tt.check)
+			}
+			if got := position.IsCheckmate(); got != tt.checkmate {
+				t.Errorf("IsCheckmate() = %v, want %v", got, tt.checkmate)
+			}
+		})
+	}
+}
+
+func TestKeyIgnoresMoveCount(t *testing.T) {
+	a, err := newTestPosition(t, "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 1").Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newTestPosition(t, "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL b - 5").Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newTestPosition(t, "lnsgkgsnl/1r5b1/ppppppppp/9/9/9/PPPPPPPPP/1B5R1/LNSGKGSNL w - 1").Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("Key() differs by move count: %s, %s", a, b)
+	}
+	if a == c {
+		t.Errorf("Key() is the same for different turns: %s", a)
+	}
+}
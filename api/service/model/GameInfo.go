@@ -29,6 +29,21 @@ func (t TimeRuleString) ToGameInfo() GameInfo {
 	return gameInfo
 }
 
+// 表示用の表記（持ち時間600秒・秒読み30秒など）
+func (t TimeRuleString) Text() string {
+	gameInfo := t.ToGameInfo()
+	texts := []string{}
+	for _, key := range []string{"持ち時間", "秒読み", "秒加算"} {
+		if value, ok := gameInfo[key]; ok {
+			texts = append(texts, key+value)
+		}
+	}
+	if len(texts) == 0 {
+		return string(t)
+	}
+	return strings.Join(texts, "・")
+}
+
 // ------------------------------------------------------------
 type GameInfo map[string]string
 
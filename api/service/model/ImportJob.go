@@ -0,0 +1,102 @@
+// service/model/ImportJob.go
+// 棋譜の一括取り込みジョブのデータモデルを定義
+
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+type ImportJobStatus string
+
+const (
+	IMPORT_JOB_PENDING ImportJobStatus = "pending" // 受付済み
+	IMPORT_JOB_RUNNING ImportJobStatus = "running" // 取り込み中
+	IMPORT_JOB_DONE    ImportJobStatus = "done"    // 完了（個別の失敗を含む）
+	IMPORT_JOB_FAILED  ImportJobStatus = "failed"  // ジョブ全体の失敗
+)
+
+// table: `import_jobs`
+type ImportJob struct {
+	ID             string          `db:"id"`
+	AccountID      string          `db:"account_id"`
+	Status         ImportJobStatus `db:"status"`
+	TotalCount     int64           `db:"total_count"`     // 取り込み対象の棋譜数
+	ProcessedCount int64           `db:"processed_count"` // 処理済みの棋譜数
+	SucceededCount int64           `db:"succeeded_count"` // 取り込みに成功した棋譜数
+	FailedCount    int64           `db:"failed_count"`    // 取り込みに失敗した棋譜数
+	SkippedCount   int64           `db:"skipped_count"`   // 同一棋譜があるためスキップした棋譜数
+	CreatedAt      time.Time       `db:"created_at"`
+	UpdatedAt      time.Time       `db:"updated_at"`
+	Message        *string         `db:"message"` // ジョブ全体が失敗した理由
+}
+
+// table: `import_job_entries`
+type ImportJobEntry struct {
+	JobID       string  `db:"job_id"`
+	Seq         int64   `db:"seq"`          // ジョブ内の通し番号
+	Name        string  `db:"name"`         // ファイル名（複数棋譜のCSAは"#番号"を付与）
+	KifuID      *string `db:"kifu_id"`      // 作成した棋譜ID（失敗・スキップ時はNULL）
+	Message     *string `db:"message"`      // 失敗時のエラーメッセージ
+	DuplicateOf *string `db:"duplicate_of"` // 既に登録されている同一の棋譜ID
+}
+
+// ------------------------------------------------------------
+
+type ImportJobResponse struct {
+	ID             string                    `json:"id"`
+	Status         ImportJobStatus           `json:"status"`
+	TotalCount     int64                     `json:"total_count"`
+	ProcessedCount int64                     `json:"processed_count"`
+	SucceededCount int64                     `json:"succeeded_count"`
+	FailedCount    int64                     `json:"failed_count"`
+	SkippedCount   int64                     `json:"skipped_count"`
+	CreatedAt      time.Time                 `json:"created_at"`
+	UpdatedAt      time.Time                 `json:"updated_at"`
+	Message        *string                   `json:"message,omitempty"` // ジョブ全体が失敗した理由
+	Entries        []*ImportJobEntryResponse `json:"entries"`
+}
+
+type ImportJobEntryResponse struct {
+	Name         string  `json:"name"`
+	Success      bool    `json:"success"`
+	Skipped      bool    `json:"skipped"` // 同一棋譜があるため作成しなかった
+	KifuID       *string `json:"kifu_id,omitempty"`
+	Message      *string `json:"message,omitempty"`
+	DuplicateOf  *string `json:"duplicate_of,omitempty"`  // 既に登録されている同一の棋譜ID
+	DuplicateURL *string `json:"duplicate_url,omitempty"` // 同一の棋譜の閲覧ページへのリンク
+}
+
+func (t *ImportJob) ToResponse(entries []*ImportJobEntry) *ImportJobResponse {
+	entryResponses := make([]*ImportJobEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		entryResponse := &ImportJobEntryResponse{
+			Name:        entry.Name,
+			Success:     entry.KifuID != nil,
+			Skipped:     entry.KifuID == nil && entry.Message == nil && entry.DuplicateOf != nil,
+			KifuID:      entry.KifuID,
+			Message:     entry.Message,
+			DuplicateOf: entry.DuplicateOf,
+		}
+		if entry.DuplicateOf != nil {
+			url := fmt.Sprintf("/kifu/view?id=%s", *entry.DuplicateOf)
+			entryResponse.DuplicateURL = &url
+		}
+		entryResponses = append(entryResponses, entryResponse)
+	}
+	resp := &ImportJobResponse{
+		ID:             t.ID,
+		Status:         t.Status,
+		TotalCount:     t.TotalCount,
+		ProcessedCount: t.ProcessedCount,
+		SucceededCount: t.SucceededCount,
+		FailedCount:    t.FailedCount,
+		SkippedCount:   t.SkippedCount,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+		Message:        t.Message,
+		Entries:        entryResponses,
+	}
+	return resp
+}
@@ -4,6 +4,7 @@
 package model
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -22,6 +23,14 @@ type Kifu struct {
 	UpdatedAt       time.Time       `db:"updated_at"`
 	LikeCount       int64           `db:"like_count"`    // いいね数
 	CommentCount    int64           `db:"comment_count"` // 感想コメント数
+	Fingerprint     *string         `db:"fingerprint"`   // 同一棋譜の判定用（初期局面＋メインラインのハッシュ）
+	Version         int64           `db:"version"`       // 更新の競合検出用の版（更新のたびに1増える）
+	ForkedFrom      *string         `db:"forked_from"`   // フォーク元の棋譜ID（フォークでなければNULL）
+}
+
+// HTTPのETagヘッダー用の表記
+func (t *Kifu) ETag() string {
+	return fmt.Sprintf(`"%d"`, t.Version)
 }
 
 // table: `kifu_options`
@@ -46,6 +55,7 @@ type KifuBranch struct {
 	EndingNumber  *int64      `db:"ending_number"`  // 最終手の次の番号
 	EndingType    *EndingType `db:"ending_type"`    // 終局の種類（投了／千日手／中断など）
 	EndingComment *string     `db:"ending_comment"` // 終局時のコメント
+	SortOrder     int64       `db:"sort_order"`     // 同じ手から分岐するブランチ間の順序（小さいほど先）
 }
 
 // table: `kifu_moves`
@@ -57,6 +67,8 @@ type KifuMove struct {
 	ToPlace     PiecePlace `db:"to_place"`      // 動いた先の場所
 	Comment     *string    `db:"comment"`       // コメント
 	TimeSpentMs *int64     `db:"time_spent_ms"` // 消費時間（ミリ秒）
+
+	Annotations []*KifuAnnotation // 注釈（kifu_annotationsテーブルから取得）
 }
 
 type KifuBranchWithMoves struct {
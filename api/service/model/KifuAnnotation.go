@@ -0,0 +1,282 @@
+// service/model/KifuAnnotation.go
+// 指し手に付ける図示の注釈（矢印・マスの強調・評価記号）
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type KifuAnnotationType string
+
+const (
+	KIFU_ANNOTATION_MARK      KifuAnnotationType = "mark"      // 評価記号
+	KIFU_ANNOTATION_ARROW     KifuAnnotationType = "arrow"     // 矢印
+	KIFU_ANNOTATION_HIGHLIGHT KifuAnnotationType = "highlight" // マスの強調
+)
+
+// 評価記号
+var KifuAnnotationMarks = map[string]bool{
+	"!!": true, // 好手
+	"!":  true, // 有力
+	"!?": true, // 面白い手
+	"?!": true, // 疑問
+	"?":  true, // 悪手
+	"??": true, // 大悪手
+}
+
+// 矢印・強調の色
+var KifuAnnotationColors = map[string]bool{
+	"red":    true,
+	"blue":   true,
+	"green":  true,
+	"yellow": true,
+}
+
+const kifuAnnotationMaxCount = 30 // 1手あたりの注釈の最大数
+
+// table: `kifu_annotations`
+type KifuAnnotation struct {
+	ID        string             `db:"id"`
+	BranchID  string             `db:"branch_id"`
+	Number    int64              `db:"number"`
+	Type      KifuAnnotationType `db:"type"`
+	FromPlace *PiecePlace        `db:"from_place"` // 矢印の始点（矢印以外はNULL）
+	ToPlace   *PiecePlace        `db:"to_place"`   // 矢印の終点、強調するマス（評価記号はNULL）
+	Mark      *string            `db:"mark"`       // 評価記号（評価記号以外はNULL）
+	Color     *string            `db:"color"`      // 矢印・強調の色（NULLは既定の色）
+}
+
+func isBoardPlace(place *PiecePlace) bool {
+	if place == nil {
+		return false
+	}
+	row, col := place.RowCol()
+	return row < 9 && col < 9
+}
+
+func (t *KifuAnnotation) Validate() error {
+	if t.Color != nil && !KifuAnnotationColors[*t.Color] {
+		return fmt.Errorf("invalid annotation color: %s", *t.Color)
+	}
+	switch t.Type {
+	case KIFU_ANNOTATION_MARK:
+		if t.Mark == nil || !KifuAnnotationMarks[*t.Mark] {
+			return fmt.Errorf("invalid annotation mark at %d", t.Number)
+		}
+	case KIFU_ANNOTATION_ARROW:
+		if !isBoardPlace(t.FromPlace) || !isBoardPlace(t.ToPlace) || *t.FromPlace == *t.ToPlace {
+			return fmt.Errorf("invalid annotation arrow at %d", t.Number)
+		}
+	case KIFU_ANNOTATION_HIGHLIGHT:
+		if !isBoardPlace(t.ToPlace) {
+			return fmt.Errorf("invalid annotation highlight at %d", t.Number)
+		}
+	default:
+		return fmt.Errorf("invalid annotation type: %s", t.Type)
+	}
+	return nil
+}
+
+// 1手分の注釈を検証する（評価記号は1つまで）
+func ValidateKifuAnnotations(annotations []*KifuAnnotation) error {
+	if len(annotations) > kifuAnnotationMaxCount {
+		return fmt.Errorf("too many annotations: %d", len(annotations))
+	}
+	marks := 0
+	for _, annotation := range annotations {
+		if err := annotation.Validate(); err != nil {
+			return err
+		}
+		if annotation.Type == KIFU_ANNOTATION_MARK {
+			marks++
+		}
+	}
+	if marks > 1 {
+		return fmt.Errorf("multiple annotation marks")
+	}
+	return nil
+}
+
+// ------------------------------------------------------------
+// レスポンス
+
+type KifuAnnotationResponse struct {
+	Type      KifuAnnotationType `json:"type"`
+	FromPlace *PiecePlace        `json:"from_place,omitempty"` // 矢印の始点
+	ToPlace   *PiecePlace        `json:"to_place,omitempty"`   // 矢印の終点、強調するマス
+	Mark      *string            `json:"mark,omitempty"`       // 評価記号
+	Color     *string            `json:"color,omitempty"`      // 矢印・強調の色
+}
+
+func (t *KifuAnnotation) ToResponse() *KifuAnnotationResponse {
+	return &KifuAnnotationResponse{
+		Type:      t.Type,
+		FromPlace: t.FromPlace,
+		ToPlace:   t.ToPlace,
+		Mark:      t.Mark,
+		Color:     t.Color,
+	}
+}
+
+func annotationsToResponse(annotations []*KifuAnnotation) []*KifuAnnotationResponse {
+	if len(annotations) == 0 {
+		return nil
+	}
+	resp := make([]*KifuAnnotationResponse, len(annotations))
+	for i, annotation := range annotations {
+		resp[i] = annotation.ToResponse()
+	}
+	return resp
+}
+
+// ------------------------------------------------------------
+// 棋譜ファイルのコメントでの表記（KIF・CSA・JKFのコメント行に1注釈ずつ書く）
+// 取り込み時に注釈にし、棋譜ファイルの出力時はコメントの末尾に表記を加える
+//   #mark ?!
+//   #arrow 77 76 red （始点・終点は筋段の数字、色は省略可）
+//   #highlight 55 yellow
+// CSAの「'*」コメントは先頭の「*」を含めて取り込まれるため、「*#mark ?!」も受け付ける
+
+var kifuAnnotationCommentPattern = regexp.MustCompile(`^\*?#(mark|arrow|highlight)\s+(.+)$`)
+
+func placeToCommentString(place PiecePlace) string {
+	file, rank := place.FileRank()
+	return fmt.Sprintf("%d%d", file, rank)
+}
+
+func placeFromCommentString(s string) (*PiecePlace, bool) {
+	if len(s) != 2 {
+		return nil, false
+	}
+	file, err1 := strconv.Atoi(s[:1])
+	rank, err2 := strconv.Atoi(s[1:])
+	if err1 != nil || err2 != nil || file < 1 || rank < 1 {
+		return nil, false
+	}
+	place := NewPiecePlaceFromFileRank(file, rank)
+	return &place, true
+}
+
+// 注釈をコメント行の表記にする
+func (t *KifuAnnotation) ToCommentLine() string {
+	var line string
+	switch t.Type {
+	case KIFU_ANNOTATION_MARK:
+		return "#mark " + *t.Mark
+	case KIFU_ANNOTATION_ARROW:
+		line = fmt.Sprintf("#arrow %s %s", placeToCommentString(*t.FromPlace), placeToCommentString(*t.ToPlace))
+	case KIFU_ANNOTATION_HIGHLIGHT:
+		line = fmt.Sprintf("#highlight %s", placeToCommentString(*t.ToPlace))
+	}
+	if t.Color != nil {
+		line += " " + *t.Color
+	}
+	return line
+}
+
+// コメント行の表記を注釈にする（注釈の表記でなければnil）
+func parseKifuAnnotationCommentLine(line string) *KifuAnnotation {
+	matches := kifuAnnotationCommentPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if matches == nil {
+		return nil
+	}
+	annotation := &KifuAnnotation{Type: KifuAnnotationType(matches[1])}
+	args := strings.Fields(matches[2])
+	switch annotation.Type {
+	case KIFU_ANNOTATION_MARK:
+		annotation.Mark = &args[0]
+		args = args[1:]
+	case KIFU_ANNOTATION_ARROW:
+		if len(args) < 2 {
+			return nil
+		}
+		from, ok1 := placeFromCommentString(args[0])
+		to, ok2 := placeFromCommentString(args[1])
+		if !ok1 || !ok2 {
+			return nil
+		}
+		annotation.FromPlace, annotation.ToPlace = from, to
+		args = args[2:]
+	case KIFU_ANNOTATION_HIGHLIGHT:
+		to, ok := placeFromCommentString(args[0])
+		if !ok {
+			return nil
+		}
+		annotation.ToPlace = to
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		annotation.Color = &args[0]
+	}
+	if annotation.Validate() != nil {
+		return nil
+	}
+	return annotation
+}
+
+// 棋譜ファイル出力用に、コメントの末尾に注釈の表記を加える
+func (t *KifuMove) CommentWithAnnotations() *string {
+	lines := []string{}
+	if t.Comment != nil && *t.Comment != "" {
+		lines = append(lines, *t.Comment)
+	}
+	for _, annotation := range t.Annotations {
+		lines = append(lines, annotation.ToCommentLine())
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	comment := strings.Join(lines, "\n")
+	return &comment
+}
+
+// 棋譜ファイルから取り込んだコメントのうち、注釈の表記の行を注釈として取り出す
+func (t *KifuMove) ExtractAnnotationsFromComment() {
+	if t.Comment == nil {
+		return
+	}
+	lines := []string{}
+	extracted := false
+	for _, line := range strings.Split(*t.Comment, "\n") {
+		annotation := parseKifuAnnotationCommentLine(line)
+		if annotation == nil || len(t.Annotations) >= kifuAnnotationMaxCount ||
+			(annotation.Type == KIFU_ANNOTATION_MARK && t.hasAnnotationMark()) {
+			lines = append(lines, line)
+			continue
+		}
+		annotation.BranchID, annotation.Number = t.BranchID, t.Number
+		t.Annotations = append(t.Annotations, annotation)
+		extracted = true
+	}
+	if !extracted {
+		return
+	}
+	comment := strings.Join(lines, "\n")
+	if strings.TrimSpace(comment) == "" {
+		t.Comment = nil
+	} else {
+		t.Comment = &comment
+	}
+}
+
+func (t *KifuMove) hasAnnotationMark() bool {
+	for _, annotation := range t.Annotations {
+		if annotation.Type == KIFU_ANNOTATION_MARK {
+			return true
+		}
+	}
+	return false
+}
+
+// 取り込んだ棋譜の全ての指し手について、コメントから注釈を取り出す
+func (t *ParsedKifu) ExtractAnnotations() {
+	for _, branch := range t.Branches {
+		for _, move := range branch.Moves {
+			move.ExtractAnnotationsFromComment()
+		}
+	}
+}
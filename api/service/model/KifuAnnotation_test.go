@@ -0,0 +1,116 @@
+// service/model/KifuAnnotation_test.go
+
+package model
+
+import "testing"
+
+func TestExtractAnnotationsFromComment(t *testing.T) {
+	comment := "好手\n#mark !\n*#arrow 77 76 red\n#highlight 55\n#arrow 7 76"
+	move := &KifuMove{BranchID: "b", Number: 3, Comment: &comment}
+	move.ExtractAnnotationsFromComment()
+
+	if len(move.Annotations) != 3 {
+		t.Fatalf("annotations = %d, want 3", len(move.Annotations))
+	}
+	if a := move.Annotations[0]; a.Type != KIFU_ANNOTATION_MARK || *a.Mark != "!" {
+		t.Errorf("annotation[0] = %+v, want mark !", *a)
+	}
+	if a := move.Annotations[1]; a.Type != KIFU_ANNOTATION_ARROW ||
+		*a.FromPlace != NewPiecePlaceFromFileRank(7, 7) || *a.ToPlace != NewPiecePlaceFromFileRank(7, 6) || *a.Color != "red" {
+		t.Errorf("annotation[1] = %+v, want arrow 77 76 red", *a)
+	}
+	if a := move.Annotations[2]; a.Type != KIFU_ANNOTATION_HIGHLIGHT || *a.ToPlace != NewPiecePlaceFromFileRank(5, 5) || a.Color != nil {
+		t.Errorf("annotation[2] = %+v, want highlight 55", *a)
+	}
+	for _, a := range move.Annotations {
+		if a.BranchID != "b" || a.Number != 3 {
+			t.Errorf("annotation position = %s:%d, want b:3", a.BranchID, a.Number)
+		}
+	}
+	// 注釈の表記でない行はコメントに残す
+	if move.Comment == nil || *move.Comment != "好手\n#arrow 7 76" {
+		t.Errorf("comment = %v, want remaining lines", move.Comment)
+	}
+}
+
+func TestExtractAnnotationsFromCommentOnly(t *testing.T) {
+	comment := "#mark ??"
+	move := &KifuMove{Comment: &comment}
+	move.ExtractAnnotationsFromComment()
+	if move.Comment != nil {
+		t.Errorf("comment = %q, want nil", *move.Comment)
+	}
+	if len(move.Annotations) != 1 {
+		t.Errorf("annotations = %d, want 1", len(move.Annotations))
+	}
+}
+
+func TestKifuAnnotationCommentLineRoundTrip(t *testing.T) {
+	from, to := NewPiecePlaceFromFileRank(7, 7), NewPiecePlaceFromFileRank(7, 6)
+	center := NewPiecePlaceFromFileRank(5, 5)
+	corner := NewPiecePlaceFromFileRank(9, 1)
+	mark, red, yellow := "?!", "red", "yellow"
+	annotations := []*KifuAnnotation{
+		{Type: KIFU_ANNOTATION_MARK, Mark: &mark},
+		{Type: KIFU_ANNOTATION_ARROW, FromPlace: &from, ToPlace: &to},
+		{Type: KIFU_ANNOTATION_ARROW, FromPlace: &corner, ToPlace: &center, Color: &red},
+		{Type: KIFU_ANNOTATION_HIGHLIGHT, ToPlace: &center},
+		{Type: KIFU_ANNOTATION_HIGHLIGHT, ToPlace: &corner, Color: &yellow},
+	}
+	for _, want := range annotations {
+		line := want.ToCommentLine()
+		got := parseKifuAnnotationCommentLine(line)
+		if got == nil {
+			t.Errorf("parse(%q) = nil", line)
+			continue
+		}
+		if !equalKifuAnnotation(got, want) {
+			t.Errorf("parse(%q) = %+v, want %+v", line, *got, *want)
+		}
+	}
+}
+
+func TestCommentWithAnnotationsRoundTrip(t *testing.T) {
+	comment := "好手\n次の一手"
+	to := NewPiecePlaceFromFileRank(2, 4)
+	mark, blue := "!", "blue"
+	move := &KifuMove{
+		BranchID: "b",
+		Number:   5,
+		Comment:  &comment,
+		Annotations: []*KifuAnnotation{
+			{BranchID: "b", Number: 5, Type: KIFU_ANNOTATION_MARK, Mark: &mark},
+			{BranchID: "b", Number: 5, Type: KIFU_ANNOTATION_HIGHLIGHT, ToPlace: &to, Color: &blue},
+		},
+	}
+
+	exported := move.CommentWithAnnotations()
+	imported := &KifuMove{BranchID: "b", Number: 5, Comment: exported}
+	imported.ExtractAnnotationsFromComment()
+
+	if imported.Comment == nil || *imported.Comment != comment {
+		t.Errorf("comment = %v, want %q", imported.Comment, comment)
+	}
+	if len(imported.Annotations) != len(move.Annotations) {
+		t.Fatalf("annotations = %d, want %d", len(imported.Annotations), len(move.Annotations))
+	}
+	for i := range move.Annotations {
+		if !equalKifuAnnotation(imported.Annotations[i], move.Annotations[i]) {
+			t.Errorf("annotation[%d] = %+v, want %+v", i, *imported.Annotations[i], *move.Annotations[i])
+		}
+	}
+}
+
+func TestCommentWithAnnotationsEmpty(t *testing.T) {
+	if comment := (&KifuMove{}).CommentWithAnnotations(); comment != nil {
+		t.Errorf("comment = %q, want nil", *comment)
+	}
+}
+
+func equalKifuAnnotation(a, b *KifuAnnotation) bool {
+	equalPlace := func(x, y *PiecePlace) bool { return (x == nil && y == nil) || (x != nil && y != nil && *x == *y) }
+	equalString := func(x, y *string) bool { return (x == nil && y == nil) || (x != nil && y != nil && *x == *y) }
+	return a.BranchID == b.BranchID && a.Number == b.Number && a.Type == b.Type &&
+		equalPlace(a.FromPlace, b.FromPlace) && equalPlace(a.ToPlace, b.ToPlace) &&
+		equalString(a.Mark, b.Mark) && equalString(a.Color, b.Color)
+}
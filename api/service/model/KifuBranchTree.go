@@ -0,0 +1,90 @@
+// service/model/KifuBranchTree.go
+// 分岐ツリー（KifuBranchWithMovesのリスト）の操作
+
+package model
+
+import (
+	"sort"
+)
+
+func FindBranch(branches []*KifuBranchWithMoves, branchID string) *KifuBranchWithMoves {
+	for _, branch := range branches {
+		if branch.ID == branchID {
+			return branch
+		}
+	}
+	return nil
+}
+
+// メインライン（RootBranchIDがnullのもの）
+func MainBranch(branches []*KifuBranchWithMoves) *KifuBranchWithMoves {
+	for _, branch := range branches {
+		if branch.RootBranchID == nil {
+			return branch
+		}
+	}
+	return nil
+}
+
+// ブランチの最終手の番号（指し手が無い場合は分岐元の番号）
+func (t *KifuBranchWithMoves) LastNumber() int64 {
+	if len(t.Moves) > 0 {
+		return t.Moves[len(t.Moves)-1].Number
+	}
+	if t.RootNumber != nil {
+		return *t.RootNumber
+	}
+	return 0
+}
+
+// number手目の指し手（無ければnil）
+func (t *KifuBranchWithMoves) MoveAt(number int64) *KifuMove {
+	for _, move := range t.Moves {
+		if move.Number == number {
+			return move
+		}
+	}
+	return nil
+}
+
+// 同じ手から分岐するブランチ（兄弟間の順序で並べる）
+func SiblingBranches(branches []*KifuBranchWithMoves, rootBranchID string, rootNumber int64) []*KifuBranchWithMoves {
+	result := []*KifuBranchWithMoves{}
+	for _, branch := range branches {
+		if branch.RootBranchID != nil && *branch.RootBranchID == rootBranchID && *branch.RootNumber == rootNumber {
+			result = append(result, branch)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].SortOrder < result[j].SortOrder
+	})
+	return result
+}
+
+// branchIDのafterNumber手目より後から分岐するブランチと、その子孫のブランチのID
+func DescendantBranchIDs(branches []*KifuBranchWithMoves, branchID string, afterNumber int64) []string {
+	result := []string{}
+	for _, branch := range branches {
+		if branch.RootBranchID == nil || *branch.RootBranchID != branchID || *branch.RootNumber <= afterNumber {
+			continue
+		}
+		result = append(result, branch.ID)
+		result = append(result, DescendantBranchIDs(branches, branch.ID, -1)...)
+	}
+	return result
+}
+
+// 指定したIDのブランチを除いたリスト
+func RemoveBranches(branches []*KifuBranchWithMoves, branchIDs []string) []*KifuBranchWithMoves {
+	removed := make(map[string]bool, len(branchIDs))
+	for _, id := range branchIDs {
+		removed[id] = true
+	}
+	result := make([]*KifuBranchWithMoves, 0, len(branches))
+	for _, branch := range branches {
+		if !removed[branch.ID] {
+			result = append(result, branch)
+		}
+	}
+	return result
+}
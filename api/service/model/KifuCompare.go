@@ -0,0 +1,141 @@
+// service/model/KifuCompare.go
+// 2つの棋譜のメインラインを比較する
+
+package model
+
+import (
+	"fmt"
+)
+
+type KifuCompareResponse struct {
+	A                *KifuCompareSideResponse      `json:"a"`
+	B                *KifuCompareSideResponse      `json:"b"`
+	DivergenceNumber *int64                        `json:"divergence_number"` // 最初に指し手が異なる手数（同一手順ならNULL）
+	Transpositions   []*KifuTranspositionResponse  `json:"transpositions"`    // 異なる手順で同一局面に到達した箇所
+	Differences      []*KifuMoveDifferenceResponse `json:"differences"`       // 分岐以降の手ごとの指し手
+}
+
+type KifuCompareSideResponse struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	MoveCount int64  `json:"move_count"` // メインラインの手数
+}
+
+type KifuTranspositionResponse struct {
+	ANumber int64 `json:"a_number"` // 棋譜Aで局面に到達した手数
+	BNumber int64 `json:"b_number"` // 棋譜Bで局面に到達した手数
+	SFEN    SFEN  `json:"sfen"`
+}
+
+type KifuMoveDifferenceResponse struct {
+	Number int64             `json:"number"`
+	A      *KifuMoveResponse `json:"a"` // 棋譜Aの指し手（手数が足りなければNULL）
+	B      *KifuMoveResponse `json:"b"` // 棋譜Bの指し手（手数が足りなければNULL）
+}
+
+// メインラインを再生し、各手のレスポンスと各手の後の局面のキーを返す（keys[0]は開始局面）
+func (t *Kifu) replayMainLine(branches []*KifuBranchWithMoves) ([]*KifuMoveResponse, []string, error) {
+	mainBranch := MainBranch(branches)
+	if mainBranch == nil {
+		return nil, nil, fmt.Errorf("main branch not found: %s", t.ID)
+	}
+	position, err := NewBoardPosition(t.InitialPosition)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := position.Key()
+	if err != nil {
+		return nil, nil, err
+	}
+	moves := make([]*KifuMoveResponse, 0, len(mainBranch.Moves))
+	keys := []string{key}
+	for _, move := range mainBranch.Moves {
+		resp := move.ToResponse(mainBranch.ID, nil, position)
+		if resp == nil {
+			return nil, nil, fmt.Errorf("invalid move in kifu %s at %d", t.ID, move.Number)
+		}
+		if key, err = position.Key(); err != nil {
+			return nil, nil, err
+		}
+		moves = append(moves, resp)
+		keys = append(keys, key)
+	}
+	return moves, keys, nil
+}
+
+// メインラインの開始局面と各手の後の局面のキー
+func (t *Kifu) MainLinePositionKeys(branches []*KifuBranchWithMoves) ([]string, error) {
+	_, keys, err := t.replayMainLine(branches)
+	return keys, err
+}
+
+func isSameMove(a *KifuMoveResponse, b *KifuMoveResponse) bool {
+	return a.Piece == b.Piece && a.FromPlace == b.FromPlace && a.ToPlace == b.ToPlace
+}
+
+// 開始局面が同じ2つの棋譜のメインラインを比較する
+func CompareKifus(a *Kifu, aBranches []*KifuBranchWithMoves, b *Kifu, bBranches []*KifuBranchWithMoves) (*KifuCompareResponse, error) {
+	aMoves, aKeys, err := a.replayMainLine(aBranches)
+	if err != nil {
+		return nil, err
+	}
+	bMoves, bKeys, err := b.replayMainLine(bBranches)
+	if err != nil {
+		return nil, err
+	}
+	if aKeys[0] != bKeys[0] {
+		return nil, fmt.Errorf("initial position differs: %s, %s", a.ID, b.ID)
+	}
+
+	resp := &KifuCompareResponse{
+		A:              &KifuCompareSideResponse{ID: a.ID, Title: a.Title, MoveCount: int64(len(aMoves))},
+		B:              &KifuCompareSideResponse{ID: b.ID, Title: b.Title, MoveCount: int64(len(bMoves))},
+		Transpositions: []*KifuTranspositionResponse{},
+		Differences:    []*KifuMoveDifferenceResponse{},
+	}
+
+	// 最初に指し手が異なる手（一方が途中で終わる場合はその次の手）
+	common := 0
+	for common < len(aMoves) && common < len(bMoves) && isSameMove(aMoves[common], bMoves[common]) {
+		common++
+	}
+	if common == len(aMoves) && common == len(bMoves) {
+		return resp, nil
+	}
+	divergence := int64(common + 1)
+	resp.DivergenceNumber = &divergence
+
+	// 分岐以降の手ごとの指し手
+	for i := common; i < len(aMoves) || i < len(bMoves); i++ {
+		diff := &KifuMoveDifferenceResponse{Number: int64(i + 1)}
+		if i < len(aMoves) {
+			diff.A = aMoves[i]
+		}
+		if i < len(bMoves) {
+			diff.B = bMoves[i]
+		}
+		resp.Differences = append(resp.Differences, diff)
+	}
+
+	// 少なくとも一方が分岐以降に到達した同一局面（各局面で最初に到達した手数）
+	aFirst := make(map[string]int)
+	for i := 1; i < len(aKeys); i++ {
+		if _, ok := aFirst[aKeys[i]]; !ok {
+			aFirst[aKeys[i]] = i
+		}
+	}
+	reported := make(map[string]bool)
+	for j := 1; j < len(bKeys); j++ {
+		i, ok := aFirst[bKeys[j]]
+		if !ok || reported[bKeys[j]] || (i <= common && j <= common) {
+			continue
+		}
+		reported[bKeys[j]] = true
+		resp.Transpositions = append(resp.Transpositions, &KifuTranspositionResponse{
+			ANumber: int64(i),
+			BNumber: int64(j),
+			SFEN:    SFEN(bKeys[j]),
+		})
+	}
+	return resp, nil
+}
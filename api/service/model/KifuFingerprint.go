@@ -0,0 +1,38 @@
+// service/model/KifuFingerprint.go
+// 同一棋譜の判定に使用するフィンガープリント
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// 初期局面とメインラインの指し手からフィンガープリントを生成する
+// 対局情報やコメントは含めず、同じ局面から同じ手順を指した棋譜は同一とみなす
+func NewKifuFingerprint(initialPosition *SFEN, mainMoves []*KifuMove) (string, error) {
+	position, err := NewBoardPosition(initialPosition)
+	if err != nil {
+		return "", err
+	}
+	sfen, err := position.ToSFEN(1) // 手数などの表記揺れを正規化
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(sfen))
+	for _, move := range mainMoves {
+		fmt.Fprintf(h, "/%d:%02x%02x%02x", move.Number, move.Piece, move.FromPlace, move.ToPlace)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (t *ParsedKifu) Fingerprint() (string, error) {
+	var mainMoves []*KifuMove
+	if len(t.Branches) > 0 {
+		mainMoves = t.Branches[0].Moves
+	}
+	return NewKifuFingerprint(t.Kifu.InitialPosition, mainMoves)
+}
@@ -0,0 +1,258 @@
+// service/model/KifuMerge.go
+// 複数の棋譜のメインラインを1つの分岐ツリーに統合する
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const KifuMoveCommentMaxLength = 1000 // kifu_movesのコメントの上限
+
+// 統合元の棋譜
+type KifuMergeSource struct {
+	Kifu     *Kifu
+	Branches []*KifuBranchWithMoves
+}
+
+// 出典の表記（タイトルと対局者）
+func (t *KifuMergeSource) Label() string {
+	if t.Kifu.BlackPlayer != nil && t.Kifu.WhitePlayer != nil {
+		return fmt.Sprintf("%s（%s vs %s）", t.Kifu.Title, *t.Kifu.BlackPlayer, *t.Kifu.WhitePlayer)
+	}
+	return t.Kifu.Title
+}
+
+// メインラインの初手が同じか（指し手の無い棋譜は他の棋譜と分岐しない）
+func (t *KifuMergeSource) SameFirstMove(other *KifuMergeSource) bool {
+	a, b := MainBranch(t.Branches), MainBranch(other.Branches)
+	if a == nil || b == nil || len(a.Moves) == 0 || len(b.Moves) == 0 {
+		return true
+	}
+	x, y := a.Moves[0], b.Moves[0]
+	return x.Piece == y.Piece && x.FromPlace == y.FromPlace && x.ToPlace == y.ToPlace
+}
+
+// 開始局面の同一判定用のキー（平手初期局面のNULLとSFEN表記の違いを吸収する）
+func (t *Kifu) InitialPositionKey() (string, error) {
+	position, err := NewBoardPosition(t.InitialPosition)
+	if err != nil {
+		return "", err
+	}
+	return position.Key()
+}
+
+type kifuMergeComment struct {
+	source int
+	text   string
+}
+
+// 統合ツリーのノード（1つの指し手）
+type kifuMergeNode struct {
+	move     *KifuMove
+	sources  []int // この手を通る統合元
+	comments []kifuMergeComment
+	endings  []int // この手で終わる統合元
+	children []*kifuMergeNode
+	notes    []string // 出典の注記
+}
+
+func (t *kifuMergeNode) number() int64 {
+	if t.move == nil {
+		return 0
+	}
+	return t.move.Number
+}
+
+func (t *kifuMergeNode) child(move *KifuMove) *kifuMergeNode {
+	for _, child := range t.children {
+		if child.move.Piece == move.Piece && child.move.FromPlace == move.FromPlace && child.move.ToPlace == move.ToPlace {
+			return child
+		}
+	}
+	child := &kifuMergeNode{
+		move: &KifuMove{
+			Number:    move.Number,
+			Piece:     move.Piece,
+			FromPlace: move.FromPlace,
+			ToPlace:   move.ToPlace,
+		},
+	}
+	t.children = append(t.children, child)
+	return child
+}
+
+// 開始局面が同じ棋譜のメインラインを統合する
+// 共通の手順は1本にまとめ、手順が分かれたところを分岐にする（最初の棋譜の手順がメインライン）
+// 戻り値のブランチは親ブランチが先の順で、IDは仮のもの
+func MergeKifuLines(sources []*KifuMergeSource) ([]*KifuBranchWithMoves, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no kifu to merge")
+	}
+
+	// 各棋譜のメインラインを局面で検証しながらツリーに追加
+	root := &kifuMergeNode{}
+	paths := make([][]*kifuMergeNode, len(sources))
+	for i, source := range sources {
+		mainBranch := MainBranch(source.Branches)
+		if mainBranch == nil {
+			return nil, fmt.Errorf("main branch not found: %s", source.Kifu.ID)
+		}
+		position, err := NewBoardPosition(source.Kifu.InitialPosition)
+		if err != nil {
+			return nil, err
+		}
+		node := root
+		for _, move := range mainBranch.Moves {
+			if err := position.Move(move); err != nil {
+				return nil, fmt.Errorf("invalid move in kifu %s at %d: %w", source.Kifu.ID, move.Number, err)
+			}
+			node = node.child(move)
+			node.sources = append(node.sources, i)
+			if len(node.move.Annotations) == 0 { // 注釈は最初に注釈のある統合元のものを使う
+				node.move.Annotations = copyKifuAnnotations(move.Annotations)
+			}
+			if move.Comment != nil && *move.Comment != "" {
+				node.comments = append(node.comments, kifuMergeComment{source: i, text: *move.Comment})
+			}
+			paths[i] = append(paths[i], node)
+		}
+		node.endings = append(node.endings, i)
+	}
+
+	// 出典の注記は、その棋譜だけが通る最初の手に付ける（他の棋譜と同じ手順で終わる場合は最終手）
+	for i, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		target := path[len(path)-1]
+		for _, node := range path {
+			if len(node.sources) == 1 {
+				target = node
+				break
+			}
+		}
+		target.notes = append(target.notes, "出典: "+sources[i].Label())
+	}
+
+	// 初手の分岐はメインラインの分岐として表せない
+	if len(root.children) > 1 {
+		return nil, fmt.Errorf("first moves differ among kifus to merge")
+	}
+
+	builder := &kifuMergeBuilder{sources: sources}
+	mainBranch := builder.newBranch(nil, nil)
+	builder.addLine(root, mainBranch)
+	return builder.branches, nil
+}
+
+type kifuMergeBuilder struct {
+	sources  []*KifuMergeSource
+	branches []*KifuBranchWithMoves
+}
+
+func (b *kifuMergeBuilder) newBranch(rootBranchID *string, rootNumber *int64) *KifuBranchWithMoves {
+	branch := &KifuBranchWithMoves{
+		KifuBranch: &KifuBranch{
+			ID:           fmt.Sprintf("merge-%d", len(b.branches)), // 仮ID
+			RootBranchID: rootBranchID,
+			RootNumber:   rootNumber,
+		},
+		Moves: []*KifuMove{},
+	}
+	b.branches = append(b.branches, branch)
+	return branch
+}
+
+// nodeの後の手順をbranchに追加する（2つめ以降の候補手は分岐にする）
+func (b *kifuMergeBuilder) addLine(node *kifuMergeNode, branch *KifuBranchWithMoves) {
+	for {
+		if len(node.children) == 0 {
+			b.setEnding(node, branch)
+			return
+		}
+		rootBranchID, rootNumber := branch.ID, node.number()
+		for _, child := range node.children[1:] {
+			variation := b.newBranch(&rootBranchID, &rootNumber)
+			variation.Moves = append(variation.Moves, b.toKifuMove(child, variation.ID))
+			b.addLine(child, variation)
+		}
+		node = node.children[0]
+		branch.Moves = append(branch.Moves, b.toKifuMove(node, branch.ID))
+	}
+}
+
+func copyKifuAnnotations(annotations []*KifuAnnotation) []*KifuAnnotation {
+	result := make([]*KifuAnnotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		copied := *annotation
+		result = append(result, &copied)
+	}
+	return result
+}
+
+func (b *kifuMergeBuilder) toKifuMove(node *kifuMergeNode, branchID string) *KifuMove {
+	move := *node.move
+	move.BranchID = branchID
+	move.Annotations = copyKifuAnnotations(node.move.Annotations)
+
+	// コメントが統合元で異なる場合は出典を付けて併記する
+	texts := []string{}
+	distinct := make(map[string]bool)
+	for _, comment := range node.comments {
+		distinct[comment.text] = true
+	}
+	for _, comment := range node.comments {
+		if len(distinct) > 1 {
+			texts = append(texts, fmt.Sprintf("[%s] %s", b.sources[comment.source].Label(), comment.text))
+		} else if len(texts) == 0 {
+			texts = append(texts, comment.text)
+		}
+	}
+	if len(texts) == 0 && len(node.notes) == 0 {
+		return &move
+	}
+
+	// kifu_movesのコメントの上限に収める（出典の注記を残し、併記したコメントを切り詰める）
+	if len(node.notes) > 0 {
+		notes := strings.Join(node.notes, "\n")
+		body := truncateRunes(strings.Join(texts, "\n"), KifuMoveCommentMaxLength-utf8.RuneCountInString(notes)-1)
+		texts = []string{}
+		if body != "" {
+			texts = append(texts, body)
+		}
+		texts = append(texts, notes)
+	}
+	comment := truncateRunes(strings.Join(texts, "\n"), KifuMoveCommentMaxLength)
+	move.Comment = &comment
+	return &move
+}
+
+// maxRunes文字を超える場合は末尾を「…」にして切り詰める
+func truncateRunes(s string, maxRunes int) string {
+	if maxRunes < 1 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes-1]) + "…"
+}
+
+// ラインの最後で終わる統合元の終局情報をブランチに付ける（最初の統合元を優先）
+func (b *kifuMergeBuilder) setEnding(node *kifuMergeNode, branch *KifuBranchWithMoves) {
+	for _, i := range node.endings {
+		ending := MainBranch(b.sources[i].Branches)
+		if ending.EndingType == nil {
+			continue
+		}
+		endingNumber := node.number() + 1
+		branch.EndingNumber = &endingNumber
+		branch.EndingType = ending.EndingType
+		branch.EndingComment = ending.EndingComment
+		return
+	}
+}
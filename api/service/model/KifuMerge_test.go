@@ -0,0 +1,88 @@
+// service/model/KifuMerge_test.go
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func newMergeTestSource(title string, comment string, moves ...[3]int) *KifuMergeSource {
+	branch := &KifuBranchWithMoves{KifuBranch: &KifuBranch{ID: title + "-main"}}
+	for i, m := range moves {
+		move := &KifuMove{
+			BranchID:  branch.ID,
+			Number:    int64(i + 1),
+			Piece:     PIECE_FU,
+			FromPlace: NewPiecePlaceFromFileRank(m[0], m[1]),
+			ToPlace:   NewPiecePlaceFromFileRank(m[0], m[2]),
+		}
+		if comment != "" {
+			move.Comment = &comment
+		}
+		branch.Moves = append(branch.Moves, move)
+	}
+	return &KifuMergeSource{
+		Kifu:     &Kifu{ID: title, Title: title, InitialPosition: SfenHirate.PSFEN()},
+		Branches: []*KifuBranchWithMoves{branch},
+	}
+}
+
+func TestMergeKifuLinesCommentLength(t *testing.T) {
+	long := strings.Repeat("長", 900)
+	sources := []*KifuMergeSource{
+		newMergeTestSource("a", long+"A", [3]int{7, 7, 6}, [3]int{3, 3, 4}),
+		newMergeTestSource("b", long+"B", [3]int{7, 7, 6}, [3]int{3, 3, 4}),
+		newMergeTestSource("c", long+"C", [3]int{7, 7, 6}, [3]int{8, 3, 4}),
+	}
+	branches, err := MergeKifuLines(sources)
+	if err != nil {
+		t.Fatalf("MergeKifuLines() error = %v", err)
+	}
+	for _, branch := range branches {
+		for _, move := range branch.Moves {
+			if move.Comment == nil {
+				continue
+			}
+			if n := utf8.RuneCountInString(*move.Comment); n > KifuMoveCommentMaxLength {
+				t.Errorf("comment of move %d has %d runes, want <= %d", move.Number, n, KifuMoveCommentMaxLength)
+			}
+		}
+	}
+	// 出典の注記は切り詰めない
+	variation := branches[1]
+	if !strings.HasSuffix(*variation.Moves[0].Comment, "出典: c") {
+		t.Errorf("variation comment does not end with the source note: %q", *variation.Moves[0].Comment)
+	}
+}
+
+func TestMergeKifuLinesFirstMoveDiffers(t *testing.T) {
+	sources := []*KifuMergeSource{
+		newMergeTestSource("a", "", [3]int{7, 7, 6}),
+		newMergeTestSource("b", "", [3]int{2, 7, 6}),
+	}
+	if sources[0].SameFirstMove(sources[1]) {
+		t.Error("SameFirstMove() = true, want false")
+	}
+	if _, err := MergeKifuLines(sources); err == nil {
+		t.Error("MergeKifuLines() error = nil, want error for different first moves")
+	}
+}
+
+func TestMergeKifuLinesVariationRoot(t *testing.T) {
+	sources := []*KifuMergeSource{
+		newMergeTestSource("a", "", [3]int{7, 7, 6}, [3]int{3, 3, 4}),
+		newMergeTestSource("b", "", [3]int{7, 7, 6}, [3]int{8, 3, 4}),
+	}
+	branches, err := MergeKifuLines(sources)
+	if err != nil {
+		t.Fatalf("MergeKifuLines() error = %v", err)
+	}
+	if len(branches) != 2 {
+		t.Fatalf("branches = %d, want 2", len(branches))
+	}
+	if root := branches[1].RootNumber; root == nil || *root != 1 {
+		t.Errorf("variation root number = %v, want 1", root)
+	}
+}
@@ -80,26 +80,61 @@ var EndingNameToEndingTypeCSA = map[string]EndingType{
 
 // --------------------------------------------------------------------------------
 type KifuMoveResponse struct {
-	Number        int64                   `json:"number"`                   // 何手目か（分岐の場合も初手からカウント）
-	Piece         PieceType               `json:"piece"`                    // 動いた元の駒種
-	FromPlace     PiecePlace              `json:"from_place"`               // 移動元の場所
-	ToPlace       PiecePlace              `json:"to_place"`                 // 移動先の場所
-	Promote       *bool                   `json:"promote,omitempty"`        // 成ったか（成らなければNULL）
-	CatchPiece    *PieceType              `json:"catch_piece,omitempty"`    // 取った駒種（取ってなければNULL）
-	DirectionSign *string                 `json:"direction_sign,omitempty"` // 方向の符号（無ければNULL）
-	Variations    *[]KifuMoveLineResponse `json:"variations,omitempty"`     // この手に変わる分岐
-	Comment       *string                 `json:"comment"`                  // コメント
-	TimeSpentMs   *int64                  `json:"time_spent_ms"`            // 消費時間（ミリ秒）
+	BranchID         string                    `json:"branch_id"`                   // この手を含むブランチのID（分岐単位の編集に使用）
+	Number           int64                     `json:"number"`                      // 何手目か（分岐の場合も初手からカウント）
+	Piece            PieceType                 `json:"piece"`                       // 動いた元の駒種
+	FromPlace        PiecePlace                `json:"from_place"`                  // 移動元の場所
+	ToPlace          PiecePlace                `json:"to_place"`                    // 移動先の場所
+	Promote          *bool                     `json:"promote,omitempty"`           // 成ったか（成らなければNULL）
+	CatchPiece       *PieceType                `json:"catch_piece,omitempty"`       // 取った駒種（取ってなければNULL）
+	DirectionSign    *string                   `json:"direction_sign,omitempty"`    // 方向の符号（無ければNULL）
+	Variations       *[]KifuMoveLineResponse   `json:"variations,omitempty"`        // この手に変わる分岐
+	VariationEndings *[]*KifuEndingResponse    `json:"variation_endings,omitempty"` // 分岐ごとの終局（variationsと同じ順、終局の無い分岐はnull）
+	Comment          *string                   `json:"comment"`                     // コメント
+	TimeSpentMs      *int64                    `json:"time_spent_ms"`               // 消費時間（ミリ秒）
+	Annotations      []*KifuAnnotationResponse `json:"annotations,omitempty"`       // 矢印・マスの強調・評価記号
 }
 
 type KifuMoveLineResponse []*KifuMoveResponse
 
-func (t *Kifu) buildMoves(branches []*KifuBranchWithMoves) KifuMoveLineResponse {
+type KifuEndingResponse struct {
+	Type    EndingType `json:"type"`              // 終局の種類
+	Name    string     `json:"name"`              // 終局の種類の名前（CSA形式の表記）
+	Number  int64      `json:"number"`            // 終局の番号（最終手の次）
+	Comment *string    `json:"comment,omitempty"` // 終局時のコメント
+}
+
+func (t *KifuBranch) ToEndingResponse() *KifuEndingResponse {
+	if t.EndingType == nil {
+		return nil
+	}
+	resp := &KifuEndingResponse{
+		Type:    *t.EndingType,
+		Name:    EndingTypeName[*t.EndingType],
+		Comment: t.EndingComment,
+	}
+	if t.EndingNumber != nil {
+		resp.Number = *t.EndingNumber
+	}
+	return resp
+}
+
+// ラインの終局情報（指し手の無いラインの終局も返す）
+func (t *KifuBranchWithMoves) lineEndingResponse() *KifuEndingResponse {
+	ending := t.ToEndingResponse()
+	if ending != nil && ending.Number == 0 {
+		ending.Number = t.LastNumber() + 1
+	}
+	return ending
+}
+
+// メインラインの指し手（分岐を含む）と終局情報
+func (t *Kifu) buildMoves(branches []*KifuBranchWithMoves) (KifuMoveLineResponse, *KifuEndingResponse) {
 	// 開始局面を生成
 	position, err := NewBoardPosition(t.InitialPosition)
 	if err != nil {
 		slog.Error("failed to create board position from initial position", "kifuID", t.ID)
-		return KifuMoveLineResponse{}
+		return KifuMoveLineResponse{}, nil
 	}
 
 	// メインラインを特定（RootBranchIDがnullのもの）
@@ -111,7 +146,7 @@ func (t *Kifu) buildMoves(branches []*KifuBranchWithMoves) KifuMoveLineResponse
 	}
 	if mainBranch == nil {
 		slog.Error("failed to find main branch", "kifuID", t.ID)
-		return KifuMoveLineResponse{}
+		return KifuMoveLineResponse{}, nil
 	}
 
 	// メインラインから再帰的にKifuMoveResponseを作成
@@ -119,12 +154,13 @@ func (t *Kifu) buildMoves(branches []*KifuBranchWithMoves) KifuMoveLineResponse
 	for i, branchMove := range mainBranch.Moves {
 		moveResponses[i] = branchMove.ToResponse(mainBranch.ID, branches, position)
 	}
-	return moveResponses
+	return moveResponses, mainBranch.lineEndingResponse()
 }
 
 func (t *KifuMove) ToResponse(branchID string, allBranches []*KifuBranchWithMoves, position *BoardPosition) *KifuMoveResponse {
 	slog.Debug("KifuMove.ToResponse", "move", *t)
 	resp := &KifuMoveResponse{
+		BranchID:      branchID,
 		Number:        t.Number,
 		Piece:         t.Piece,
 		FromPlace:     t.FromPlace,
@@ -134,6 +170,7 @@ func (t *KifuMove) ToResponse(branchID string, allBranches []*KifuBranchWithMove
 		DirectionSign: position.DirectionSign(t), // 方向を表す符号
 		Comment:       t.Comment,
 		TimeSpentMs:   t.TimeSpentMs,
+		Annotations:   annotationsToResponse(t.Annotations),
 	}
 
 	// 局面を進める
@@ -143,18 +180,22 @@ func (t *KifuMove) ToResponse(branchID string, allBranches []*KifuBranchWithMove
 
 	// 分岐の追加
 	variations := []KifuMoveLineResponse{}
+	variationEndings := []*KifuEndingResponse{}
 	for _, branch := range allBranches {
 		if branch.RootBranchID != nil && *branch.RootBranchID == branchID && *branch.RootNumber == t.Number {
-			// 分岐ラインから再帰的にKifuMoveResponseを作成
+			// 分岐ラインから再帰的にKifuMoveResponseを作成（分岐内では局面を引き継ぐ）
+			branchPosition := position.Copy()
 			moveResponses := make([]*KifuMoveResponse, len(branch.Moves))
 			for i, branchMove := range branch.Moves {
-				moveResponses[i] = branchMove.ToResponse(branch.ID, allBranches, position.Copy())
+				moveResponses[i] = branchMove.ToResponse(branch.ID, allBranches, branchPosition)
 			}
 			variations = append(variations, moveResponses)
+			variationEndings = append(variationEndings, branch.lineEndingResponse())
 		}
 	}
 	if len(variations) > 0 {
 		resp.Variations = &variations
+		resp.VariationEndings = &variationEndings
 	}
 
 	return resp
@@ -0,0 +1,62 @@
+// service/model/KifuMoveResponse_test.go
+
+package model
+
+import "testing"
+
+func TestBuildMovesEmptyMainLineEnding(t *testing.T) {
+	toryo := ENDING_TORYO
+	kifu := &Kifu{ID: "k", InitialPosition: SfenHirate.PSFEN()}
+	main := &KifuBranchWithMoves{KifuBranch: &KifuBranch{ID: "main", EndingType: &toryo}}
+
+	moves, ending := kifu.buildMoves([]*KifuBranchWithMoves{main})
+	if len(moves) != 0 {
+		t.Errorf("moves = %d, want 0", len(moves))
+	}
+	if ending == nil || ending.Type != ENDING_TORYO || ending.Number != 1 {
+		t.Errorf("ending = %+v, want TORYO at 1", ending)
+	}
+}
+
+func TestBuildMovesVariationEndings(t *testing.T) {
+	toryo := ENDING_TORYO
+	rootNumber := int64(1)
+	kifu := &Kifu{ID: "k", InitialPosition: SfenHirate.PSFEN()}
+	main := &KifuBranchWithMoves{
+		KifuBranch: &KifuBranch{ID: "main"},
+		Moves: []*KifuMove{
+			{BranchID: "main", Number: 1, Piece: PIECE_FU, FromPlace: NewPiecePlaceFromFileRank(7, 7), ToPlace: NewPiecePlaceFromFileRank(7, 6)},
+		},
+	}
+	// 指し手の無い分岐（2手目で投了）と、終局の無い分岐
+	resign := &KifuBranchWithMoves{
+		KifuBranch: &KifuBranch{ID: "resign", RootBranchID: &main.ID, RootNumber: &rootNumber, EndingType: &toryo},
+	}
+	other := &KifuBranchWithMoves{
+		KifuBranch: &KifuBranch{ID: "other", RootBranchID: &main.ID, RootNumber: &rootNumber, SortOrder: 1},
+		Moves: []*KifuMove{
+			{BranchID: "other", Number: 2, Piece: PIECE_FU, FromPlace: NewPiecePlaceFromFileRank(3, 3), ToPlace: NewPiecePlaceFromFileRank(3, 4)},
+		},
+	}
+
+	moves, ending := kifu.buildMoves([]*KifuBranchWithMoves{main, resign, other})
+	if ending != nil {
+		t.Errorf("main ending = %+v, want nil", ending)
+	}
+	if len(moves) != 1 || moves[0].Variations == nil || moves[0].VariationEndings == nil {
+		t.Fatalf("moves = %+v, want one move with variations", moves)
+	}
+	variations, endings := *moves[0].Variations, *moves[0].VariationEndings
+	if len(variations) != 2 || len(endings) != 2 {
+		t.Fatalf("variations = %d, endings = %d, want 2 each", len(variations), len(endings))
+	}
+	if len(variations[0]) != 0 {
+		t.Errorf("variation[0] moves = %d, want 0", len(variations[0]))
+	}
+	if endings[0] == nil || endings[0].Type != ENDING_TORYO || endings[0].Number != 2 {
+		t.Errorf("variation ending[0] = %+v, want TORYO at 2", endings[0])
+	}
+	if endings[1] != nil {
+		t.Errorf("variation ending[1] = %+v, want nil", endings[1])
+	}
+}
@@ -0,0 +1,117 @@
+// service/model/KifuPosition.go
+// 棋譜の任意の手数の局面を生成する
+
+package model
+
+import (
+	"fmt"
+)
+
+// 指定ブランチのnumber手目の局面を生成する（0は開始局面、分岐は分岐元のブランチをたどる）
+func (t *Kifu) PositionAt(branches []*KifuBranchWithMoves, branchID string, number int64) (*BoardPosition, error) {
+	return t.replayPath(branches, branchID, number, nil)
+}
+
+// 開始局面から指定ブランチのnumber手目までを再生する（visitは開始局面と各手の後の局面で呼ばれる）
+func (t *Kifu) replayPath(branches []*KifuBranchWithMoves, branchID string, number int64, visit func(position *BoardPosition) error) (*BoardPosition, error) {
+	branchMap := make(map[string]*KifuBranchWithMoves, len(branches))
+	for _, branch := range branches {
+		branchMap[branch.ID] = branch
+	}
+
+	// 指定ブランチからメインラインまでの経路と、各ブランチで進める手数
+	path := []*KifuBranchWithMoves{}
+	limits := []int64{}
+	id, limit := branchID, number
+	for {
+		branch, ok := branchMap[id]
+		if !ok {
+			return nil, fmt.Errorf("branch not found: %s", id)
+		}
+		path = append(path, branch)
+		limits = append(limits, limit)
+		if branch.RootBranchID == nil || branch.RootNumber == nil {
+			break
+		}
+		if len(path) > len(branches) {
+			return nil, fmt.Errorf("circular branch reference: %s", branchID)
+		}
+		id, limit = *branch.RootBranchID, *branch.RootNumber
+	}
+
+	position, err := NewBoardPosition(t.InitialPosition)
+	if err != nil {
+		return nil, err
+	}
+	if visit != nil {
+		if err := visit(position); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		branch, limit := path[i], limits[i]
+		reached := int64(0)
+		if branch.RootNumber != nil {
+			reached = *branch.RootNumber
+		}
+		for _, move := range branch.Moves {
+			if move.Number > limit {
+				break
+			}
+			if err := position.Move(move); err != nil {
+				return nil, fmt.Errorf("failed to simulate move %d: %w", move.Number, err)
+			}
+			if visit != nil {
+				if err := visit(position); err != nil {
+					return nil, err
+				}
+			}
+			reached = move.Number
+		}
+		if reached != limit {
+			return nil, fmt.Errorf("move number out of range: %d", limit)
+		}
+	}
+	return position, nil
+}
+
+// 終局の種類が最終局面と矛盾しないか確認する（局面から判定できる種類のみ）
+func (t *Kifu) ValidateEnding(branches []*KifuBranchWithMoves, branchID string, endingType EndingType) error {
+	if _, ok := EndingTypeName[endingType]; !ok {
+		return fmt.Errorf("invalid ending type: %d", endingType)
+	}
+	branch := FindBranch(branches, branchID)
+	if branch == nil {
+		return fmt.Errorf("branch not found: %s", branchID)
+	}
+
+	switch endingType {
+	case ENDING_TSUMI: // 手番側が詰んでいること
+		position, err := t.PositionAt(branches, branchID, branch.LastNumber())
+		if err != nil {
+			return err
+		}
+		if !position.IsCheckmate() {
+			return fmt.Errorf("final position is not checkmate")
+		}
+	case ENDING_SENNICHITE: // 最終局面と同一の局面が4回現れていること
+		counts := map[string]int{}
+		var last string
+		_, err := t.replayPath(branches, branchID, branch.LastNumber(), func(position *BoardPosition) error {
+			key, err := position.Key()
+			if err != nil {
+				return err
+			}
+			counts[key]++
+			last = key
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if counts[last] < 4 {
+			return fmt.Errorf("final position has not appeared four times")
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@
 package model
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -20,6 +21,8 @@ type KifuSummaryResponse struct {
 	Tags         []string          `json:"tags"`      // タグリスト
 	LikeCount    int64             `json:"like_count"`
 	CommentCount int64             `json:"comment_count"`
+	Version      int64             `json:"version"`           // 更新時に指定する版
+	Snippet      *string           `json:"snippet,omitempty"` // キーワード検索で一致した箇所の抜粋（一致箇所を<mark>で囲む）
 }
 
 func (t *Kifu) ToSummaryResponse(owner *Account, kifuTags []*KifuTag) *KifuSummaryResponse {
@@ -33,6 +36,7 @@ func (t *Kifu) ToSummaryResponse(owner *Account, kifuTags []*KifuTag) *KifuSumma
 		Tags:         t.buildTags(kifuTags),
 		LikeCount:    t.LikeCount,
 		CommentCount: t.CommentCount,
+		Version:      t.Version,
 	}
 	return resp
 }
@@ -51,8 +55,13 @@ type KifuDetailResponse struct {
 	GameInfo        GameInfo             `json:"game_info"` // 対局情報
 	Tags            []string             `json:"tags"`      // タグリスト
 	Moves           KifuMoveLineResponse `json:"moves"`     // 指し手（分岐を含む）
+	Ending          *KifuEndingResponse  `json:"ending"`    // メインラインの終局（無ければNULL）
 	LikeCount       int64                `json:"like_count"`
 	HasLike         bool                 `json:"has_like"`
+	Version         int64                `json:"version"`         // 更新時に指定する版
+	ForkedFrom      *string              `json:"forked_from"`     // フォーク元の棋譜ID
+	BlackPlayerID   *string              `json:"black_player_id"` // 先手の対局者ID（対局者が確定していない場合はNULL）
+	WhitePlayerID   *string              `json:"white_player_id"` // 後手の対局者ID（対局者が確定していない場合はNULL）
 }
 
 func (t *Kifu) ToDetailResponse(owner *Account, options []*KifuOption, kifuTags []*KifuTag, branches []*KifuBranchWithMoves, hasLike bool) *KifuDetailResponse {
@@ -66,9 +75,31 @@ func (t *Kifu) ToDetailResponse(owner *Account, options []*KifuOption, kifuTags
 		GameInfo:        t.buildGameInfo(options),
 		Tags:            t.buildTags(kifuTags),
 		InitialPosition: t.InitialPosition,
-		Moves:           t.buildMoves(branches),
 		LikeCount:       t.LikeCount,
 		HasLike:         hasLike,
+		Version:         t.Version,
+		ForkedFrom:      t.ForkedFrom,
+	}
+	resp.Moves, resp.Ending = t.buildMoves(branches)
+	return resp
+}
+
+// ------------------------------------------------------------
+// 重複した棋譜の通知用のレスポンス
+
+type DuplicateKifuResponse struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	IsOwn bool   `json:"is_own"` // 自身の棋譜か
+	URL   string `json:"url"`    // 棋譜閲覧ページへのリンク
+}
+
+func (t *Kifu) ToDuplicateResponse(accountID string) *DuplicateKifuResponse {
+	resp := &DuplicateKifuResponse{
+		ID:    t.ID,
+		Title: t.Title,
+		IsOwn: t.AccountID == accountID,
+		URL:   fmt.Sprintf("/kifu/view?id=%s", t.ID),
 	}
 	return resp
 }
@@ -0,0 +1,255 @@
+// service/model/KifuRevision.go
+// 棋譜の変更履歴（リビジョン）のデータモデルを定義
+
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+type KifuRevisionAction string
+
+const (
+	KIFU_REVISION_BASELINE     KifuRevisionAction = "baseline"     // 履歴の記録開始前の状態
+	KIFU_REVISION_CREATE       KifuRevisionAction = "create"       // 棋譜の新規作成
+	KIFU_REVISION_FORK         KifuRevisionAction = "fork"         // 他の棋譜からのフォーク
+	KIFU_REVISION_UPDATE_INFO  KifuRevisionAction = "update_info"  // 棋譜情報・タグの更新
+	KIFU_REVISION_UPDATE_MOVES KifuRevisionAction = "update_moves" // 指し手の更新
+	KIFU_REVISION_EDIT_BRANCH  KifuRevisionAction = "edit_branch"  // 分岐単位の指し手の編集
+	KIFU_REVISION_RESTORE      KifuRevisionAction = "restore"      // 過去のリビジョンの復元
+)
+
+// table: `kifu_revisions`（追記のみ）
+type KifuRevision struct {
+	ID         string             `db:"id"`
+	KifuID     string             `db:"kifu_id"`
+	Version    int64              `db:"version"`    // この変更後の棋譜の版
+	AccountID  string             `db:"account_id"` // 変更したアカウント
+	Action     KifuRevisionAction `db:"action"`
+	Snapshot   string             `db:"snapshot"`    // 変更後の棋譜全体（KifuSnapshotのJSON）
+	RestoredOf *string            `db:"restored_of"` // 復元元のリビジョンID
+	CreatedAt  time.Time          `db:"created_at"`
+}
+
+// ------------------------------------------------------------
+// リビジョンに保存する棋譜全体のスナップショット
+
+type KifuSnapshot struct {
+	Title           string                `json:"title"`
+	IsPublic        bool                  `json:"is_public"`
+	BlackPlayer     *string               `json:"black_player,omitempty"`
+	WhitePlayer     *string               `json:"white_player,omitempty"`
+	StartedAt       *time.Time            `json:"started_at,omitempty"`
+	TimeRule        *TimeRuleString       `json:"time_rule,omitempty"`
+	InitialPosition *SFEN                 `json:"initial_position,omitempty"`
+	Options         []*KifuSnapshotOption `json:"options"`
+	Tags            []string              `json:"tags"`
+	Branches        []*KifuSnapshotBranch `json:"branches"` // 1つめがメインブランチで、必ず親ブランチが先にある
+}
+
+type KifuSnapshotOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type KifuSnapshotBranch struct {
+	ID            string              `json:"id"`
+	RootBranchID  *string             `json:"root_branch_id,omitempty"`
+	RootNumber    *int64              `json:"root_number,omitempty"`
+	EndingNumber  *int64              `json:"ending_number,omitempty"`
+	EndingType    *EndingType         `json:"ending_type,omitempty"`
+	EndingComment *string             `json:"ending_comment,omitempty"`
+	SortOrder     int64               `json:"sort_order,omitempty"`
+	Moves         []*KifuSnapshotMove `json:"moves"`
+}
+
+type KifuSnapshotMove struct {
+	Number      int64                     `json:"number"`
+	Piece       PieceType                 `json:"piece"`
+	FromPlace   PiecePlace                `json:"from_place"`
+	ToPlace     PiecePlace                `json:"to_place"`
+	Comment     *string                   `json:"comment,omitempty"`
+	TimeSpentMs *int64                    `json:"time_spent_ms,omitempty"`
+	Annotations []*KifuAnnotationResponse `json:"annotations,omitempty"`
+}
+
+func NewKifuSnapshot(kifu *Kifu, options []*KifuOption, tags []*KifuTag, branches []*KifuBranchWithMoves) *KifuSnapshot {
+	snapshot := &KifuSnapshot{
+		Title:           kifu.Title,
+		IsPublic:        kifu.IsPublic,
+		BlackPlayer:     kifu.BlackPlayer,
+		WhitePlayer:     kifu.WhitePlayer,
+		StartedAt:       kifu.StartedAt,
+		TimeRule:        kifu.TimeRule,
+		InitialPosition: kifu.InitialPosition,
+		Options:         make([]*KifuSnapshotOption, 0, len(options)),
+		Tags:            make([]string, 0, len(tags)),
+		Branches:        make([]*KifuSnapshotBranch, 0, len(branches)),
+	}
+	for _, option := range options {
+		snapshot.Options = append(snapshot.Options, &KifuSnapshotOption{Name: option.Name, Value: option.Value})
+	}
+	for _, tag := range tags {
+		snapshot.Tags = append(snapshot.Tags, tag.Name)
+	}
+	for _, branch := range sortBranchesParentFirst(branches) {
+		snapshotBranch := &KifuSnapshotBranch{
+			ID:            branch.ID,
+			RootBranchID:  branch.RootBranchID,
+			RootNumber:    branch.RootNumber,
+			EndingNumber:  branch.EndingNumber,
+			EndingType:    branch.EndingType,
+			EndingComment: branch.EndingComment,
+			SortOrder:     branch.SortOrder,
+			Moves:         make([]*KifuSnapshotMove, 0, len(branch.Moves)),
+		}
+		for _, move := range branch.Moves {
+			snapshotBranch.Moves = append(snapshotBranch.Moves, &KifuSnapshotMove{
+				Number:      move.Number,
+				Piece:       move.Piece,
+				FromPlace:   move.FromPlace,
+				ToPlace:     move.ToPlace,
+				Comment:     move.Comment,
+				TimeSpentMs: move.TimeSpentMs,
+				Annotations: annotationsToResponse(move.Annotations),
+			})
+		}
+		snapshot.Branches = append(snapshot.Branches, snapshotBranch)
+	}
+	return snapshot
+}
+
+// 親ブランチが先になるように並べ替える（メインラインが先頭、同じ手からの分岐は兄弟間の順序）
+func sortBranchesParentFirst(branches []*KifuBranchWithMoves) []*KifuBranchWithMoves {
+	branches = append([]*KifuBranchWithMoves{}, branches...)
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i].SortOrder < branches[j].SortOrder
+	})
+	result := make([]*KifuBranchWithMoves, 0, len(branches))
+	added := make(map[string]bool, len(branches))
+	for len(result) < len(branches) {
+		progress := false
+		for _, branch := range branches {
+			if added[branch.ID] {
+				continue
+			}
+			if branch.RootBranchID == nil || added[*branch.RootBranchID] {
+				result = append(result, branch)
+				added[branch.ID] = true
+				progress = true
+			}
+		}
+		if !progress { // 親が見つからないブランチは末尾に残す
+			for _, branch := range branches {
+				if !added[branch.ID] {
+					result = append(result, branch)
+					added[branch.ID] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (t *KifuSnapshot) ToJSON() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (t *KifuRevision) ParseSnapshot() (*KifuSnapshot, error) {
+	snapshot := &KifuSnapshot{}
+	if err := json.Unmarshal([]byte(t.Snapshot), snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// スナップショットを棋譜のデータモデルに戻す（BranchIDはスナップショット時のもの）
+func (t *KifuSnapshot) ToKifuData(kifu *Kifu) (*Kifu, []*KifuOption, []*KifuTag, []*KifuBranchWithMoves) {
+	restored := *kifu
+	restored.Title = t.Title
+	restored.IsPublic = t.IsPublic
+	restored.BlackPlayer = t.BlackPlayer
+	restored.WhitePlayer = t.WhitePlayer
+	restored.StartedAt = t.StartedAt
+	restored.TimeRule = t.TimeRule
+	restored.InitialPosition = t.InitialPosition
+
+	options := make([]*KifuOption, 0, len(t.Options))
+	for _, option := range t.Options {
+		options = append(options, &KifuOption{KifuID: kifu.ID, Name: option.Name, Value: option.Value})
+	}
+	tags := make([]*KifuTag, 0, len(t.Tags))
+	for _, name := range t.Tags {
+		tags = append(tags, &KifuTag{KifuID: kifu.ID, Name: name})
+	}
+	branches := make([]*KifuBranchWithMoves, 0, len(t.Branches))
+	for _, snapshotBranch := range t.Branches {
+		branch := &KifuBranchWithMoves{
+			KifuBranch: &KifuBranch{
+				ID:            snapshotBranch.ID,
+				KifuID:        kifu.ID,
+				RootBranchID:  snapshotBranch.RootBranchID,
+				RootNumber:    snapshotBranch.RootNumber,
+				EndingNumber:  snapshotBranch.EndingNumber,
+				EndingType:    snapshotBranch.EndingType,
+				EndingComment: snapshotBranch.EndingComment,
+				SortOrder:     snapshotBranch.SortOrder,
+			},
+			Moves: make([]*KifuMove, 0, len(snapshotBranch.Moves)),
+		}
+		for _, move := range snapshotBranch.Moves {
+			kifuMove := &KifuMove{
+				BranchID:    snapshotBranch.ID,
+				Number:      move.Number,
+				Piece:       move.Piece,
+				FromPlace:   move.FromPlace,
+				ToPlace:     move.ToPlace,
+				Comment:     move.Comment,
+				TimeSpentMs: move.TimeSpentMs,
+			}
+			for _, annotation := range move.Annotations {
+				kifuMove.Annotations = append(kifuMove.Annotations, &KifuAnnotation{
+					BranchID:  snapshotBranch.ID,
+					Number:    move.Number,
+					Type:      annotation.Type,
+					FromPlace: annotation.FromPlace,
+					ToPlace:   annotation.ToPlace,
+					Mark:      annotation.Mark,
+					Color:     annotation.Color,
+				})
+			}
+			branch.Moves = append(branch.Moves, kifuMove)
+		}
+		branches = append(branches, branch)
+	}
+	return &restored, options, tags, branches
+}
+
+// ------------------------------------------------------------
+
+type KifuRevisionResponse struct {
+	ID         string             `json:"id"`
+	Version    int64              `json:"version"`
+	Action     KifuRevisionAction `json:"action"`
+	Account    *AccountResponse   `json:"account"` // 変更したアカウント
+	RestoredOf *string            `json:"restored_of,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+func (t *KifuRevision) ToResponse(account *Account) *KifuRevisionResponse {
+	resp := &KifuRevisionResponse{
+		ID:         t.ID,
+		Version:    t.Version,
+		Action:     t.Action,
+		Account:    account.ToResponse(),
+		RestoredOf: t.RestoredOf,
+		CreatedAt:  t.CreatedAt,
+	}
+	return resp
+}
@@ -0,0 +1,104 @@
+// service/model/KifuSearch.go
+// 棋譜検索の並び順と、キーワード検索の結果に添える抜粋
+
+package model
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 棋譜一覧の並び順
+type KifuSort string
+
+const (
+	KIFU_SORT_UPDATED   KifuSort = "updated"   // 更新日時の新しい順
+	KIFU_SORT_LIKED     KifuSort = "liked"     // いいね数の多い順
+	KIFU_SORT_COMMENTED KifuSort = "commented" // コメント数の多い順
+	KIFU_SORT_STARTED   KifuSort = "started"   // 対局日時の新しい順（対局日時の無い棋譜は最後）
+	KIFU_SORT_TITLE     KifuSort = "title"     // タイトル順
+	KIFU_SORT_RELEVANCE KifuSort = "relevance" // キーワードとの一致の度合いの順（キーワード検索のみ）
+)
+
+// table: `kifu_search_documents`
+type KifuSearchDocument struct {
+	KifuID   string `db:"kifu_id"`
+	Title    string `db:"title"`
+	Players  string `db:"players"`
+	Options  string `db:"options"`
+	Tags     string `db:"tags"`
+	Comments string `db:"comments"`
+}
+
+const (
+	kifuSearchSnippetBefore = 20 // 最初の一致箇所より前に含める文字数
+	kifuSearchSnippetLength = 80 // 抜粋の最大文字数
+)
+
+// キーワードのいずれかに一致する正規表現（大文字・小文字を区別せず、長いキーワードを優先）
+func keywordsPattern(keywords []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword != "" {
+			quoted = append(quoted, regexp.QuoteMeta(keyword))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// キーワードに一致した箇所の抜粋（HTMLエスケープ済みで、一致箇所を<mark>で囲む）
+// 一覧に表示されないコメント・棋譜情報を優先し、一致が無ければnil
+func (t *KifuSearchDocument) Snippet(keywords []string) *string {
+	pattern := keywordsPattern(keywords)
+	if pattern == nil {
+		return nil
+	}
+	for _, text := range []string{t.Comments, t.Options, t.Tags, t.Players, t.Title} {
+		loc := pattern.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		snippet := highlightKeywords(excerpt(text, loc[0]), pattern)
+		return &snippet
+	}
+	return nil
+}
+
+// textのバイト位置posの前後を切り出す（改行は空白にする）
+func excerpt(text string, pos int) string {
+	runes := []rune(text)
+	start := len([]rune(text[:pos])) - kifuSearchSnippetBefore
+	if start < 0 {
+		start = 0
+	}
+	end := start + kifuSearchSnippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+	s := strings.ReplaceAll(string(runes[start:end]), "\n", " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+func highlightKeywords(s string, pattern *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(s, -1) {
+		b.WriteString(html.EscapeString(s[last:loc[0]]))
+		b.WriteString("<mark>" + html.EscapeString(s[loc[0]:loc[1]]) + "</mark>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	return b.String()
+}
@@ -0,0 +1,169 @@
+// service/model/KifuSimilar.go
+// 似ている棋譜（共通のタグ・序盤の局面・対局者・持ち時間から類似度を計算する）
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const SimilarKifuOpeningMoves = 30 // 序盤の局面として比較する手数
+
+// 類似度の重み
+const (
+	similarWeightTag      = 3.0  // 共通のタグ1つあたり
+	similarWeightPlayer   = 4.0  // 同じ対局者1人あたり
+	similarWeightPosition = 0.25 // 共通の序盤の局面1つあたり（開始局面は除く）
+	similarWeightTimeRule = 2.0  // 持ち時間が同じ
+)
+
+// 類似度の計算に使う棋譜の特徴
+type KifuFeatures struct {
+	Kifu         *Kifu
+	TagNames     map[string]string // タグのキー -> タグ名
+	Players      []*KifuPlayer     // 先手・後手の対局者
+	PositionKeys map[string]int64  // 序盤の局面のキー -> 手数（同一局面は最初の手数）
+}
+
+func NewKifuFeatures(kifu *Kifu, tags []*KifuTag, players []*KifuPlayer) *KifuFeatures {
+	features := &KifuFeatures{
+		Kifu:         kifu,
+		TagNames:     make(map[string]string, len(tags)),
+		Players:      players,
+		PositionKeys: map[string]int64{},
+	}
+	for _, tag := range tags {
+		features.TagNames[TagKey(tag.Name)] = tag.Name
+	}
+	return features
+}
+
+// 同じ対局者か（両方の対局者が確定していれば対局者ID、それ以外は名前のキーで比較する）
+func (t *KifuFeatures) hasPlayer(player *KifuPlayer) bool {
+	for _, other := range t.Players {
+		if player.PlayerID != nil && other.PlayerID != nil {
+			if *player.PlayerID == *other.PlayerID {
+				return true
+			}
+		} else if player.NameKey != "" && player.NameKey == other.NameKey {
+			return true
+		}
+	}
+	return false
+}
+
+// メインラインの序盤の指し手を再生して局面のキーを記録する（開始局面は含めない）
+// 途中に不正な指し手があれば、その手の前までの局面を記録する
+func (t *KifuFeatures) SetOpeningMoves(moves []*KifuMove) error {
+	position, err := NewBoardPosition(t.Kifu.InitialPosition)
+	if err != nil {
+		return err
+	}
+	for _, move := range moves {
+		if move.Number > SimilarKifuOpeningMoves {
+			break
+		}
+		if err := position.Move(move); err != nil {
+			break
+		}
+		key, err := position.Key()
+		if err != nil {
+			return err
+		}
+		if _, ok := t.PositionKeys[key]; !ok {
+			t.PositionKeys[key] = move.Number
+		}
+	}
+	return nil
+}
+
+// 2つの棋譜の共通点と類似度
+type KifuSimilarity struct {
+	KifuID         string
+	Score          float64
+	Tags           []string        // 共通のタグ
+	Players        []string        // 同じ対局者（基準の棋譜上の名前）
+	PositionCount  int             // 共通の序盤の局面の数
+	PositionNumber int64           // 共通の局面のうち、基準の棋譜で最も後の手数
+	TimeRule       *TimeRuleString // 同じ持ち時間
+}
+
+// 基準の棋譜（t）と他の棋譜の類似度
+func (t *KifuFeatures) Similarity(other *KifuFeatures) *KifuSimilarity {
+	similarity := &KifuSimilarity{KifuID: other.Kifu.ID}
+	for key, name := range t.TagNames {
+		if _, ok := other.TagNames[key]; ok {
+			similarity.Tags = append(similarity.Tags, name)
+		}
+	}
+	for _, player := range t.Players {
+		if other.hasPlayer(player) {
+			similarity.Players = append(similarity.Players, player.Name)
+		}
+	}
+	for key, number := range t.PositionKeys {
+		if _, ok := other.PositionKeys[key]; ok {
+			similarity.PositionCount++
+			similarity.PositionNumber = max(similarity.PositionNumber, number)
+		}
+	}
+	if t.Kifu.TimeRule != nil && other.Kifu.TimeRule != nil && *t.Kifu.TimeRule == *other.Kifu.TimeRule {
+		similarity.TimeRule = t.Kifu.TimeRule
+	}
+	sort.Strings(similarity.Tags)
+	sort.Strings(similarity.Players)
+
+	similarity.Score = similarWeightTag*float64(len(similarity.Tags)) +
+		similarWeightPlayer*float64(len(similarity.Players)) +
+		similarWeightPosition*float64(similarity.PositionCount)
+	if similarity.TimeRule != nil {
+		similarity.Score += similarWeightTimeRule
+	}
+	return similarity
+}
+
+// 類似度の高い順（同じ類似度は更新日時の新しい順）に並べる
+func SortKifuSimilarities(similarities []*KifuSimilarity, kifus map[string]*Kifu) {
+	sort.SliceStable(similarities, func(i, j int) bool {
+		if similarities[i].Score != similarities[j].Score {
+			return similarities[i].Score > similarities[j].Score
+		}
+		return kifus[similarities[i].KifuID].UpdatedAt.After(kifus[similarities[j].KifuID].UpdatedAt)
+	})
+}
+
+// 共通点の説明
+func (t *KifuSimilarity) Reasons() []string {
+	reasons := []string{}
+	if len(t.Tags) > 0 {
+		reasons = append(reasons, fmt.Sprintf("共通のタグ（%s）", strings.Join(t.Tags, "、")))
+	}
+	if t.PositionCount > 0 {
+		reasons = append(reasons, fmt.Sprintf("序盤の局面が共通（%d手目まで）", t.PositionNumber))
+	}
+	if len(t.Players) > 0 {
+		reasons = append(reasons, fmt.Sprintf("同じ対局者（%s）", strings.Join(t.Players, "、")))
+	}
+	if t.TimeRule != nil {
+		reasons = append(reasons, fmt.Sprintf("同じ持ち時間（%s）", t.TimeRule.Text()))
+	}
+	return reasons
+}
+
+// ------------------------------------------------------------
+
+type SimilarKifuResponse struct {
+	Kifu    *KifuSummaryResponse `json:"kifu"`
+	Score   float64              `json:"score"`   // 類似度（大きいほど似ている）
+	Reasons []string             `json:"reasons"` // 共通点の説明
+}
+
+func (t *KifuSimilarity) ToResponse(kifu *KifuSummaryResponse) *SimilarKifuResponse {
+	return &SimilarKifuResponse{
+		Kifu:    kifu,
+		Score:   t.Score,
+		Reasons: t.Reasons(),
+	}
+}
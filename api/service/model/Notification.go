@@ -0,0 +1,58 @@
+// service/model/Notification.go
+// アカウントへの通知
+
+package model
+
+import "time"
+
+type NotificationType string
+
+const (
+	NOTIFICATION_SAVED_SEARCH NotificationType = "saved_search" // 保存した検索条件に一致する棋譜が公開された
+)
+
+// table: `notifications`
+type Notification struct {
+	ID            string           `db:"id"`
+	AccountID     string           `db:"account_id"`
+	Type          NotificationType `db:"type"`
+	SavedSearchID *string          `db:"saved_search_id"` // 一致した検索条件
+	KifuID        string           `db:"kifu_id"`
+	CreatedAt     time.Time        `db:"created_at"`
+	ReadAt        *time.Time       `db:"read_at"`    // 既読にした日時（未読はNULL）
+	EmailedAt     *time.Time       `db:"emailed_at"` // メールで知らせた日時（未送信はNULL）
+}
+
+// メールでまとめて知らせる通知（宛先と棋譜・検索条件の名前を含む）
+type NotificationDigestItem struct {
+	NotificationID  string
+	AccountID       string
+	Email           string
+	SavedSearchID   string
+	SavedSearchName string
+	KifuID          string
+	KifuTitle       string
+}
+
+type NotificationResponse struct {
+	ID          string               `json:"id"`
+	Type        NotificationType     `json:"type"`
+	SavedSearch *SavedSearchResponse `json:"saved_search"` // 一致した検索条件
+	Kifu        *KifuSummaryResponse `json:"kifu"`
+	CreatedAt   time.Time            `json:"created_at"`
+	ReadAt      *time.Time           `json:"read_at"` // 未読はNULL
+}
+
+func (t *Notification) ToResponse(savedSearch *SavedSearch, kifu *KifuSummaryResponse) *NotificationResponse {
+	resp := &NotificationResponse{
+		ID:        t.ID,
+		Type:      t.Type,
+		Kifu:      kifu,
+		CreatedAt: t.CreatedAt,
+		ReadAt:    t.ReadAt,
+	}
+	if savedSearch != nil {
+		resp.SavedSearch = savedSearch.ToResponse()
+	}
+	return resp
+}
@@ -0,0 +1,410 @@
+// service/model/Player.go
+// 対局者（先手・後手の名前の表記の揺れをまとめた人物）と、棋譜の対局者・勝敗
+
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// table: `players`
+type Player struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"` // 表示名
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// table: `player_aliases`
+type PlayerAlias struct {
+	AliasKey string `db:"alias_key"` // 別名のキー（PlayerNameKey）
+	Alias    string `db:"alias"`     // 登録時の別名の表記
+	PlayerID string `db:"player_id"`
+}
+
+// 先手・後手
+type PlayerSide int64
+
+const (
+	SIDE_BLACK PlayerSide = 0x0 + iota // 先手（下手）
+	SIDE_WHITE                         // 後手（上手）
+)
+
+var PlayerSideName = map[PlayerSide]string{
+	SIDE_BLACK: "black",
+	SIDE_WHITE: "white",
+}
+
+// 対局者から見た勝敗
+type GameResult int64
+
+const (
+	RESULT_LOSS GameResult = -1
+	RESULT_DRAW GameResult = 0
+	RESULT_WIN  GameResult = 1
+)
+
+var GameResultName = map[GameResult]string{
+	RESULT_LOSS: "loss",
+	RESULT_DRAW: "draw",
+	RESULT_WIN:  "win",
+}
+
+// table: `kifu_players`
+// 候補（CandidateIDs）があって対局者が決まっていないものは、管理者の確認待ち
+type KifuPlayer struct {
+	KifuID       string      `db:"kifu_id"`
+	Side         PlayerSide  `db:"side"`
+	Name         string      `db:"name"`          // 棋譜上の名前
+	NameKey      string      `db:"name_key"`      // 名前のキー（PlayerNameKey）
+	PlayerID     *string     `db:"player_id"`     // 対局者（未確定はNULL）
+	Result       *GameResult `db:"result"`        // 勝敗（不明はNULL）
+	CandidateIDs []string    `db:"candidate_ids"` // 確認待ちの候補の対局者ID（カンマ区切りで保存）
+	MoveCount    int64       `db:"move_count"`    // メインラインの手数
+}
+
+// 確認待ちの対応（名前のキーごと）
+type PlayerReview struct {
+	NameKey      string
+	Name         string
+	KifuCount    int64
+	CandidateIDs []string
+}
+
+// 成績の集計
+type PlayerRecord struct {
+	Games     int64
+	Wins      int64
+	Losses    int64
+	Draws     int64
+	MoveTotal int64 // 手数の合計（指し手の無い棋譜を除く）
+	MoveGames int64 // 手数を合計した対局数
+}
+
+// 対局を1つ加える
+func (t *PlayerRecord) Add(result *GameResult, moveCount int64) {
+	t.Games++
+	if result != nil {
+		switch *result {
+		case RESULT_WIN:
+			t.Wins++
+		case RESULT_LOSS:
+			t.Losses++
+		case RESULT_DRAW:
+			t.Draws++
+		}
+	}
+	if moveCount > 0 {
+		t.MoveTotal += moveCount
+		t.MoveGames++
+	}
+}
+
+func (t *PlayerRecord) Merge(other *PlayerRecord) {
+	t.Games += other.Games
+	t.Wins += other.Wins
+	t.Losses += other.Losses
+	t.Draws += other.Draws
+	t.MoveTotal += other.MoveTotal
+	t.MoveGames += other.MoveGames
+}
+
+// table: `player_stats`
+// 公開棋譜での先手・後手ごとの成績（棋譜の対局者の対応が変わると集計し直す）
+type PlayerStats struct {
+	PlayerID string     `db:"player_id"`
+	Side     PlayerSide `db:"side"`
+	PlayerRecord
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// 戦型（タグ）ごとの成績
+type PlayerOpeningRecord struct {
+	Name string
+	PlayerRecord
+}
+
+// 相手ごとの成績（対局者が確定していない相手は棋譜上の名前ごと）
+type PlayerOpponentRecord struct {
+	PlayerID      *string
+	Name          string
+	LastStartedAt *time.Time
+	PlayerRecord
+}
+
+// ------------------------------------------------------------
+// 名前の照合
+
+// 名前の後ろに付く段級位・タイトル・敬称など（「藤井七段」「羽生善治 九段」「藤井竜王・名人」）
+var playerTitlePattern = regexp.MustCompile(
+	`(?:\([^)]*\)|女流|永世|名誉|十[七八九]世|[一二三四五六七八九十初0-9]+(?:段|級)|[一二三四五六七八]冠|` +
+		`竜王|名人|王位|王座|棋王|王将|棋聖|叡王|女王|清麗|白玲|倉敷藤花|アマ|プロ|さん|氏|先生|・| )+$`,
+)
+
+// 名前の表記を正規化する（幅の違いを統一し、連続する空白を1つにする）
+func NormalizePlayerName(name string) string {
+	return NormalizeTagName(name)
+}
+
+// 同一の名前とみなすためのキー（正規化した表記から、空白・英字の大小・ひらがなとカタカナの違いを除く）
+func PlayerNameKey(name string) string {
+	return strings.ReplaceAll(TagKey(name), " ", "")
+}
+
+// 段級位・タイトル・敬称を除いた名前（除くと空になる場合はそのまま）
+func StripPlayerTitle(name string) string {
+	name = NormalizePlayerName(name)
+	if stripped := strings.TrimSpace(playerTitlePattern.ReplaceAllString(name, "")); stripped != "" {
+		return stripped
+	}
+	return name
+}
+
+// 名前と、段級位・タイトル・敬称を除いた名前のキー（同じ場合は1つ）
+func PlayerNameKeys(name string) []string {
+	key := PlayerNameKey(name)
+	baseKey := PlayerNameKey(StripPlayerTitle(name))
+	if baseKey == key {
+		return []string{key}
+	}
+	return []string{key, baseKey}
+}
+
+// ------------------------------------------------------------
+// 勝敗の判定
+
+// 棋譜の対局者と、メインラインの終局の情報
+type KifuGameRecord struct {
+	KifuID          string
+	IsPublic        bool
+	BlackPlayer     *string
+	WhitePlayer     *string
+	InitialPosition *SFEN       // 開始局面（平手初期局面はNULL）
+	EndingType      *EndingType // メインラインの終局の種類
+	EndingNumber    *int64      // メインラインの最終手の次の番号
+}
+
+// 先手から見た勝敗（終局の種類から判定できない場合はnil）
+func (t *KifuGameRecord) BlackResult() *GameResult {
+	if t.EndingType == nil {
+		return nil
+	}
+	var result GameResult
+	switch *t.EndingType {
+	case ENDING_SENNICHITE, ENDING_JISHOGI, ENDING_HIKIWAKE, ENDING_MAX_MOVES:
+		result = RESULT_DRAW
+	case ENDING_BLACK_ILLEGAL_ACTION:
+		result = RESULT_LOSS
+	case ENDING_WHITE_ILLEGAL_ACTION:
+		result = RESULT_WIN
+	case ENDING_TORYO, ENDING_TIME_UP, ENDING_ILLEGAL_MOVE, ENDING_TSUMI:
+		result = RESULT_LOSS // 手番側の負け
+		if !t.blackToMoveAtEnding() {
+			result = RESULT_WIN
+		}
+	case ENDING_KACHI:
+		result = RESULT_WIN // 手番側の勝ち
+		if !t.blackToMoveAtEnding() {
+			result = RESULT_LOSS
+		}
+	default:
+		return nil // 中断・待った・不詰など
+	}
+	return &result
+}
+
+// メインラインの手数
+func (t *KifuGameRecord) MoveCount() int64 {
+	if t.EndingNumber == nil {
+		return 0
+	}
+	return *t.EndingNumber - 1
+}
+
+// 指定した側から見た勝敗
+func (t *KifuGameRecord) Result(side PlayerSide) *GameResult {
+	result := t.BlackResult()
+	if result == nil || side == SIDE_BLACK {
+		return result
+	}
+	opposite := -*result
+	return &opposite
+}
+
+// 終局時の手番が先手か
+func (t *KifuGameRecord) blackToMoveAtEnding() bool {
+	number := int64(1)
+	if t.EndingNumber != nil {
+		number = *t.EndingNumber
+	}
+	return MoveSide(t.InitialPosition, number) == SIDE_BLACK
+}
+
+// 指し手の番号の手番（番号は開始局面の手番から1と数える、開始局面がNULLは平手）
+func MoveSide(initialPosition *SFEN, number int64) PlayerSide {
+	firstMoverIsBlack := initialPosition == nil || !strings.Contains(string(*initialPosition), " w ")
+	if (number%2 == 1) == firstMoverIsBlack {
+		return SIDE_BLACK
+	}
+	return SIDE_WHITE
+}
+
+// ------------------------------------------------------------
+// レスポンス
+
+type PlayerResponse struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"` // 別名（棋譜上の表記）
+}
+
+func (t *Player) ToResponse(aliases []*PlayerAlias) *PlayerResponse {
+	names := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		names = append(names, alias.Alias)
+	}
+	return &PlayerResponse{
+		ID:      t.ID,
+		Name:    t.Name,
+		Aliases: names,
+	}
+}
+
+// 成績
+type PlayerRecordResponse struct {
+	Games        int64    `json:"games"`
+	Wins         int64    `json:"wins"`
+	Losses       int64    `json:"losses"`
+	Draws        int64    `json:"draws"`         // 千日手・持将棋など（勝敗が不明な対局は含めない）
+	WinRate      *float64 `json:"win_rate"`      // 勝数 / (勝数 + 敗数)（勝敗の無い場合はNULL）
+	AverageMoves *float64 `json:"average_moves"` // 平均手数（指し手の無い棋譜を除く、無い場合はNULL）
+}
+
+func (t *PlayerRecord) ToResponse() *PlayerRecordResponse {
+	resp := &PlayerRecordResponse{
+		Games:  t.Games,
+		Wins:   t.Wins,
+		Losses: t.Losses,
+		Draws:  t.Draws,
+	}
+	if t.Wins+t.Losses > 0 {
+		winRate := float64(t.Wins) / float64(t.Wins+t.Losses)
+		resp.WinRate = &winRate
+	}
+	if t.MoveGames > 0 {
+		averageMoves := float64(t.MoveTotal) / float64(t.MoveGames)
+		resp.AverageMoves = &averageMoves
+	}
+	return resp
+}
+
+type PlayerRefResponse struct {
+	PlayerID *string `json:"player_id"` // 対局者が確定していない場合はNULL
+	Name     string  `json:"name"`      // 棋譜上の名前
+}
+
+type PlayerGameResponse struct {
+	Kifu     *KifuSummaryResponse `json:"kifu"`
+	Side     string               `json:"side"`     // black / white
+	Result   *string              `json:"result"`   // win / loss / draw（不明はNULL）
+	Opponent *PlayerRefResponse   `json:"opponent"` // 相手（名前が無い場合はNULL）
+}
+
+func (t *KifuPlayer) ToGameResponse(kifu *KifuSummaryResponse, opponent *KifuPlayer) *PlayerGameResponse {
+	resp := &PlayerGameResponse{
+		Kifu: kifu,
+		Side: PlayerSideName[t.Side],
+	}
+	if t.Result != nil {
+		result := GameResultName[*t.Result]
+		resp.Result = &result
+	}
+	if opponent != nil {
+		resp.Opponent = &PlayerRefResponse{
+			PlayerID: opponent.PlayerID,
+			Name:     opponent.Name,
+		}
+	}
+	return resp
+}
+
+type PlayerDetailResponse struct {
+	*PlayerResponse
+	Total          *PlayerRecordResponse `json:"total"`            // 通算の成績
+	Black          *PlayerRecordResponse `json:"black"`            // 先手番の成績
+	White          *PlayerRecordResponse `json:"white"`            // 後手番の成績
+	FirstStartedAt *time.Time            `json:"first_started_at"` // 最も古い対局日時
+	LastStartedAt  *time.Time            `json:"last_started_at"`  // 最も新しい対局日時
+	Openings       []*TagCountResponse   `json:"openings"`         // 対局した棋譜のタグ（戦型）の多い順
+	Games          []*PlayerGameResponse `json:"games"`            // 対局（対局日時の新しい順）
+}
+
+// 先手・後手ごとの成績から、通算・先手番・後手番の成績を作る
+func NewPlayerRecordResponses(records map[PlayerSide]*PlayerRecord) (total, black, white *PlayerRecordResponse) {
+	sum := &PlayerRecord{}
+	sides := map[PlayerSide]*PlayerRecordResponse{}
+	for _, side := range []PlayerSide{SIDE_BLACK, SIDE_WHITE} {
+		record, ok := records[side]
+		if !ok {
+			record = &PlayerRecord{}
+		}
+		sum.Merge(record)
+		sides[side] = record.ToResponse()
+	}
+	return sum.ToResponse(), sides[SIDE_BLACK], sides[SIDE_WHITE]
+}
+
+// 対局者の一覧の要素（公開棋譜での成績）
+type PlayerSummaryResponse struct {
+	*PlayerResponse
+	Total *PlayerRecordResponse `json:"total"`
+	Black *PlayerRecordResponse `json:"black"`
+	White *PlayerRecordResponse `json:"white"`
+}
+
+type PlayerOpeningResponse struct {
+	Name   string                `json:"name"` // タグ名
+	Record *PlayerRecordResponse `json:"record"`
+}
+
+func (t *PlayerOpeningRecord) ToResponse() *PlayerOpeningResponse {
+	return &PlayerOpeningResponse{
+		Name:   t.Name,
+		Record: t.PlayerRecord.ToResponse(),
+	}
+}
+
+// 相手との対戦成績
+type PlayerOpponentResponse struct {
+	PlayerID      *string               `json:"player_id"` // 対局者が確定していない場合はNULL
+	Name          string                `json:"name"`
+	LastStartedAt *time.Time            `json:"last_started_at"` // 最も新しい対局日時
+	Record        *PlayerRecordResponse `json:"record"`
+}
+
+func (t *PlayerOpponentRecord) ToResponse() *PlayerOpponentResponse {
+	return &PlayerOpponentResponse{
+		PlayerID:      t.PlayerID,
+		Name:          t.Name,
+		LastStartedAt: t.LastStartedAt,
+		Record:        t.PlayerRecord.ToResponse(),
+	}
+}
+
+// 対局者の統計（公開棋譜のうち、条件に一致する対局）
+type PlayerStatsResponse struct {
+	PlayerID  string                    `json:"player_id"`
+	Total     *PlayerRecordResponse     `json:"total"`
+	Black     *PlayerRecordResponse     `json:"black"`     // 先手番の成績
+	White     *PlayerRecordResponse     `json:"white"`     // 後手番の成績
+	Openings  []*PlayerOpeningResponse  `json:"openings"`  // 戦型（タグ）ごとの成績（対局数の多い順）
+	Opponents []*PlayerOpponentResponse `json:"opponents"` // よく対局する相手との対戦成績（対局数の多い順）
+}
+
+// 確認待ちの対局者（棋譜上の名前ごと）
+type PlayerReviewResponse struct {
+	Name       string            `json:"name"`
+	KifuCount  int64             `json:"kifu_count"` // この名前の確認待ちの棋譜の数
+	Candidates []*PlayerResponse `json:"candidates"` // 候補の対局者
+}
@@ -0,0 +1,51 @@
+// service/model/SavedSearch.go
+// 保存した検索条件（新しく公開された棋譜が一致した場合に通知する）
+
+package model
+
+import "time"
+
+// table: `saved_searches`
+type SavedSearch struct {
+	ID          string    `db:"id"`
+	AccountID   string    `db:"account_id"`
+	Name        string    `db:"name"`
+	Keyword     string    `db:"keyword"`       // タイトル・対局者・棋譜情報・タグ・コメント（空白区切りで全てを含む）
+	Tags        []string  `db:"tags"`          // タグ（JSONの配列で保存）
+	TagMatchAll bool      `db:"tag_match_all"` // trueは全てのタグを持つ、falseはいずれかのタグを持つ
+	Player      *string   `db:"player"`        // 先手・後手のいずれかの部分一致
+	Position    *SFEN     `db:"position"`      // メインラインに現れる局面
+	PositionKey *string   `db:"position_key"`  // 局面の同一判定用のキー
+	EmailDigest bool      `db:"email_digest"`  // 一致した棋譜をメールでまとめて知らせる
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type SavedSearchResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Keyword     string    `json:"keyword"`
+	Tags        []string  `json:"tags"`
+	TagMode     string    `json:"tag_mode"` // and / or
+	Player      *string   `json:"player"`
+	Position    *SFEN     `json:"position"`
+	EmailDigest bool      `json:"email_digest"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (t *SavedSearch) ToResponse() *SavedSearchResponse {
+	tagMode := "or"
+	if t.TagMatchAll {
+		tagMode = "and"
+	}
+	return &SavedSearchResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Keyword:     t.Keyword,
+		Tags:        t.Tags,
+		TagMode:     tagMode,
+		Player:      t.Player,
+		Position:    t.Position,
+		EmailDigest: t.EmailDigest,
+		CreatedAt:   t.CreatedAt,
+	}
+}
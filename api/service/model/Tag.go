@@ -0,0 +1,90 @@
+// service/model/Tag.go
+// タグの表記の正規化と、タグ一覧・別名のレスポンス
+
+package model
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const KifuTagMaxLength = 50 // kifu_tagsのタグ名の上限
+
+// タグ名の表記を正規化する
+// 全角英数・半角カナなどの幅の違いを統一し（NFKC）、連続する空白を1つにする
+func NormalizeTagName(name string) string {
+	return strings.Join(strings.Fields(norm.NFKC.String(name)), " ")
+}
+
+// 同一のタグとみなすためのキー（正規化した表記から、英字の大小・ひらがなとカタカナの違いを除く）
+func TagKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - ('ァ' - 'ぁ') // カタカナはひらがなにする
+		}
+		return unicode.ToLower(r)
+	}, NormalizeTagName(name))
+}
+
+// 初期登録するタグの別名（別名→タグ名）
+var DefaultTagAliases = map[string]string{
+	"4間飛車":        "四間飛車",
+	"3間飛車":        "三間飛車",
+	"shikenbisha": "四間飛車",
+	"sankenbisha": "三間飛車",
+	"nakabisha":   "中飛車",
+	"mukaibisha":  "向かい飛車",
+	"向飛車":         "向かい飛車",
+	"furibisha":   "振り飛車",
+	"振飛車":         "振り飛車",
+	"ibisha":      "居飛車",
+	"yagura":      "矢倉",
+	"kakugawari":  "角換わり",
+	"角換り":         "角換わり",
+	"aigakari":    "相掛かり",
+	"相掛り":         "相掛かり",
+	"yokofudori":  "横歩取り",
+	"anaguma":     "穴熊",
+}
+
+// table: `tag_aliases`
+type TagAlias struct {
+	AliasKey string `db:"alias_key"` // 別名のキー（TagKey）
+	Alias    string `db:"alias"`     // 登録時の別名の表記
+	Name     string `db:"name"`      // 置き換えるタグ名
+}
+
+// タグごとの公開棋譜の数
+type TagCount struct {
+	Name  string
+	Count int64
+}
+
+// ------------------------------------------------------------
+// レスポンス
+
+type TagCountResponse struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"` // このタグの付いた公開棋譜の数
+}
+
+func (t *TagCount) ToResponse() *TagCountResponse {
+	return &TagCountResponse{
+		Name:  t.Name,
+		Count: t.Count,
+	}
+}
+
+type TagAliasResponse struct {
+	Alias string `json:"alias"`
+	Name  string `json:"name"`
+}
+
+func (t *TagAlias) ToResponse() *TagAliasResponse {
+	return &TagAliasResponse{
+		Alias: t.Alias,
+		Name:  t.Name,
+	}
+}
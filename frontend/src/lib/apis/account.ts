@@ -63,17 +63,29 @@ export const getAccountById = async (accountId: string): Promise<ApiResult> => {
 export const updateAccountInfo = async (
   name: string,
   icon_id?: string,
-  introduction?: string
+  introduction?: string,
+  player_names?: string[]
 ): Promise<ApiResult> => {
   const params = {
     name: name,
     icon_id: icon_id || '',
     introduction: introduction || '',
+    player_names: player_names,
   };
   const result = await API.put('/api/account/info', params, true);
   return result;
 };
 
+export const getAccountStats = async (): Promise<ApiResult> => {
+  const result = await API.get('/api/account/stats', null, true);
+  if (!result.data) {
+    console.error('get account stats error: no data');
+    result.ok = false;
+    result.data = '統計の取得に失敗しました。';
+  }
+  return result;
+};
+
 export const deleteAccount = async (): Promise<ApiResult> => {
   const result = await API.delete('/api/account', null, true);
   return result;
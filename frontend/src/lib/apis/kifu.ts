@@ -1,18 +1,45 @@
 // src/lib/apis/kifu.ts
 
 import { API, type ApiResult } from '$lib/types/API';
-import type { KifuMove } from '$lib/types/Kifu';
+import type { KifuEnding, KifuMove } from '$lib/types/Kifu';
+
+// 他の画面で棋譜が更新されていた場合（409 Conflict）
+export const KIFU_CONFLICT_MESSAGE =
+  '他の画面で棋譜が更新されています。再読み込みしてから編集してください。';
+export const isVersionConflict = (result: ApiResult): boolean =>
+  typeof result.data === 'string' && result.data.startsWith('409');
+
+// 棋譜一覧の並び順（省略時はキーワード検索では一致の度合いの順、それ以外は更新日時の新しい順）
+export type KifuSort = 'updated' | 'liked' | 'commented' | 'started' | 'title' | 'relevance';
+
+// 棋譜の検索条件（未指定の条件では絞り込まない）
+export interface KifuSearchConditions {
+  keyword?: string; // タイトル・対局者・棋譜情報・タグ・コメント（空白区切りで全てを含む）
+  tags?: string[];
+  tag_mode?: 'and' | 'or';
+  player?: string; // 先手・後手のいずれか
+  black_player?: string;
+  white_player?: string;
+  started_from?: string; // YYYY-MM-DD
+  started_to?: string; // YYYY-MM-DD（この日を含む）
+  ending_type?: number;
+  handicap?: string; // 平手、香落ち、…
+  sort?: KifuSort;
+  cursor?: string; // 前のレスポンスのnext_cursor（指定した場合はpageより優先）
+}
 
 export const searchKifus = async (
   owner: string | null,
   page: number,
   page_size: number,
-  isLoggedIn: boolean
+  isLoggedIn: boolean,
+  conditions: KifuSearchConditions = {}
 ): Promise<ApiResult> => {
   const params = {
     owner: owner,
     page: page,
     page_size: page_size,
+    ...conditions,
   };
   const result = await API.get('/api/kifu', params, isLoggedIn);
   if (!result.data) {
@@ -52,6 +79,20 @@ export const getKifu = async (kifuId: string, withToken: boolean): Promise<ApiRe
   return result;
 };
 
+export const getSimilarKifus = async (
+  kifuId: string,
+  limit: number,
+  withToken: boolean
+): Promise<ApiResult> => {
+  const result = await API.get(`/api/kifu/${kifuId}/similar`, { limit }, withToken);
+  if (!result.data) {
+    console.error('get similar kifus error: no data');
+    result.ok = false;
+    result.data = '似ている棋譜の取得に失敗しました。';
+  }
+  return result;
+};
+
 export const deleteKifu = async (kifuId: string): Promise<ApiResult> => {
   const result = await API.delete(`/api/kifu/${kifuId}`, null, true);
   if (!result.ok) {
@@ -66,28 +107,39 @@ export const updateKifuInfo = async (
   title: string,
   isPublic: boolean,
   gameInfo: { [key: string]: string },
-  tags: string[]
+  tags: string[],
+  version: number
 ): Promise<ApiResult> => {
   const params = {
     title,
     is_public: isPublic,
     game_info: gameInfo,
     tags,
+    version,
   };
   const result = await API.put(`/api/kifu/${kifuId}`, params, true);
   if (!result.ok) {
     console.error('update kifu info error');
-    result.data = '棋譜情報の更新に失敗しました。';
+    result.data = isVersionConflict(result)
+      ? KIFU_CONFLICT_MESSAGE
+      : '棋譜情報の更新に失敗しました。';
   }
   return result;
 };
 
-export const updateKifuMoves = async (kifuId: string, moves: KifuMove[]): Promise<ApiResult> => {
-  const params = { moves };
+export const updateKifuMoves = async (
+  kifuId: string,
+  moves: KifuMove[],
+  ending: KifuEnding | null,
+  version: number
+): Promise<ApiResult> => {
+  const params = { moves, ending, version };
   const result = await API.put(`/api/kifu/${kifuId}/moves`, params, true);
   if (!result.ok) {
     console.error('update kifu moves error');
-    result.data = '棋譜の指し手の更新に失敗しました。';
+    result.data = isVersionConflict(result)
+      ? KIFU_CONFLICT_MESSAGE
+      : '棋譜の指し手の更新に失敗しました。';
   }
   return result;
 };
@@ -0,0 +1,65 @@
+// src/lib/apis/notification.ts
+
+import { API, type ApiResult } from '$lib/types/API';
+import type { SavedSearchConditions } from '$lib/types/Notification';
+
+export const getSavedSearches = async (): Promise<ApiResult> => {
+  const result = await API.get('/api/saved-searches', null, true);
+  if (!result.data) {
+    console.error('get saved searches error: no data');
+    result.ok = false;
+    result.data = '保存した検索条件の取得に失敗しました。';
+  }
+  return result;
+};
+
+export const createSavedSearch = async (conditions: SavedSearchConditions): Promise<ApiResult> => {
+  const result = await API.post('/api/saved-searches', conditions, true);
+  if (!result.ok) {
+    console.error('create saved search error');
+    result.data = '検索条件の保存に失敗しました。';
+  }
+  return result;
+};
+
+export const deleteSavedSearch = async (searchId: string): Promise<ApiResult> => {
+  const result = await API.delete(`/api/saved-searches/${searchId}`, null, true);
+  if (!result.ok) {
+    console.error('delete saved search error');
+    result.data = '検索条件の削除に失敗しました。';
+  }
+  return result;
+};
+
+export const getNotifications = async (
+  page: number,
+  page_size: number,
+  unread = false
+): Promise<ApiResult> => {
+  const params = { page, page_size, unread };
+  const result = await API.get('/api/notifications', params, true);
+  if (!result.data) {
+    console.error('get notifications error: no data');
+    result.ok = false;
+    result.data = '通知の取得に失敗しました。';
+  }
+  return result;
+};
+
+export const readNotification = async (notificationId: string): Promise<ApiResult> => {
+  const result = await API.put(`/api/notifications/${notificationId}/read`, null, true);
+  if (!result.ok) {
+    console.error('read notification error');
+    result.data = '通知の更新に失敗しました。';
+  }
+  return result;
+};
+
+export const readAllNotifications = async (): Promise<ApiResult> => {
+  const result = await API.put('/api/notifications/read', null, true);
+  if (!result.ok) {
+    console.error('read all notifications error');
+    result.data = '通知の更新に失敗しました。';
+  }
+  return result;
+};
@@ -0,0 +1,32 @@
+// src/lib/apis/player.ts
+
+import { API, type ApiResult } from '$lib/types/API';
+import type { PlayerStatsConditions } from '$lib/types/Player';
+
+export const getPlayer = async (
+  playerId: string,
+  page: number,
+  page_size: number
+): Promise<ApiResult> => {
+  const params = { page, page_size };
+  const result = await API.get(`/api/players/${playerId}`, params, false);
+  if (!result.data) {
+    console.error('get player error: no data');
+    result.ok = false;
+    result.data = '対局者の情報の取得に失敗しました。';
+  }
+  return result;
+};
+
+export const getPlayerStats = async (
+  playerId: string,
+  conditions: PlayerStatsConditions = {}
+): Promise<ApiResult> => {
+  const result = await API.get(`/api/players/${playerId}/stats`, conditions, false);
+  if (!result.data) {
+    console.error('get player stats error: no data');
+    result.ok = false;
+    result.data = '対局者の統計の取得に失敗しました。';
+  }
+  return result;
+};
@@ -0,0 +1,20 @@
+// src/lib/apis/tag.ts
+
+import { API, type ApiResult } from '$lib/types/API';
+
+export interface TagCount {
+  name: string;
+  count: number; // このタグの付いた公開棋譜の数
+}
+
+// 公開棋譜に付いているタグを多い順に取得（prefixで入力補完）
+export const getTags = async (prefix: string, limit: number = 10): Promise<ApiResult> => {
+  const params = { prefix, limit };
+  const result = await API.get('/api/tags', params, false);
+  if (!result.data) {
+    console.error('get tags error: no data');
+    result.ok = false;
+    result.data = 'タグの取得に失敗しました。';
+  }
+  return result;
+};
@@ -9,6 +9,7 @@ export interface PaginationResponse {
   page: number;
   page_size: number;
   max_page: number;
+  next_cursor?: string; // 続きを取得するためのカーソル
 }
 
 export interface ApiResult {
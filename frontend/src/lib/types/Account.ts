@@ -8,4 +8,6 @@ export interface Account {
   email?: string;
   created_at: Date;
   last_login_at: Date;
+  allow_fork?: boolean; // 他のアカウントによる棋譜のフォークを許可するか
+  player_names?: string[]; // 自分とみなす対局者名（本人のみ）
 }
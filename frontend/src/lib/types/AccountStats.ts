@@ -0,0 +1,36 @@
+// src/lib/types/AccountStats.ts
+
+import type { KifuSummary } from './Kifu';
+import type { PlayerRecord } from './Player';
+
+// 消費時間の傾向（自分の側の指し手）
+export interface TimeUse {
+  moves: number;
+  total_ms: number;
+  average_ms: number | null;
+  phases: {
+    name: 'opening' | 'middlegame' | 'endgame';
+    from_move: number;
+    to_move: number | null;
+    moves: number;
+    average_ms: number | null;
+  }[];
+  distribution: { from_ms: number; to_ms: number | null; moves: number }[];
+  longest: { kifu_id: string; number: number; time_spent_ms: number } | null;
+}
+
+// アカウントの統計（自分の棋譜、非公開を含む）
+export interface AccountStats {
+  kifu_count: number;
+  months: { month: string; count: number }[]; // YYYY-MM
+  results: {
+    player_names: string[];
+    total: PlayerRecord;
+    black: PlayerRecord;
+    white: PlayerRecord;
+  };
+  openings: { name: string; count: number }[];
+  time_use: TimeUse;
+  most_liked: KifuSummary[];
+  most_commented: KifuSummary[];
+}
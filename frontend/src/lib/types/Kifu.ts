@@ -21,6 +21,14 @@ export interface KifuSummary {
   tags: string[];
   like_count: number;
   comment_count: number;
+  version: number; // 更新時に指定する版
+  snippet?: string; // キーワード検索で一致した箇所の抜粋（エスケープ済み、一致箇所を<mark>で囲む）
+}
+
+export interface SimilarKifu {
+  kifu: KifuSummary;
+  score: number; // 類似度（大きいほど似ている）
+  reasons: string[]; // 共通点の説明
 }
 
 export interface KifuDetail {
@@ -34,11 +42,17 @@ export interface KifuDetail {
   game_info: { [key: string]: string };
   tags: string[];
   moves: KifuMove[];
+  ending?: KifuEnding | null; // メインラインの終局（指し手の無いラインの終局も含む）
   like_count: number;
   has_like: boolean;
+  version: number; // 更新時に指定する版
+  forked_from?: string | null; // フォーク元の棋譜ID
+  black_player_id?: string | null; // 先手の対局者ID（対局者が確定していない場合はnull）
+  white_player_id?: string | null; // 後手の対局者ID（対局者が確定していない場合はnull）
 }
 
 export interface KifuMove {
+  branch_id?: string; // この手を含むブランチのID（レスポンスのみ）
   number: number;
   piece: PieceType;
   from_place: number; // Use PIECE_PLACE_IN_HAND for moves from hand
@@ -47,6 +61,23 @@ export interface KifuMove {
   catch_piece?: PieceType;
   direction_sign?: string;
   variations?: KifuMove[][]; // Array of move lines
+  variation_endings?: (KifuEnding | null)[]; // 分岐ごとの終局（variationsと同じ順）
   comment?: string;
   time_spent_ms?: number;
+  annotations?: KifuAnnotation[]; // 矢印・マスの強調・評価記号
+}
+
+export interface KifuAnnotation {
+  type: 'mark' | 'arrow' | 'highlight';
+  from_place?: number; // 矢印の始点
+  to_place?: number; // 矢印の終点、強調するマス
+  mark?: '!!' | '!' | '!?' | '?!' | '?' | '??'; // 評価記号
+  color?: 'red' | 'blue' | 'green' | 'yellow';
+}
+
+export interface KifuEnding {
+  type: number; // 終局の種類（0=投了、1=中断、2=千日手 … 12=詰み）
+  name?: string; // CSA形式の表記（レスポンスのみ）
+  number?: number; // 終局の番号（ラインの最終手の次、レスポンスのみ）
+  comment?: string;
 }
@@ -0,0 +1,35 @@
+// src/lib/types/Notification.ts
+
+import type { KifuSummary } from './Kifu';
+
+// 保存した検索条件
+export interface SavedSearch {
+  id: string;
+  name: string;
+  keyword: string;
+  tags: string[];
+  tag_mode: 'and' | 'or';
+  player: string | null;
+  position: string | null; // メインラインに現れる局面（SFEN）
+  email_digest: boolean; // 一致した棋譜をメールでまとめて知らせる
+  created_at: string;
+}
+
+export interface SavedSearchConditions {
+  name: string;
+  keyword?: string;
+  tags?: string[];
+  tag_mode?: 'and' | 'or';
+  player?: string;
+  position?: string;
+  email_digest?: boolean;
+}
+
+export interface Notification {
+  id: string;
+  type: 'saved_search'; // 保存した検索条件に一致する棋譜が公開された
+  saved_search: SavedSearch | null;
+  kifu: KifuSummary | null;
+  created_at: string;
+  read_at: string | null; // 未読はnull
+}
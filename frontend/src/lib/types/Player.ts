@@ -0,0 +1,64 @@
+// src/lib/types/Player.ts
+
+import type { KifuSummary } from './Kifu';
+
+export interface Player {
+  id: string;
+  name: string;
+  aliases: string[]; // 別名（棋譜上の表記）
+}
+
+// 成績
+export interface PlayerRecord {
+  games: number;
+  wins: number;
+  losses: number;
+  draws: number;
+  win_rate: number | null; // 勝数 / (勝数 + 敗数)
+  average_moves: number | null; // 平均手数
+}
+
+export interface PlayerGame {
+  kifu: KifuSummary;
+  side: 'black' | 'white';
+  result: 'win' | 'loss' | 'draw' | null; // 不明はnull
+  opponent: { player_id: string | null; name: string } | null;
+}
+
+export interface PlayerDetail extends Player {
+  total: PlayerRecord;
+  black: PlayerRecord;
+  white: PlayerRecord;
+  first_started_at: string | null;
+  last_started_at: string | null;
+  openings: { name: string; count: number }[]; // 対局した棋譜のタグ（戦型）の多い順
+  games: PlayerGame[];
+}
+
+// 対局者の一覧の要素
+export interface PlayerSummary extends Player {
+  total: PlayerRecord;
+  black: PlayerRecord;
+  white: PlayerRecord;
+}
+
+// 対局者の統計の条件
+export interface PlayerStatsConditions {
+  started_from?: string; // YYYY-MM-DD
+  started_to?: string; // YYYY-MM-DD（この日を含む）
+  tags?: string[]; // 全てのタグを持つ棋譜
+}
+
+export interface PlayerStats {
+  player_id: string;
+  total: PlayerRecord;
+  black: PlayerRecord;
+  white: PlayerRecord;
+  openings: { name: string; record: PlayerRecord }[]; // 戦型（タグ）ごとの成績
+  opponents: {
+    player_id: string | null;
+    name: string;
+    last_started_at: string | null;
+    record: PlayerRecord;
+  }[]; // よく対局する相手との対戦成績
+}